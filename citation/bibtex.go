@@ -0,0 +1,90 @@
+package citation
+
+import (
+	"book-management/models"
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// bibtexEscaper escapes the characters LaTeX treats specially
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// writeBibTeX writes one @book entry per book
+func writeBibTeX(w io.Writer, books []models.Book) error {
+	out := bufio.NewWriter(w)
+	used := make(map[string]int)
+
+	for i, book := range books {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+
+		out.WriteString("@book{" + citeKey(book, used) + ",\n")
+		field := func(name, value string) {
+			if value != "" {
+				out.WriteString("  " + name + " = {" + value + "},\n")
+			}
+		}
+
+		names := make([]string, len(book.Authors))
+		for j, author := range book.Authors {
+			names[j] = bibtexEscaper.Replace(ParseName(author).Inverted())
+		}
+		field("author", strings.Join(names, " and "))
+		field("title", bibtexEscaper.Replace(book.Title))
+		field("publisher", bibtexEscaper.Replace(book.Publisher))
+		if book.ReleaseYear != 0 {
+			field("year", strconv.Itoa(book.ReleaseYear))
+		}
+		field("isbn", book.ISBN)
+		if book.TotalPage != 0 {
+			field("pagetotal", strconv.Itoa(book.TotalPage))
+		}
+		out.WriteString("}\n")
+	}
+
+	return out.Flush()
+}
+
+// citeKey builds a key from the first author's family name and the year,
+// e.g. "pramoedya1980", falling back to "book<ID>". Keys already used in the
+// same document get a letter suffix.
+func citeKey(book models.Book, used map[string]int) string {
+	key := ""
+	if len(book.Authors) > 0 {
+		for _, r := range strings.ToLower(ParseName(book.Authors[0]).Family) {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				key += string(r)
+			}
+		}
+	}
+	if key == "" {
+		return "book" + strconv.Itoa(book.ID)
+	}
+	if book.ReleaseYear != 0 {
+		key += strconv.Itoa(book.ReleaseYear)
+	}
+
+	n := used[key]
+	used[key] = n + 1
+	switch {
+	case n == 0:
+		return key
+	case n <= 26:
+		return key + string(rune('a'+n-1))
+	}
+	return key + "-" + strconv.Itoa(n)
+}
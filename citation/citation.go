@@ -0,0 +1,109 @@
+// Package citation renders books as BibTeX, RIS and CSL-JSON citations.
+package citation
+
+import (
+	"book-management/models"
+	"io"
+	"strings"
+)
+
+// Format is a citation output format
+type Format string
+
+// Supported citation formats
+const (
+	BibTeX  Format = "bibtex"
+	RIS     Format = "ris"
+	CSLJSON Format = "csl-json"
+)
+
+// ContentTypes maps the media types clients may ask for to a format. The
+// first entry is the default when the client accepts anything.
+var ContentTypes = []struct {
+	MediaType string
+	Format    Format
+}{
+	{"application/x-bibtex", BibTeX},
+	{"text/x-bibtex", BibTeX},
+	{"application/x-research-info-systems", RIS},
+	{"application/vnd.citationstyles.csl+json", CSLJSON},
+	{"application/json", CSLJSON},
+}
+
+// ParseFormat reads a format parameter value
+func ParseFormat(name string) (Format, bool) {
+	switch strings.ToLower(name) {
+	case "bibtex", "bib":
+		return BibTeX, true
+	case "ris":
+		return RIS, true
+	case "csl-json", "csljson", "csl":
+		return CSLJSON, true
+	}
+	return "", false
+}
+
+// ContentType is the media type a format is served with
+func (f Format) ContentType() string {
+	switch f {
+	case BibTeX:
+		return "application/x-bibtex; charset=utf-8"
+	case RIS:
+		return "application/x-research-info-systems; charset=utf-8"
+	}
+	return "application/vnd.citationstyles.csl+json"
+}
+
+// Extension is the usual file extension of a format
+func (f Format) Extension() string {
+	switch f {
+	case BibTeX:
+		return "bib"
+	case RIS:
+		return "ris"
+	}
+	return "json"
+}
+
+// Write renders books in the given format
+func Write(w io.Writer, format Format, books []models.Book) error {
+	switch format {
+	case BibTeX:
+		return writeBibTeX(w, books)
+	case RIS:
+		return writeRIS(w, books)
+	}
+	return writeCSLJSON(w, books)
+}
+
+// Name is a personal name split into family and given parts
+type Name struct {
+	Family string `json:"family,omitempty"`
+	Given  string `json:"given,omitempty"`
+}
+
+// ParseName splits "Family, Given" or "Given Family". A single word is
+// taken as the family name.
+func ParseName(name string) Name {
+	name = strings.TrimSpace(name)
+	if family, given, ok := strings.Cut(name, ","); ok {
+		return Name{Family: strings.TrimSpace(family), Given: strings.TrimSpace(given)}
+	}
+
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return Name{Family: name}
+	}
+	return Name{
+		Family: words[len(words)-1],
+		Given:  strings.Join(words[:len(words)-1], " "),
+	}
+}
+
+// Inverted formats the name as "Family, Given"
+func (n Name) Inverted() string {
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
@@ -0,0 +1,61 @@
+package citation
+
+import (
+	"book-management/models"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CSLItem is a CSL-JSON item of type "book"
+type CSLItem struct {
+	ID            string   `json:"id"`
+	Type          string   `json:"type"`
+	Title         string   `json:"title"`
+	Author        []Name   `json:"author,omitempty"`
+	Publisher     string   `json:"publisher,omitempty"`
+	Issued        *CSLDate `json:"issued,omitempty"`
+	ISBN          string   `json:"ISBN,omitempty"`
+	NumberOfPages string   `json:"number-of-pages,omitempty"`
+	Abstract      string   `json:"abstract,omitempty"`
+}
+
+// CSLDate is a CSL date in date-parts form
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// NewCSLItem maps a book onto a CSL-JSON item
+func NewCSLItem(book models.Book) CSLItem {
+	item := CSLItem{
+		ID:        "book-" + strconv.Itoa(book.ID),
+		Type:      "book",
+		Title:     book.Title,
+		Publisher: book.Publisher,
+		ISBN:      book.ISBN,
+		Abstract:  book.Description,
+	}
+	for _, author := range book.Authors {
+		item.Author = append(item.Author, ParseName(author))
+	}
+	if book.ReleaseYear != 0 {
+		item.Issued = &CSLDate{DateParts: [][]int{{book.ReleaseYear}}}
+	}
+	if book.TotalPage != 0 {
+		item.NumberOfPages = strconv.Itoa(book.TotalPage)
+	}
+	return item
+}
+
+// writeCSLJSON writes the books as a CSL-JSON array
+func writeCSLJSON(w io.Writer, books []models.Book) error {
+	items := make([]CSLItem, len(books))
+	for i, book := range books {
+		items[i] = NewCSLItem(book)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
@@ -0,0 +1,40 @@
+package citation
+
+import (
+	"book-management/models"
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeRIS writes one TY BOOK record per book. RIS lines end in CRLF.
+func writeRIS(w io.Writer, books []models.Book) error {
+	out := bufio.NewWriter(w)
+
+	for _, book := range books {
+		tag := func(name, value string) {
+			// A tag value must stay on one line
+			value = strings.Join(strings.Fields(value), " ")
+			if value != "" {
+				out.WriteString(name + "  - " + value + "\r\n")
+			}
+		}
+
+		tag("TY", "BOOK")
+		tag("ID", strconv.Itoa(book.ID))
+		tag("TI", book.Title)
+		for _, author := range book.Authors {
+			tag("AU", ParseName(author).Inverted())
+		}
+		tag("PB", book.Publisher)
+		if book.ReleaseYear != 0 {
+			tag("PY", strconv.Itoa(book.ReleaseYear))
+		}
+		tag("SN", book.ISBN)
+		tag("AB", book.Description)
+		out.WriteString("ER  - \r\n\r\n")
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"book-management/models"
+	"log"
+)
+
+// InitCirculation loads the lending rules from LOAN_PERIOD_DAYS,
+// LOAN_LIMIT, MAX_RENEWALS and HOLD_PICKUP_DAYS, and the fine rules.
+// Unset variables keep their defaults.
+func InitCirculation() {
+	rules := models.DefaultCirculationRules()
+	err := envInts(map[string]*int{
+		"LOAN_PERIOD_DAYS": &rules.LoanPeriodDays,
+		"LOAN_LIMIT":       &rules.LoanLimit,
+		"MAX_RENEWALS":     &rules.MaxRenewals,
+		"HOLD_PICKUP_DAYS": &rules.HoldPickupDays,
+	})
+	if err != nil {
+		log.Fatal("Invalid circulation rules:", err)
+	}
+	models.SetCirculationRules(rules)
+
+	fines, err := fineRulesFromEnv()
+	if err != nil {
+		log.Fatal("Invalid fine rules:", err)
+	}
+	models.SetFineRules(fines)
+}
+
+// fineRulesFromEnv reads FINE_DAILY_RATE, FINE_GRACE_DAYS, FINE_MAX,
+// FINE_BLOCK_THRESHOLD and the per category overrides
+// FINE_CATEGORY_DAILY_RATE and FINE_CATEGORY_MAX ("<category_id>:<amount>,...")
+func fineRulesFromEnv() (models.FineRules, error) {
+	rules := models.DefaultFineRules()
+
+	err := envInts(map[string]*int{
+		"FINE_DAILY_RATE":      &rules.DailyRate,
+		"FINE_GRACE_DAYS":      &rules.GraceDays,
+		"FINE_MAX":             &rules.MaxFine,
+		"FINE_BLOCK_THRESHOLD": &rules.BlockThreshold,
+	})
+	if err != nil {
+		return rules, err
+	}
+
+	if err := envCategoryInts("FINE_CATEGORY_DAILY_RATE", rules.CategoryDailyRate); err != nil {
+		return rules, err
+	}
+	return rules, envCategoryInts("FINE_CATEGORY_MAX", rules.CategoryMaxFine)
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// BaseURL is the public scheme and host of the API, used for absolute links
+// such as the identifiers in book metadata. It is configured rather than
+// taken from request headers, which clients can forge.
+var BaseURL string
+
+// InitServer loads PUBLIC_BASE_URL, defaulting to http://localhost:<PORT>
+func InitServer() {
+	raw := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if raw == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		raw = "http://localhost:" + port
+		log.Println("PUBLIC_BASE_URL is not set, using", raw)
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		log.Fatalf("PUBLIC_BASE_URL must be an absolute http(s) URL, got %q", raw)
+	}
+	BaseURL = raw
+}
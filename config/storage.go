@@ -0,0 +1,34 @@
+package config
+
+import (
+	"book-management/storage"
+	"log"
+	"os"
+)
+
+// Storage is the blob store for uploaded files
+var Storage storage.BlobStore
+
+// FileURLSecret is the HMAC key of file download links
+var FileURLSecret []byte
+
+// InitStorage opens the blob store configured by STORAGE_DRIVER
+func InitStorage() {
+	var err error
+	Storage, err = storage.FromEnv()
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+	log.Println("Storage initialized successfully")
+
+	// Download links must not be signed with the key that signs login
+	// tokens, nor with a key anyone can read in the source
+	secret := os.Getenv("FILE_URL_SECRET")
+	if secret == "" {
+		log.Fatal("FILE_URL_SECRET must be set")
+	}
+	if secret == os.Getenv("JWT_SECRET") {
+		log.Fatal("FILE_URL_SECRET must differ from JWT_SECRET")
+	}
+	FileURLSecret = []byte(secret)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"book-management/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InitValidation loads the book validation rules from the environment.
+// Unset variables keep their defaults.
+func InitValidation() {
+	rules, err := bookRulesFromEnv()
+	if err != nil {
+		log.Fatal("Invalid validation rules:", err)
+	}
+	models.SetBookRules(rules)
+}
+
+// bookRulesFromEnv reads BOOK_MIN_RELEASE_YEAR, BOOK_RELEASE_YEAR_LEAD,
+// BOOK_TITLE_MIN_LENGTH, BOOK_TITLE_MAX_LENGTH, BOOK_MAX_PRICE and
+// BOOK_CATEGORY_MAX_PRICE ("<category_id>:<price>,...")
+func bookRulesFromEnv() (models.BookRules, error) {
+	rules := models.DefaultBookRules()
+
+	err := envInts(map[string]*int{
+		"BOOK_MIN_RELEASE_YEAR":  &rules.MinReleaseYear,
+		"BOOK_RELEASE_YEAR_LEAD": &rules.ReleaseYearLead,
+		"BOOK_TITLE_MIN_LENGTH":  &rules.TitleMinLength,
+		"BOOK_TITLE_MAX_LENGTH":  &rules.TitleMaxLength,
+		"BOOK_MAX_PRICE":         &rules.MaxPrice,
+	})
+	if err != nil {
+		return rules, err
+	}
+
+	return rules, envCategoryInts("BOOK_CATEGORY_MAX_PRICE", rules.CategoryMaxPrice)
+}
+
+// envInts reads each named environment variable into its target. Unset
+// variables leave the target unchanged.
+func envInts(targets map[string]*int) error {
+	for name, target := range targets {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return fmt.Errorf("%s must be a non-negative number, got %q", name, raw)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// envCategoryInts reads a "<category_id>:<value>,..." environment variable
+// into target
+func envCategoryInts(name string, target map[int]int) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(raw, ",") {
+		id, amount, found := strings.Cut(strings.TrimSpace(entry), ":")
+		categoryID, idErr := strconv.Atoi(id)
+		value, valueErr := strconv.Atoi(amount)
+		if !found || idErr != nil || valueErr != nil || value < 0 {
+			return fmt.Errorf("%s entries must look like <category_id>:<value>, got %q", name, entry)
+		}
+		target[categoryID] = value
+	}
+	return nil
+}
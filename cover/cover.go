@@ -0,0 +1,177 @@
+// Package cover validates uploaded cover images, strips their metadata and
+// renders thumbnails.
+package cover
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the decoded size of an image so a small file cannot
+// expand into a huge bitmap
+const MaxPixels = 40_000_000
+
+// Size is a rendition of a cover, fitted inside a Width x Width box
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Original is the name of the full-size rendition
+const Original = "original"
+
+// Sizes are the thumbnail renditions generated for every cover
+var Sizes = []Size{
+	{Name: "small", Width: 150},
+	{Name: "medium", Width: 300},
+	{Name: "large", Width: 600},
+}
+
+// AllowedTypes are the sniffed MIME types accepted for upload
+var AllowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ErrUnsupportedType is returned for files that are not an allowed image
+var ErrUnsupportedType = errors.New("cover must be a JPEG, PNG, GIF or WebP image")
+
+// Rendition is one encoded image of a cover
+type Rendition struct {
+	Name string
+	Data []byte
+}
+
+// Cover is a processed upload
+type Cover struct {
+	// Hash is the SHA-256 of the uploaded file
+	Hash        string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	// Renditions holds the original first, then one entry per Sizes
+	Renditions []Rendition
+}
+
+// Process validates data, re-encodes it without EXIF or other metadata
+// (applying the EXIF orientation first) and renders every thumbnail size.
+// JPEG and WebP uploads are stored as JPEG, PNG and GIF uploads (first
+// frame only) as PNG.
+func Process(data []byte) (*Cover, error) {
+	sniffed := http.DetectContentType(data)
+	if !AllowedTypes[sniffed] {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("cover must not exceed %d pixels", MaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if sniffed == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	sum := sha256.Sum256(data)
+	cover := &Cover{
+		Hash:        hex.EncodeToString(sum[:]),
+		ContentType: "image/jpeg",
+		Extension:   "jpg",
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+	if sniffed == "image/png" || sniffed == "image/gif" {
+		cover.ContentType = "image/png"
+		cover.Extension = "png"
+	}
+
+	encoded, err := cover.encode(img)
+	if err != nil {
+		return nil, err
+	}
+	cover.Renditions = append(cover.Renditions, Rendition{Name: Original, Data: encoded})
+
+	for _, size := range Sizes {
+		encoded, err := cover.encode(fit(img, size.Width))
+		if err != nil {
+			return nil, err
+		}
+		cover.Renditions = append(cover.Renditions, Rendition{Name: size.Name, Data: encoded})
+	}
+
+	return cover, nil
+}
+
+// Filename is the stored file name of a rendition, e.g. "small.jpg"
+func (c *Cover) Filename(name string) string {
+	return name + "." + c.Extension
+}
+
+// encode writes img in the cover's output format. Neither encoder writes
+// any metadata.
+func (c *Cover) encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if c.Extension == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), err
+}
+
+// fit scales img down to fit in a width x width box; it is never enlarged
+func fit(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= width && h <= width {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*width/w)
+		w = width
+	} else {
+		w = max(1, w*width/h)
+		h = width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Over, nil)
+	return dst
+}
+
+// flatten draws img over white so transparent areas don't turn black in JPEG
+func flatten(img image.Image) image.Image {
+	if _, ok := img.(*image.YCbCr); ok {
+		return img
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,57 @@
+// Package edition extracts bibliographic metadata and page counts from
+// EPUB and PDF files.
+package edition
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// Metadata is what could be read from a digital edition. Empty fields were
+// not found in the file.
+type Metadata struct {
+	Title     string   `json:"title,omitempty"`
+	Language  string   `json:"language,omitempty"`
+	Authors   []string `json:"authors,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	ISBN      string   `json:"isbn,omitempty"`
+	PageCount int      `json:"page_count,omitempty"`
+	// PageCountEstimated is set when an EPUB has no page list and the count
+	// was derived from its text length
+	PageCountEstimated bool `json:"page_count_estimated,omitempty"`
+}
+
+// ErrUnsupported is returned for formats other than "pdf" and "epub"
+var ErrUnsupported = errors.New("edition: unsupported format")
+
+// Extract reads the metadata of a file in the given format ("pdf" or "epub")
+func Extract(r io.ReaderAt, size int64, format string) (Metadata, error) {
+	switch format {
+	case "epub":
+		return extractEPUB(r, size)
+	case "pdf":
+		return extractPDF(io.NewSectionReader(r, 0, size))
+	}
+	return Metadata{}, ErrUnsupported
+}
+
+// isbnFrom returns the ISBN-10/13 in an identifier such as
+// "urn:isbn:978-602-03-1234-5" or "ISBN 9786020312345", or ""
+func isbnFrom(identifier string) string {
+	s := strings.ToUpper(strings.TrimSpace(identifier))
+	s = strings.TrimPrefix(s, "URN:ISBN:")
+	s = strings.TrimPrefix(s, "ISBN")
+	s = strings.TrimLeft(s, ": ")
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+
+	if len(s) != 10 && len(s) != 13 {
+		return ""
+	}
+	for i, r := range s {
+		if (r < '0' || r > '9') && !(r == 'X' && i == 9 && len(s) == 10) {
+			return ""
+		}
+	}
+	return s
+}
@@ -0,0 +1,173 @@
+package edition
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestISBNFrom(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       string
+	}{
+		{"urn:isbn:978-602-03-1234-5", "9786020312345"},
+		{"ISBN 9786020312345", "9786020312345"},
+		{"isbn: 0-306-40615-2", "0306406152"},
+		{"030640615x", "030640615X"},
+		{"X306406152", ""},
+		{"978602031234", ""},
+		{"97860203123456", ""},
+		{"urn:uuid:0b4a4f5e-5d0c-4c5a-9f1e-2f7a8c6e1d3b", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := isbnFrom(tt.identifier); got != tt.want {
+			t.Errorf("isbnFrom(%q) = %q, want %q", tt.identifier, got, tt.want)
+		}
+	}
+}
+
+// buildEPUB zips files into an EPUB
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const epubContainerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+func epubPackage(manifest, spine string) string {
+	return `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title> Bumi Manusia </dc:title>
+    <dc:language>id</dc:language>
+    <dc:creator>Pramoedya Ananta Toer</dc:creator>
+    <dc:creator opf:role="trl" xmlns:opf="http://www.idpf.org/2007/opf">Max Lane</dc:creator>
+    <dc:publisher>Lentera Dipantara</dc:publisher>
+    <dc:identifier>urn:uuid:1234</dc:identifier>
+    <dc:identifier>urn:isbn:978-602-03-1234-5</dc:identifier>
+  </metadata>
+  <manifest>` + manifest + `</manifest>
+  <spine>` + spine + `</spine>
+</package>`
+}
+
+func TestExtractEPUB(t *testing.T) {
+	chapter := "<html><head><title>ignored</title></head><body><p>" + strings.Repeat("a", charsPerPage+1) + "</p></body></html>"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Metadata
+	}{
+		{
+			name: "page list",
+			files: map[string]string{
+				"META-INF/container.xml": epubContainerXML,
+				"OEBPS/content.opf": epubPackage(
+					`<item id="nav" href="nav%20doc.xhtml" media-type="application/xhtml+xml" properties="nav"/>`,
+					`<itemref idref="nav"/>`),
+				"OEBPS/nav doc.xhtml": `<html><body><nav epub:type="page-list"><ol>
+					<li><a href="c.xhtml#p1">1</a></li><li><a href="c.xhtml#p2">2</a></li><li><a href="c.xhtml#p3">3</a></li>
+				</ol></nav></body></html>`,
+			},
+			want: Metadata{
+				Title: "Bumi Manusia", Language: "id", Authors: []string{"Pramoedya Ananta Toer"},
+				Publisher: "Lentera Dipantara", ISBN: "9786020312345", PageCount: 3,
+			},
+		},
+		{
+			name: "estimated from text",
+			files: map[string]string{
+				"META-INF/container.xml": epubContainerXML,
+				"OEBPS/content.opf": epubPackage(
+					`<item id="c1" href="text/c1.xhtml" media-type="application/xhtml+xml"/>`,
+					`<itemref idref="c1"/><itemref idref="missing"/>`),
+				"OEBPS/text/c1.xhtml": chapter,
+			},
+			want: Metadata{
+				Title: "Bumi Manusia", Language: "id", Authors: []string{"Pramoedya Ananta Toer"},
+				Publisher: "Lentera Dipantara", ISBN: "9786020312345", PageCount: 2, PageCountEstimated: true,
+			},
+		},
+		{
+			name: "repeated spine items read once",
+			files: map[string]string{
+				"META-INF/container.xml": epubContainerXML,
+				"OEBPS/content.opf": epubPackage(
+					`<item id="c1" href="text/c1.xhtml" media-type="application/xhtml+xml"/>`,
+					strings.Repeat(`<itemref idref="c1"/>`, 50)),
+				"OEBPS/text/c1.xhtml": chapter,
+			},
+			want: Metadata{
+				Title: "Bumi Manusia", Language: "id", Authors: []string{"Pramoedya Ananta Toer"},
+				Publisher: "Lentera Dipantara", ISBN: "9786020312345", PageCount: 2, PageCountEstimated: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildEPUB(t, tt.files)
+			got, err := Extract(bytes.NewReader(data), int64(len(data)), "epub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsInvalidFiles(t *testing.T) {
+	noContainer := buildEPUB(t, map[string]string{"mimetype": "application/epub+zip"})
+	noRootfile := buildEPUB(t, map[string]string{"META-INF/container.xml": "<container/>"})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"not a zip", []byte("%PDF-1.4"), "epub"},
+		{"missing container", noContainer, "epub"},
+		{"missing package document", noRootfile, "epub"},
+		{"unsupported format", []byte("x"), "mobi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Extract(bytes.NewReader(tt.data), int64(len(tt.data)), tt.format); err == nil {
+				t.Error("Extract() succeeded, want an error")
+			}
+		})
+	}
+
+	if _, err := Extract(bytes.NewReader(nil), 0, "mobi"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Extract() error = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,206 @@
+package edition
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerPage is used to estimate the page count of EPUBs without a page
+// list, roughly a printed paperback page
+const charsPerPage = 1800
+
+// maxEntrySize bounds how much of a single EPUB entry is read, guarding
+// against zip bombs, and maxSpineTotal and maxSpineItems how much reading
+// the spine to estimate the page count may cost together
+const (
+	maxEntrySize  = 20 << 20
+	maxSpineTotal = 50 << 20
+	maxSpineItems = 1000
+)
+
+var (
+	pageListPattern = regexp.MustCompile(`(?is)<nav[^>]*epub:type\s*=\s*"[^"]*\bpage-list\b[^"]*"[^>]*>(.*?)</nav>`)
+	navLinkPattern  = regexp.MustCompile(`(?i)<a\b`)
+	ncxPagePattern  = regexp.MustCompile(`(?i)<pageTarget\b`)
+	tagPattern      = regexp.MustCompile(`(?s)<[^>]*>`)
+	bodyPattern     = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+)
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfCreator struct {
+	Name string `xml:",chardata"`
+	Role string `xml:"role,attr"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string     `xml:"title"`
+		Languages   []string     `xml:"language"`
+		Creators    []opfCreator `xml:"creator"`
+		Publishers  []string     `xml:"publisher"`
+		Identifiers []string     `xml:"identifier"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// extractEPUB reads the OPF package document. The page count comes from
+// the EPUB 3 page-list nav or the EPUB 2 NCX pageList; without either it is
+// estimated from the text length of the spine.
+func extractEPUB(r io.ReaderAt, size int64) (Metadata, error) {
+	var meta Metadata
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return meta, err
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := decodeEntry(files, "META-INF/container.xml", &container); err != nil {
+		return meta, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return meta, errors.New("edition: EPUB has no package document")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var opf opfPackage
+	if err := decodeEntry(files, opfPath, &opf); err != nil {
+		return meta, err
+	}
+
+	if len(opf.Metadata.Titles) > 0 {
+		meta.Title = strings.TrimSpace(opf.Metadata.Titles[0])
+	}
+	if len(opf.Metadata.Languages) > 0 {
+		meta.Language = strings.TrimSpace(opf.Metadata.Languages[0])
+	}
+	if len(opf.Metadata.Publishers) > 0 {
+		meta.Publisher = strings.TrimSpace(opf.Metadata.Publishers[0])
+	}
+	for _, creator := range opf.Metadata.Creators {
+		if name := strings.TrimSpace(creator.Name); name != "" && (creator.Role == "" || creator.Role == "aut") {
+			meta.Authors = append(meta.Authors, name)
+		}
+	}
+	for _, identifier := range opf.Metadata.Identifiers {
+		if isbn := isbnFrom(identifier); isbn != "" {
+			meta.ISBN = isbn
+			break
+		}
+	}
+
+	// Manifest hrefs are relative to the package document
+	base := path.Dir(opfPath)
+	hrefs := make(map[string]string)
+	for _, item := range opf.Manifest {
+		href := item.Href
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		hrefs[item.ID] = path.Join(base, href)
+
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			if nav, err := readEntry(files, hrefs[item.ID]); err == nil {
+				if list := pageListPattern.FindSubmatch(nav); list != nil {
+					meta.PageCount = len(navLinkPattern.FindAll(list[1], -1))
+				}
+			}
+		}
+	}
+
+	if meta.PageCount == 0 && opf.Spine.Toc != "" {
+		if ncx, err := readEntry(files, hrefs[opf.Spine.Toc]); err == nil {
+			meta.PageCount = len(ncxPagePattern.FindAll(ncx, -1))
+		}
+	}
+
+	if meta.PageCount == 0 {
+		// A spine may list the same document many times; each is read once
+		chars := 0
+		budget := int64(maxSpineTotal)
+		read := make(map[string]bool)
+		for i, ref := range opf.Spine.Itemrefs {
+			if i >= maxSpineItems || budget <= 0 {
+				break
+			}
+			name := hrefs[ref.IDRef]
+			if read[name] {
+				continue
+			}
+			read[name] = true
+
+			doc, err := readEntryLimit(files, name, min(maxEntrySize, budget))
+			if err != nil {
+				continue
+			}
+			budget -= int64(len(doc))
+			if body := bodyPattern.FindSubmatch(doc); body != nil {
+				doc = body[1]
+			}
+			text := strings.Join(strings.Fields(string(tagPattern.ReplaceAll(doc, []byte(" ")))), " ")
+			chars += utf8.RuneCountInString(text)
+		}
+		if chars > 0 {
+			meta.PageCount = (chars + charsPerPage - 1) / charsPerPage
+			meta.PageCountEstimated = true
+		}
+	}
+
+	return meta, nil
+}
+
+// readEntry reads at most maxEntrySize bytes of an archive entry
+func readEntry(files map[string]*zip.File, name string) ([]byte, error) {
+	return readEntryLimit(files, name, maxEntrySize)
+}
+
+// readEntryLimit reads at most limit bytes of an archive entry
+func readEntryLimit(files map[string]*zip.File, name string, limit int64) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, errors.New("edition: missing " + name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+// decodeEntry unmarshals an XML archive entry
+func decodeEntry(files map[string]*zip.File, name string, v interface{}) error {
+	data, err := readEntry(files, name)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
@@ -0,0 +1,295 @@
+package edition
+
+import (
+	"bytes"
+	"compress/zlib"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFSize bounds how much of a PDF is scanned, maxInflatedSize how much
+// a single compressed object stream may expand to, and maxInflatedTotal and
+// maxObjectStreams how much inflating all of them may cost together
+const (
+	maxPDFSize       = 200 << 20
+	maxInflatedSize  = 20 << 20
+	maxInflatedTotal = 50 << 20
+	maxObjectStreams = 1000
+)
+
+var (
+	pagesTypePattern = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pageTypePattern  = regexp.MustCompile(`/Type\s*/Page\b`)
+	countPattern     = regexp.MustCompile(`/Count\s+(\d+)`)
+	objStmPattern    = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	streamPattern    = regexp.MustCompile(`stream\r?\n`)
+	titlePattern     = regexp.MustCompile(`/Title\s*([(<])`)
+	langPattern      = regexp.MustCompile(`/Lang\s*([(<])`)
+	infoPattern      = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	xmpTitlePattern  = regexp.MustCompile(`(?s)<dc:title>.*?<rdf:li[^>]*>(.*?)</rdf:li>`)
+)
+
+// extractPDF reads the page count from the page tree and the title and
+// language from the document information and catalog dictionaries. It
+// does not build a full object graph: it scans the file's objects,
+// including those packed in compressed object streams (PDF 1.5+).
+func extractPDF(r io.Reader) (Metadata, error) {
+	var meta Metadata
+
+	data, err := io.ReadAll(io.LimitReader(r, maxPDFSize))
+	if err != nil {
+		return meta, err
+	}
+
+	chunks := [][]byte{data}
+	chunks = append(chunks, objectStreams(data)...)
+
+	// The root of the page tree has the largest /Count of any Pages node
+	for _, chunk := range chunks {
+		for _, loc := range pagesTypePattern.FindAllIndex(chunk, -1) {
+			dict := enclosingDict(chunk, loc[0])
+			if m := countPattern.FindSubmatch(dict); m != nil {
+				if count, err := strconv.Atoi(string(m[1])); err == nil && count > meta.PageCount {
+					meta.PageCount = count
+				}
+			}
+		}
+	}
+
+	// Fall back to counting leaf page objects
+	if meta.PageCount == 0 {
+		for _, chunk := range chunks {
+			meta.PageCount += len(pageTypePattern.FindAll(chunk, -1))
+		}
+	}
+
+	// Strings of encrypted documents cannot be read without the key
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return meta, nil
+	}
+
+	// Outline entries also have a /Title, so only the document information
+	// dictionary and the XMP metadata are trusted for the title
+	if info := infoDict(data); info != nil {
+		meta.Title = strings.TrimSpace(stringAfter(info, titlePattern))
+	}
+	if meta.Title == "" {
+		if m := xmpTitlePattern.FindSubmatch(data); m != nil {
+			meta.Title = strings.TrimSpace(html.UnescapeString(string(m[1])))
+		}
+	}
+
+	// /Lang is only defined on the catalog (and marked content, which
+	// lives in compressed page streams that are not scanned)
+	for _, chunk := range chunks {
+		if meta.Language = strings.TrimSpace(stringAfter(chunk, langPattern)); meta.Language != "" {
+			break
+		}
+	}
+
+	return meta, nil
+}
+
+// infoDict returns the body of the object the last trailer names as /Info
+func infoDict(data []byte) []byte {
+	matches := infoPattern.FindAllSubmatch(data, -1)
+	if matches == nil {
+		return nil
+	}
+	m := matches[len(matches)-1]
+	header := regexp.MustCompile(`\b` + string(m[1]) + `\s+` + string(m[2]) + `\s+obj\b`)
+	loc := header.FindIndex(data)
+	if loc == nil {
+		return nil
+	}
+	body := data[loc[1]:]
+	if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// objectStreams inflates the compressed object streams in the file, up to
+// maxObjectStreams streams and maxInflatedTotal bytes
+func objectStreams(data []byte) [][]byte {
+	var streams [][]byte
+	budget := int64(maxInflatedTotal)
+	for _, loc := range objStmPattern.FindAllIndex(data, maxObjectStreams) {
+		if budget <= 0 {
+			break
+		}
+
+		start := streamPattern.FindIndex(data[loc[1]:])
+		if start == nil {
+			continue
+		}
+		body := data[loc[1]+start[1]:]
+		if end := bytes.Index(body, []byte("endstream")); end >= 0 {
+			body = body[:end]
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		inflated, _ := io.ReadAll(io.LimitReader(zr, min(maxInflatedSize, budget)))
+		zr.Close()
+		budget -= int64(len(inflated))
+		if len(inflated) > 0 {
+			streams = append(streams, inflated)
+		}
+	}
+	return streams
+}
+
+// enclosingDict returns the innermost << ... >> dictionary around offset
+func enclosingDict(data []byte, offset int) []byte {
+	start, depth := -1, 0
+	for i := offset; i > 0; i-- {
+		if data[i-1] == '>' && data[i] == '>' {
+			depth++
+			i--
+		} else if data[i-1] == '<' && data[i] == '<' {
+			if depth == 0 {
+				start = i - 1
+				break
+			}
+			depth--
+			i--
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	depth = 0
+	for i := start; i+1 < len(data); i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			depth++
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return data[start : i+1]
+			}
+		}
+	}
+	return data[start:]
+}
+
+// stringAfter decodes the literal or hex string that follows the first
+// match of pattern, whose last group captures the opening delimiter
+func stringAfter(data []byte, pattern *regexp.Regexp) string {
+	loc := pattern.FindSubmatchIndex(data)
+	if loc == nil {
+		return ""
+	}
+	open := loc[len(loc)-2]
+	if data[open] == '<' {
+		end := bytes.IndexByte(data[open:], '>')
+		if end < 0 {
+			return ""
+		}
+		return decodePDFText(hexString(data[open+1 : open+end]))
+	}
+	return decodePDFText(literalString(data[open+1:]))
+}
+
+// literalString decodes a (...) string body up to its closing parenthesis
+func literalString(data []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(data) {
+				return out
+			}
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n, j := 0, 0
+					for ; j < 3 && i+j < len(data) && data[i+j] >= '0' && data[i+j] <= '7'; j++ {
+						n = n*8 + int(data[i+j]-'0')
+					}
+					out = append(out, byte(n))
+					i += j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// hexString decodes a <...> string body
+func hexString(data []byte) []byte {
+	var digits []byte
+	for _, c := range data {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// decodePDFText converts a PDF text string, UTF-16BE with a byte order
+// mark or PDFDocEncoding (treated as Latin-1), to UTF-8
+func decodePDFText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		return string(b[3:])
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
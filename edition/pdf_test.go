@@ -0,0 +1,178 @@
+package edition
+
+import (
+	"bytes"
+	"compress/zlib"
+	"strings"
+	"testing"
+)
+
+func TestLiteralString(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`Hello) rest`, "Hello"},
+		{`a\(b\)c)`, "a(b)c"},
+		{`nested (x) y) rest`, "nested (x) y"},
+		{`\101\102C)`, "ABC"},
+		{`\0)`, "\x00"},
+		{`line\nbreak\ttab)`, "line\nbreak\ttab"},
+		{"cont\\\nued)", "contued"},
+		{`unterminated`, "unterminated"},
+		{`abc\`, "abc"},
+	}
+
+	for _, tt := range tests {
+		if got := string(literalString([]byte(tt.data))); got != tt.want {
+			t.Errorf("literalString(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestHexString(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"48656C6C6F", "Hello"},
+		{"48 65\n6c", "Hel"},
+		{"486", "H`"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := string(hexString([]byte(tt.data))); got != tt.want {
+			t.Errorf("hexString(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestDecodePDFText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"UTF-16BE", []byte{0xFE, 0xFF, 0x00, 'B', 0x00, 'u', 0x00}, "Bu"},
+		{"UTF-8 with BOM", []byte("\xEF\xBB\xBFBumi"), "Bumi"},
+		{"PDFDocEncoding", []byte("Caf\xE9"), "Café"},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := decodePDFText(tt.data); got != tt.want {
+			t.Errorf("%s: decodePDFText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// deflate zlib-compresses data for a FlateDecode stream
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// objStm wraps compressed data in an object stream
+func objStm(compressed []byte) string {
+	return "9 0 obj\n<< /Type /ObjStm /N 1 /First 0 /Filter /FlateDecode >>\nstream\n" +
+		string(compressed) + "\nendstream\nendobj\n"
+}
+
+func TestExtractPDF(t *testing.T) {
+	const pageTree = "2 0 obj\n<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Type /Page /Parent 2 0 R >>\nendobj\n"
+
+	tests := []struct {
+		name string
+		pdf  string
+		want Metadata
+	}{
+		{
+			name: "info dictionary",
+			pdf: "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R /Lang (id-ID) >>\nendobj\n" + pageTree +
+				"6 0 obj\n<< /Title (Bab 1) /Parent 7 0 R >>\nendobj\n" +
+				"5 0 obj\n<< /Title (Bumi Manusia) /Author (Pram) >>\nendobj\n" +
+				"trailer\n<< /Root 1 0 R /Info 5 0 R >>\n%%EOF\n",
+			want: Metadata{Title: "Bumi Manusia", Language: "id-ID", PageCount: 2},
+		},
+		{
+			name: "hex UTF-16 title",
+			pdf: "%PDF-1.4\n" + pageTree + "5 0 obj\n<< /Title <FEFF00420075006D0069> >>\nendobj\n" +
+				"trailer\n<< /Info 5 0 R >>\n",
+			want: Metadata{Title: "Bumi", PageCount: 2},
+		},
+		{
+			name: "XMP title",
+			pdf: "%PDF-1.4\n" + pageTree +
+				`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Anak &amp; Bangsa</rdf:li></rdf:Alt></dc:title>`,
+			want: Metadata{Title: "Anak & Bangsa", PageCount: 2},
+		},
+		{
+			name: "leaf pages without a count",
+			pdf:  "%PDF-1.4\n3 0 obj\n<< /Type /Page >>\nendobj\n4 0 obj\n<< /Type /Page >>\nendobj\n5 0 obj\n<< /Type/Page >>\nendobj\n",
+			want: Metadata{PageCount: 3},
+		},
+		{
+			name: "page tree in an object stream",
+			pdf: "%PDF-1.5\n" + objStm(deflate(t, []byte("<< /Type /Catalog /Lang (en) >> << /Type /Pages /Count 7 >>"))) +
+				"3 0 obj\n<< /Type /Pages /Count 1 >>\nendobj\n",
+			want: Metadata{Language: "en", PageCount: 7},
+		},
+		{
+			name: "encrypted",
+			pdf: "%PDF-1.4\n" + pageTree + "5 0 obj\n<< /Title (garbled) >>\nendobj\n" +
+				"trailer\n<< /Info 5 0 R /Encrypt 8 0 R >>\n",
+			want: Metadata{PageCount: 2},
+		},
+		{
+			name: "not a PDF",
+			pdf:  "hello",
+			want: Metadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractPDF(strings.NewReader(tt.pdf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != tt.want.Title || got.Language != tt.want.Language || got.PageCount != tt.want.PageCount {
+				t.Errorf("extractPDF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectStreamsLimits(t *testing.T) {
+	small := objStm(deflate(t, []byte("<< >>")))
+	got := objectStreams([]byte(strings.Repeat(small, maxObjectStreams+5)))
+	if len(got) != maxObjectStreams {
+		t.Errorf("inflated %d streams, want %d", len(got), maxObjectStreams)
+	}
+
+	// Each stream inflates to the per-stream limit, so the total limit is
+	// reached in the third stream and the fourth is skipped
+	large := objStm(deflate(t, make([]byte, maxInflatedSize+1)))
+	got = objectStreams([]byte(strings.Repeat(large, 4)))
+	total := 0
+	for _, stream := range got {
+		if len(stream) > maxInflatedSize {
+			t.Errorf("stream inflated to %d bytes, limit is %d", len(stream), maxInflatedSize)
+		}
+		total += len(stream)
+	}
+	if total != maxInflatedTotal || len(got) != 3 {
+		t.Errorf("inflated %d streams with %d bytes, want 3 with %d", len(got), total, maxInflatedTotal)
+	}
+}
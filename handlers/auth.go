@@ -22,10 +22,8 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Simple authentication (in production, verify against database with hashed passwords)
-	// For demo purposes, accepting any username/password combination
-	// You should implement proper authentication here
-	if input.Username == "" || input.Password == "" {
+	roles, ok := middleware.CheckCredentials(input.Username, input.Password)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid credentials",
 		})
@@ -33,7 +31,7 @@ func Login(c *gin.Context) {
 	}
 
 	// Generate JWT token
-	token, err := middleware.GenerateToken(input.Username)
+	token, err := middleware.GenerateToken(input.Username, roles)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -42,8 +40,8 @@ func Login(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
+		"message":  "Login successful",
+		"token":    token,
 		"username": input.Username,
 	})
 }
@@ -4,54 +4,336 @@ import (
 	"book-management/config"
 	"book-management/models"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
-// GetAllBooks retrieves all books
-func GetAllBooks(c *gin.Context) {
+// bookColumns is the column list scanned by scanBook. raw_metadata is left
+// out because it can be large; select it only where it is used.
+const bookColumns = `
+	id, title, isbn, description, image_url, release_year, price_minor,
+	currency, total_page, thickness, category_id, created_at, created_by,
+	modified_at, modified_by, version, authors, publisher,
+	image_status, image_mirror_url, image_error, image_checked_at,
+	rating_average, rating_count, tags
+`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook scans a row selected with bookColumns into a Book. Any extra
+// destinations are scanned from the columns that follow bookColumns.
+func scanBook(row rowScanner, book *models.Book, extra ...interface{}) error {
+	dest := []interface{}{
+		&book.ID,
+		&book.Title,
+		&book.ISBN,
+		&book.Description,
+		&book.ImageURL,
+		&book.ReleaseYear,
+		&book.PriceMinor,
+		&book.Currency,
+		&book.TotalPage,
+		&book.Thickness,
+		&book.CategoryID,
+		&book.CreatedAt,
+		&book.CreatedBy,
+		&book.ModifiedAt,
+		&book.ModifiedBy,
+		&book.Version,
+		pq.Array(&book.Authors),
+		&book.Publisher,
+		&book.ImageStatus,
+		&book.ImageMirrorURL,
+		&book.ImageError,
+		&book.ImageCheckedAt,
+		&book.RatingAverage,
+		&book.RatingCount,
+		pq.Array(&book.Tags),
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// findBook loads a single book by ID
+func findBook(q queryer, id int) (models.Book, error) {
+	var book models.Book
+	err := scanBook(q.QueryRow(`
+		SELECT `+bookColumns+`
+		FROM books
+		WHERE id = $1
+	`, id), &book)
+	return book, err
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// authorsOrEmpty keeps a missing author list from being written as NULL
+func authorsOrEmpty(authors []string) []string {
+	if authors == nil {
+		return []string{}
+	}
+	return authors
+}
+
+// insertBook inserts a new book and returns its ID and initial version.
+// The price opens the book's price history.
+func insertBook(q queryer, input models.BookInput, thickness, username string) (int, int, error) {
+	var bookID, version int
+	err := withTx(q, func(q queryer) error {
+		err := q.QueryRow(`
+		INSERT INTO books (
+			title, description, image_url, release_year, price_minor,
+			total_page, thickness, category_id,
+			created_at, created_by, modified_at, modified_by, isbn,
+			authors, publisher, image_status, tags, currency
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING id, version
+	`,
+			input.Title,
+			input.Description,
+			input.ImageURL,
+			input.ReleaseYear,
+			input.PriceMinor,
+			input.TotalPage,
+			thickness,
+			input.CategoryID,
+			time.Now(),
+			username,
+			time.Now(),
+			username,
+			models.NormalizeISBN(input.ISBN),
+			pq.Array(authorsOrEmpty(input.Authors)),
+			input.Publisher,
+			imageStatusFor(input.ImageURL),
+			pq.Array(models.NormalizeTags(input.Tags)),
+			models.NormalizeCurrency(input.Currency),
+		).Scan(&bookID, &version)
+		if err != nil {
+			return err
+		}
+		return recordBasePrice(q, bookID, input, username)
+	})
+	return bookID, version, err
+}
+
+// updateBookRow writes input to the book if its version is one of versions
+// (nil means any version) and returns the new version. sql.ErrNoRows is
+// returned when the book is missing or its version did not match. A changed
+// image_url resets the image mirroring state, and a changed price is added
+// to the price history.
+func updateBookRow(q queryer, id int, input models.BookInput, thickness, username string, versions []int64) (int, error) {
+	var version int
+	err := withTx(q, func(q queryer) error {
+		err := q.QueryRow(`
+		UPDATE books
+		SET title = $1, description = $2, image_url = $3, release_year = $4,
+		    price_minor = $5, total_page = $6, thickness = $7, category_id = $8,
+		    modified_at = $9, modified_by = $10, isbn = $13, authors = $14,
+		    publisher = $15, tags = $17, currency = $18, version = version + 1,
+		    image_status = CASE WHEN image_url = $3 THEN image_status ELSE $16 END,
+		    image_mirror_url = CASE WHEN image_url = $3 THEN image_mirror_url ELSE '' END,
+		    image_error = CASE WHEN image_url = $3 THEN image_error ELSE '' END,
+		    image_checked_at = CASE WHEN image_url = $3 THEN image_checked_at END
+		WHERE id = $11 AND ($12::bigint[] IS NULL OR version = ANY($12))
+		RETURNING version
+	`,
+			input.Title,
+			input.Description,
+			input.ImageURL,
+			input.ReleaseYear,
+			input.PriceMinor,
+			input.TotalPage,
+			thickness,
+			input.CategoryID,
+			time.Now(),
+			username,
+			id,
+			pq.Array(versions),
+			models.NormalizeISBN(input.ISBN),
+			pq.Array(authorsOrEmpty(input.Authors)),
+			input.Publisher,
+			imageStatusFor(input.ImageURL),
+			pq.Array(models.NormalizeTags(input.Tags)),
+			models.NormalizeCurrency(input.Currency),
+		).Scan(&version)
+		if err != nil {
+			return err
+		}
+		return recordBasePrice(q, id, input, username)
+	})
+	return version, err
+}
+
+// errBookHasCopies is returned by deleteBookRow for a book that still has
+// library copies
+var errBookHasCopies = errors.New("book has library copies")
+
+// deleteBookRow deletes the book if its version is one of versions (nil
+// matches any version) and reports how many rows were deleted. A book with
+// copies is not deleted; its copies must be deleted or withdrawn first.
+func deleteBookRow(q queryer, id int, versions []int64) (int64, error) {
+	var rowsAffected int64
+	err := withTx(q, func(q queryer) error {
+		var hasCopies bool
+		err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM copies WHERE book_id = $1)", id).Scan(&hasCopies)
+		if err != nil {
+			return err
+		}
+		if hasCopies {
+			return errBookHasCopies
+		}
+
+		if err := markSimilarListsStale(q, id); err != nil {
+			return err
+		}
+
+		listIDs, err := lockBookReadingLists(q, id)
+		if err != nil {
+			return err
+		}
+
+		var isbn string
+		err = q.QueryRow(`
+			DELETE FROM books
+			WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))
+			RETURNING isbn
+		`, id, pq.Array(versions)).Scan(&isbn)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if isForeignKeyViolation(err) {
+			return errBookHasCopies
+		}
+		if err != nil {
+			return err
+		}
+		rowsAffected = 1
+
+		// Remembered for the delete notifications of ONIX delta feeds
+		_, err = q.Exec(`
+			INSERT INTO book_deletions (book_id, isbn, deleted_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (book_id) DO UPDATE SET isbn = EXCLUDED.isbn, deleted_at = EXCLUDED.deleted_at
+		`, id, isbn, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// The cascade removed the book from reading lists
+		return renumberReadingLists(q, listIDs)
+	})
+	return rowsAffected, err
+}
+
+// queryBooks returns the books matching filter in the filter's order. When page is
+// nil every matching book is returned; otherwise only that page is returned
+// together with the total number of matches.
+func queryBooks(filter bookFilter, page *pagination) ([]models.Book, int, error) {
+	where, args := filter.where()
+
+	limit := ""
+	total := 0
+	if page != nil {
+		err := config.DB.QueryRow("SELECT COUNT(*) FROM books "+where, args...).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+		limit = fmt.Sprintf("LIMIT %d OFFSET %d", page.PageSize, page.offset())
+	}
+
 	rows, err := config.DB.Query(`
-		SELECT id, title, description, image_url, release_year, price, 
-		       total_page, thickness, category_id, created_at, created_by, 
-		       modified_at, modified_by 
-		FROM books 
-		ORDER BY id DESC
-	`)
+		SELECT `+bookColumns+`
+		FROM books
+		`+where+`
+		ORDER BY `+filter.orderBy()+`
+		`+limit, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch books",
-		})
-		return
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var books []models.Book
 	for rows.Next() {
 		var book models.Book
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Description,
-			&book.ImageURL,
-			&book.ReleaseYear,
-			&book.Price,
-			&book.TotalPage,
-			&book.Thickness,
-			&book.CategoryID,
-			&book.CreatedAt,
-			&book.CreatedBy,
-			&book.ModifiedAt,
-			&book.ModifiedBy,
-		)
-		if err != nil {
+		if err := scanBook(rows, &book); err != nil {
 			continue
 		}
 		books = append(books, book)
 	}
 
+	if page == nil {
+		total = len(books)
+	}
+	return books, total, rows.Err()
+}
+
+// GetAllBooks retrieves all books, optionally filtered and paginated
+func GetAllBooks(c *gin.Context) {
+	filter, err := parseBookFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	page, paginated, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	var pagePtr *pagination
+	if paginated {
+		pagePtr = &page
+	}
+
+	books, total, err := queryBooks(filter, pagePtr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch books",
+		})
+		return
+	}
+
+	if err := attachPrices(books, currency); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch prices",
+		})
+		return
+	}
+	translateThickness(books, requestedLanguage(c))
+
+	if paginated {
+		c.JSON(http.StatusOK, gin.H{
+			"data":       books,
+			"pagination": page.meta(total),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": books,
 	})
@@ -60,10 +342,7 @@ func GetAllBooks(c *gin.Context) {
 // CreateBook creates a new book
 func CreateBook(c *gin.Context) {
 	var input models.BookInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	if !bindJSON(c, &input) {
 		return
 	}
 
@@ -83,30 +362,7 @@ func CreateBook(c *gin.Context) {
 	// Calculate thickness
 	thickness := input.CalculateThickness()
 
-	var bookID int
-	err = config.DB.QueryRow(`
-		INSERT INTO books (
-			title, description, image_url, release_year, price, 
-			total_page, thickness, category_id, 
-			created_at, created_by, modified_at, modified_by
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id
-	`,
-		input.Title,
-		input.Description,
-		input.ImageURL,
-		input.ReleaseYear,
-		input.Price,
-		input.TotalPage,
-		thickness,
-		input.CategoryID,
-		time.Now(),
-		usernameStr,
-		time.Now(),
-		usernameStr,
-	).Scan(&bookID)
-
+	bookID, version, err := insertBook(config.DB, input, thickness, usernameStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create book",
@@ -114,6 +370,8 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
+	wakeImageMirror()
+	c.Header("ETag", formatETag(version))
 	c.JSON(http.StatusCreated, gin.H{
 		"message":   "Book created successfully",
 		"id":        bookID,
@@ -121,7 +379,8 @@ func CreateBook(c *gin.Context) {
 	})
 }
 
-// GetBookByID retrieves a book by ID
+// GetBookByID retrieves a book by ID. Besides the default JSON it can be
+// served as schema.org JSON-LD or Dublin Core XML via Accept or format.
 func GetBookByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -131,28 +390,17 @@ func GetBookByID(c *gin.Context) {
 		return
 	}
 
-	var book models.Book
-	err = config.DB.QueryRow(`
-		SELECT id, title, description, image_url, release_year, price, 
-		       total_page, thickness, category_id, created_at, created_by, 
-		       modified_at, modified_by 
-		FROM books 
-		WHERE id = $1
-	`, id).Scan(
-		&book.ID,
-		&book.Title,
-		&book.Description,
-		&book.ImageURL,
-		&book.ReleaseYear,
-		&book.Price,
-		&book.TotalPage,
-		&book.Thickness,
-		&book.CategoryID,
-		&book.CreatedAt,
-		&book.CreatedBy,
-		&book.ModifiedAt,
-		&book.ModifiedBy,
-	)
+	representation, ok := bookRepresentation(c)
+	if !ok {
+		return
+	}
+
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	book, err := findBook(config.DB, id)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -168,9 +416,56 @@ func GetBookByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": book,
-	})
+	availability, err := bookAvailability(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch availability",
+		})
+		return
+	}
+
+	// A converted price also depends on the exchange rates, the
+	// availability on the copies and the rating on the reviews, none of
+	// which the version covers
+	var body []byte
+	if representation == gin.MIMEJSON {
+		books := []models.Book{book}
+		if err := attachPrices(books, currency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch prices",
+			})
+			return
+		}
+		translateThickness(books, requestedLanguage(c))
+		book = books[0]
+		if availability.Total > 0 {
+			book.Availability = &availability
+		}
+
+		body, err = json.Marshal(gin.H{
+			"data": book,
+		})
+	} else {
+		body, err = bookMetadata(representation, book)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build book",
+		})
+		return
+	}
+
+	// The body also depends on the exchange rates, copies, reviews and
+	// category, none of which the version covers, so the ETag carries a hash
+	// of it. Each representation has its own ETag so that a cache never
+	// answers a request for one with a 304 validated against another.
+	c.Header("Vary", "Accept, Accept-Language")
+	etag := formatContentETag(book.Version, representationVariants[representation], body)
+	if etagNotModified(c, etag) {
+		return
+	}
+
+	c.Data(http.StatusOK, representation+"; charset=utf-8", body)
 }
 
 // UpdateBook updates a book by ID
@@ -183,11 +478,13 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
 	var input models.BookInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	if !bindJSON(c, &input) {
 		return
 	}
 
@@ -207,25 +504,11 @@ func UpdateBook(c *gin.Context) {
 	// Calculate thickness
 	thickness := input.CalculateThickness()
 
-	result, err := config.DB.Exec(`
-		UPDATE books 
-		SET title = $1, description = $2, image_url = $3, release_year = $4, 
-		    price = $5, total_page = $6, thickness = $7, category_id = $8,
-		    modified_at = $9, modified_by = $10
-		WHERE id = $11
-	`,
-		input.Title,
-		input.Description,
-		input.ImageURL,
-		input.ReleaseYear,
-		input.Price,
-		input.TotalPage,
-		thickness,
-		input.CategoryID,
-		time.Now(),
-		usernameStr,
-		id,
-	)
+	version, err := updateBookRow(config.DB, id, input, thickness, usernameStr, versions)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", id, "Book not found")
+		return
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -234,14 +517,8 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Book not found",
-		})
-		return
-	}
-
+	wakeImageMirror()
+	c.Header("ETag", formatETag(version))
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Book updated successfully",
 		"thickness": thickness,
@@ -258,7 +535,19 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
-	result, err := config.DB.Exec("DELETE FROM books WHERE id = $1", id)
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := deleteBookRow(config.DB, id, versions)
+	if err == errBookHasCopies {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Book has library copies; delete or withdraw them first",
+		})
+		return
+	}
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete book",
@@ -266,11 +555,8 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Book not found",
-		})
+		preconditionFailed(c, "books", id, "Book not found")
 		return
 	}
 
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// BatchBooks executes many book create, update and delete operations in one
+// request. In atomic mode every operation runs in a single transaction and
+// any failure rolls back the whole batch; in partial mode each operation is
+// applied independently.
+func BatchBooks(c *gin.Context) {
+	var input models.BookBatchInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = models.BatchModeAtomic
+	}
+
+	results := make([]models.BookOperationResult, len(input.Operations))
+	invalid := validateBookOperations(input.Operations, results)
+
+	// Validate every referenced category in one query
+	categoryIDs := make([]int64, 0, len(input.Operations))
+	for i, op := range input.Operations {
+		if results[i].Error == "" && op.Data != nil {
+			categoryIDs = append(categoryIDs, int64(op.Data.CategoryID))
+		}
+	}
+
+	existing, err := existingCategoryIDs(config.DB, categoryIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to validate categories",
+		})
+		return
+	}
+
+	for i, op := range input.Operations {
+		if results[i].Error == "" && op.Data != nil && !existing[op.Data.CategoryID] {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "Invalid category ID - category does not exist"
+			invalid++
+		}
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	if input.Mode == models.BatchModeAtomic {
+		if invalid > 0 {
+			skipPending(results, "Not executed - batch rejected")
+			respondBatch(c, input.Mode, results)
+			return
+		}
+
+		tx, err := config.DB.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to start transaction",
+			})
+			return
+		}
+
+		for i, op := range input.Operations {
+			runBookOperation(tx, op, usernameStr, &results[i])
+			if results[i].Error != "" {
+				tx.Rollback()
+				rollBackResults(results, i)
+				respondBatch(c, input.Mode, results)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to commit batch",
+			})
+			return
+		}
+
+		wakeImageMirror()
+		respondBatch(c, input.Mode, results)
+		return
+	}
+
+	for i, op := range input.Operations {
+		if results[i].Error == "" {
+			runBookOperation(config.DB, op, usernameStr, &results[i])
+		}
+	}
+
+	wakeImageMirror()
+	respondBatch(c, input.Mode, results)
+}
+
+// validateBookOperations checks the shape and binding rules of each operation,
+// filling in results for the invalid ones. It returns the number of invalid
+// operations.
+func validateBookOperations(ops []models.BookOperation, results []models.BookOperationResult) int {
+	invalid := 0
+	for i, op := range ops {
+		results[i].Index = i
+		results[i].Op = op.Op
+		results[i].ID = op.ID
+
+		var msg string
+		status := http.StatusBadRequest
+		switch op.Op {
+		case models.BatchOpCreate:
+			if op.Data == nil {
+				msg = "data is required for create"
+			}
+		case models.BatchOpUpdate:
+			if op.ID <= 0 {
+				msg = "id is required for update"
+			} else if op.Data == nil {
+				msg = "data is required for update"
+			} else if op.Version == nil {
+				msg, status = "version is required for update", http.StatusPreconditionRequired
+			}
+		case models.BatchOpDelete:
+			if op.ID <= 0 {
+				msg = "id is required for delete"
+			} else if op.Version == nil {
+				msg, status = "version is required for delete", http.StatusPreconditionRequired
+			}
+		default:
+			msg = "op must be one of create, update, delete"
+		}
+
+		if msg == "" && op.Data != nil && op.Op != models.BatchOpDelete {
+			if err := validateInput(op.Data); err != nil {
+				msg = err.Error()
+				if errs, ok := err.(models.ValidationErrors); ok {
+					msg = "Validation failed"
+					results[i].Errors = errs
+				}
+			}
+		}
+
+		if msg != "" {
+			results[i].Status = status
+			results[i].Error = msg
+			invalid++
+		}
+	}
+	return invalid
+}
+
+// existingCategoryIDs returns the subset of ids that exist in categories
+func existingCategoryIDs(q queryer, ids []int64) (map[int]bool, error) {
+	existing := make(map[int]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	rows, err := q.Query("SELECT id FROM categories WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
+
+// runBookOperation executes a single validated operation and records its result
+func runBookOperation(q queryer, op models.BookOperation, username string, result *models.BookOperationResult) {
+	var versions []int64
+	if op.Version != nil {
+		versions = []int64{int64(*op.Version)}
+	}
+
+	switch op.Op {
+	case models.BatchOpCreate:
+		thickness := op.Data.CalculateThickness()
+		id, version, err := insertBook(q, *op.Data, thickness, username)
+		if err != nil {
+			result.Status = http.StatusInternalServerError
+			result.Error = "Failed to create book"
+			return
+		}
+		result.ID = id
+		result.Status = http.StatusCreated
+		result.Version = version
+		result.Thickness = thickness
+
+	case models.BatchOpUpdate:
+		thickness := op.Data.CalculateThickness()
+		version, err := updateBookRow(q, op.ID, *op.Data, thickness, username, versions)
+		if err == sql.ErrNoRows {
+			missingOrStale(q, op.ID, result)
+			return
+		}
+		if err != nil {
+			result.Status = http.StatusInternalServerError
+			result.Error = "Failed to update book"
+			return
+		}
+		result.Status = http.StatusOK
+		result.Version = version
+		result.Thickness = thickness
+
+	case models.BatchOpDelete:
+		rowsAffected, err := deleteBookRow(q, op.ID, versions)
+		if err == errBookHasCopies {
+			result.Status = http.StatusConflict
+			result.Error = "Book has library copies; delete or withdraw them first"
+			return
+		}
+		if err != nil {
+			result.Status = http.StatusInternalServerError
+			result.Error = "Failed to delete book"
+			return
+		}
+		if rowsAffected == 0 {
+			missingOrStale(q, op.ID, result)
+			return
+		}
+		result.Status = http.StatusOK
+	}
+}
+
+// missingOrStale records why a conditional write on a book matched no rows
+func missingOrStale(q queryer, id int, result *models.BookOperationResult) {
+	var version int
+	err := q.QueryRow("SELECT version FROM books WHERE id = $1", id).Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		result.Status = http.StatusNotFound
+		result.Error = "Book not found"
+	case err != nil:
+		result.Status = http.StatusInternalServerError
+		result.Error = "Failed to check current version"
+	default:
+		result.Status = http.StatusPreconditionFailed
+		result.Version = version
+		result.Error = "Resource has been modified by another request"
+	}
+}
+
+// skipPending marks every operation without a result as not executed
+func skipPending(results []models.BookOperationResult, reason string) {
+	for i := range results {
+		if results[i].Error == "" {
+			results[i].Status = http.StatusFailedDependency
+			results[i].Error = reason
+		}
+	}
+}
+
+// rollBackResults marks every operation except the failed one as rolled back
+func rollBackResults(results []models.BookOperationResult, failed int) {
+	for i := range results {
+		if i == failed {
+			continue
+		}
+		results[i].Status = http.StatusFailedDependency
+		results[i].Version = 0
+		results[i].Thickness = ""
+		if i < failed {
+			results[i].Error = "Rolled back - batch failed"
+		} else {
+			results[i].Error = "Not executed - batch failed"
+		}
+		if results[i].Op == models.BatchOpCreate {
+			results[i].ID = 0
+		}
+	}
+}
+
+// respondBatch writes the per-item results together with a summary
+func respondBatch(c *gin.Context, mode string, results []models.BookOperationResult) {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+		if mode == models.BatchModeAtomic {
+			status = http.StatusUnprocessableEntity
+		}
+	}
+
+	c.JSON(status, gin.H{
+		"mode":      mode,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
+}
@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/marc"
+	"book-management/models"
+	"book-management/money"
+	"book-management/onix"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportBatchSize is the number of rows fetched from the cursor at a time
+const exportBatchSize = 500
+
+// exportColumns is the header row of CSV and XLSX exports
+var exportColumns = []string{
+	"id", "title", "isbn", "authors", "publisher", "tags", "description", "image_url", "release_year",
+	"price", "currency", "total_page", "thickness", "category_id", "category_name",
+	"created_at", "created_by", "modified_at", "modified_by",
+}
+
+// exportedBook is a book with its category name joined in
+type exportedBook struct {
+	models.Book
+	CategoryName string `json:"category_name"`
+}
+
+// bookExporter writes books in one export format
+type bookExporter interface {
+	Write(book exportedBook) error
+	// Flush is called after every cursor batch
+	Flush() error
+	Close() error
+}
+
+// ExportBooks streams the catalogue as CSV, NDJSON, XLSX, binary MARC 21,
+// MARCXML or ONIX 3.0. It accepts the same filters as GetAllBooks; an ONIX
+// delta feed is an export with modified_since set.
+func ExportBooks(c *gin.Context) {
+	filter, err := parseBookFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case "marc":
+		contentType = "application/marc"
+	case "marcxml":
+		contentType = "application/marcxml+xml"
+	case "onix":
+		contentType = "application/xml"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be one of csv, ndjson, xlsx, marc, marcxml, onix",
+		})
+		return
+	}
+
+	// A server-side cursor only lives inside a transaction
+	ctx := c.Request.Context()
+	tx, err := config.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start export",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// An ONIX delta feed also notifies the receiver of deleted books
+	var deleted []onix.Product
+	if format == "onix" && !filter.ModifiedSince.IsZero() {
+		deleted, err = deletedProducts(tx, filter.ModifiedSince)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch deleted books",
+			})
+			return
+		}
+	}
+
+	// Only MARC exports rebuild records from the stored source record
+	rawColumn := "NULL::jsonb"
+	if format == "marc" || format == "marcxml" {
+		rawColumn = "raw_metadata"
+	}
+
+	where, args := filter.where()
+	_, err = tx.ExecContext(ctx, `
+		DECLARE book_export NO SCROLL CURSOR FOR
+		SELECT `+bookColumns+`,
+		       COALESCE((SELECT name FROM categories WHERE categories.id = books.category_id), ''),
+		       `+rawColumn+`
+		FROM books
+		`+where+`
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch books",
+		})
+		return
+	}
+
+	extension := format
+	switch format {
+	case "marc":
+		extension = "mrc"
+	case "marcxml", "onix":
+		extension = "xml"
+	}
+
+	filename := fmt.Sprintf("books-%s.%s", time.Now().Format("20060102"), extension)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	var exporter bookExporter
+	switch format {
+	case "csv":
+		exporter = newCSVExporter(c.Writer)
+	case "ndjson":
+		exporter = newNDJSONExporter(c.Writer)
+	case "xlsx":
+		exporter, err = newXLSXExporter(c.Writer)
+	case "marc":
+		exporter = newMARCExporter(c.Writer)
+	case "marcxml":
+		exporter, err = newMARCXMLExporter(c.Writer)
+	case "onix":
+		exporter, err = newONIXExporter(c.Writer, deleted)
+	}
+
+	if err == nil {
+		err = streamExport(ctx, tx, exporter, format == "onix")
+	}
+
+	// Headers are already sent, so failures can only be logged
+	if err != nil {
+		log.Println("Book export failed:", err)
+		c.Abort()
+	}
+}
+
+// streamExport fetches the book_export cursor in batches and writes each
+// row. With withPrices the market prices of each batch are loaded as well.
+func streamExport(ctx context.Context, tx *sql.Tx, exporter bookExporter, withPrices bool) error {
+	for {
+		rows, err := tx.QueryContext(ctx, "FETCH "+strconv.Itoa(exportBatchSize)+" FROM book_export")
+		if err != nil {
+			return err
+		}
+
+		var batch []exportedBook
+		for rows.Next() {
+			var book exportedBook
+			var rawMetadata []byte
+			if err := scanBook(rows, &book.Book, &book.CategoryName, &rawMetadata); err != nil {
+				rows.Close()
+				return err
+			}
+			if len(rawMetadata) > 0 {
+				book.RawMetadata = rawMetadata
+			}
+			batch = append(batch, book)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		fetched := len(batch)
+
+		if withPrices && fetched > 0 {
+			ids := make([]int64, fetched)
+			for i, book := range batch {
+				ids[i] = int64(book.ID)
+			}
+			prices, err := loadBookPrices(tx, ids)
+			if err != nil {
+				return err
+			}
+			for i := range batch {
+				batch[i].Prices = prices[batch[i].ID]
+			}
+		}
+
+		for _, book := range batch {
+			if err := exporter.Write(book); err != nil {
+				return err
+			}
+		}
+
+		if err := exporter.Flush(); err != nil {
+			return err
+		}
+
+		if fetched < exportBatchSize {
+			return exporter.Close()
+		}
+	}
+}
+
+// formulaPrefixes start a formula when a spreadsheet opens a cell
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text that a spreadsheet would run as a formula with
+// a quote, so that exported user input cannot inject formulas
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// exportRecord converts a book into the cells of one CSV/XLSX row
+func exportRecord(book exportedBook) []string {
+	return []string{
+		strconv.Itoa(book.ID),
+		escapeFormula(book.Title),
+		escapeFormula(book.ISBN),
+		escapeFormula(strings.Join(book.Authors, "; ")),
+		escapeFormula(book.Publisher),
+		escapeFormula(strings.Join(book.Tags, "; ")),
+		escapeFormula(book.Description),
+		escapeFormula(book.ImageURL),
+		strconv.Itoa(book.ReleaseYear),
+		money.Format(book.PriceMinor, book.Currency),
+		book.Currency,
+		strconv.Itoa(book.TotalPage),
+		escapeFormula(book.Thickness),
+		strconv.Itoa(book.CategoryID),
+		escapeFormula(book.CategoryName),
+		book.CreatedAt.Format(time.RFC3339),
+		escapeFormula(book.CreatedBy),
+		book.ModifiedAt.Format(time.RFC3339),
+		escapeFormula(book.ModifiedBy),
+	}
+}
+
+type csvExporter struct {
+	w             *csv.Writer
+	flusher       http.Flusher
+	headerWritten bool
+}
+
+func newCSVExporter(w gin.ResponseWriter) *csvExporter {
+	return &csvExporter{w: csv.NewWriter(w), flusher: w}
+}
+
+func (e *csvExporter) Write(book exportedBook) error {
+	if !e.headerWritten {
+		e.headerWritten = true
+		if err := e.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(exportRecord(book))
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	e.flusher.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	// An empty export still gets a header row
+	if !e.headerWritten {
+		e.headerWritten = true
+		if err := e.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
+
+type ndjsonExporter struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func newNDJSONExporter(w gin.ResponseWriter) *ndjsonExporter {
+	return &ndjsonExporter{enc: json.NewEncoder(w), flusher: w}
+}
+
+func (e *ndjsonExporter) Write(book exportedBook) error {
+	return e.enc.Encode(book)
+}
+
+func (e *ndjsonExporter) Flush() error {
+	e.flusher.Flush()
+	return nil
+}
+
+func (e *ndjsonExporter) Close() error {
+	return e.Flush()
+}
+
+// xlsxExporter uses excelize's stream writer, which spills rows to a
+// temporary file instead of keeping the whole sheet in memory
+type xlsxExporter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxExporter{out: w, file: file, stream: stream, row: 1}, nil
+}
+
+func (e *xlsxExporter) Write(book exportedBook) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+
+	return e.stream.SetRow(cell, []interface{}{
+		book.ID, escapeFormula(book.Title), escapeFormula(book.ISBN),
+		escapeFormula(strings.Join(book.Authors, "; ")), escapeFormula(book.Publisher),
+		escapeFormula(strings.Join(book.Tags, "; ")), escapeFormula(book.Description),
+		escapeFormula(book.ImageURL), book.ReleaseYear, money.Format(book.PriceMinor, book.Currency), book.Currency, book.TotalPage,
+		escapeFormula(book.Thickness), book.CategoryID, escapeFormula(book.CategoryName),
+		book.CreatedAt.Format(time.RFC3339), escapeFormula(book.CreatedBy),
+		book.ModifiedAt.Format(time.RFC3339), escapeFormula(book.ModifiedBy),
+	})
+}
+
+func (e *xlsxExporter) Flush() error {
+	return nil
+}
+
+func (e *xlsxExporter) Close() error {
+	defer e.file.Close()
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.out)
+}
+
+type marcExporter struct {
+	w       gin.ResponseWriter
+	flusher http.Flusher
+}
+
+func newMARCExporter(w gin.ResponseWriter) *marcExporter {
+	return &marcExporter{w: w, flusher: w}
+}
+
+func (e *marcExporter) Write(book exportedBook) error {
+	return marc.WriteBinary(e.w, marc.FromBook(book.Book))
+}
+
+func (e *marcExporter) Flush() error {
+	e.flusher.Flush()
+	return nil
+}
+
+func (e *marcExporter) Close() error {
+	return e.Flush()
+}
+
+type marcXMLExporter struct {
+	xml     *marc.XMLWriter
+	flusher http.Flusher
+}
+
+func newMARCXMLExporter(w gin.ResponseWriter) (*marcXMLExporter, error) {
+	writer, err := marc.NewXMLWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &marcXMLExporter{xml: writer, flusher: w}, nil
+}
+
+func (e *marcXMLExporter) Write(book exportedBook) error {
+	return e.xml.Write(marc.FromBook(book.Book))
+}
+
+func (e *marcXMLExporter) Flush() error {
+	if err := e.xml.Flush(); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+func (e *marcXMLExporter) Close() error {
+	if err := e.xml.Close(); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+// deletedProducts builds delete notifications for the books deleted after
+// since
+func deletedProducts(q queryer, since time.Time) ([]onix.Product, error) {
+	rows, err := q.Query(`
+		SELECT book_id, isbn FROM book_deletions
+		WHERE deleted_at > $1
+		ORDER BY book_id
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []onix.Product
+	for rows.Next() {
+		var id int
+		var isbn string
+		if err := rows.Scan(&id, &isbn); err != nil {
+			return nil, err
+		}
+		products = append(products, onix.DeletedProduct(id, isbn))
+	}
+	return products, rows.Err()
+}
+
+type onixExporter struct {
+	onix     *onix.Writer
+	flusher  http.Flusher
+	supplier string
+	// deleted are written after the books
+	deleted []onix.Product
+}
+
+func newONIXExporter(w gin.ResponseWriter, deleted []onix.Product) (*onixExporter, error) {
+	supplier := os.Getenv("ONIX_SENDER_NAME")
+	if supplier == "" {
+		supplier = "Book Management API"
+	}
+
+	writer, err := onix.NewWriter(w, supplier, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &onixExporter{onix: writer, flusher: w, supplier: supplier, deleted: deleted}, nil
+}
+
+func (e *onixExporter) Write(book exportedBook) error {
+	return e.onix.Write(onix.FromBook(onix.CategorizedBook{
+		Book:         book.Book,
+		CategoryName: book.CategoryName,
+	}, e.supplier))
+}
+
+func (e *onixExporter) Flush() error {
+	if err := e.onix.Flush(); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+func (e *onixExporter) Close() error {
+	for _, product := range e.deleted {
+		if err := e.onix.Write(product); err != nil {
+			return err
+		}
+	}
+	if err := e.onix.Close(); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
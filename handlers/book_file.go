@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/edition"
+	"book-management/models"
+	"book-management/signedurl"
+	"book-management/storage"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBookFileSize limits uploaded digital editions to 100 MB
+const maxBookFileSize = 100 << 20
+
+// Lifetime of signed download links
+const (
+	defaultFileLinkTTL = 15 * time.Minute
+	maxFileLinkTTL     = 24 * time.Hour
+)
+
+// bookFileColumns is the column list scanned by scanBookFile
+const bookFileColumns = `
+	id, book_id, format, filename, content_type, size, checksum_sha256,
+	created_at, created_by, metadata
+`
+
+// scanBookFile scans a row selected with bookFileColumns. Any extra
+// destinations are scanned from the columns that follow bookFileColumns.
+func scanBookFile(row rowScanner, file *models.BookFile, extra ...interface{}) error {
+	var metadata []byte
+	dest := []interface{}{
+		&file.ID,
+		&file.BookID,
+		&file.Format,
+		&file.Filename,
+		&file.ContentType,
+		&file.Size,
+		&file.ChecksumSHA256,
+		&file.CreatedAt,
+		&file.CreatedBy,
+		&metadata,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if len(metadata) > 0 {
+		file.Metadata = metadata
+	}
+	return nil
+}
+
+// detectEditionFormat recognises PDF and EPUB files from their first bytes
+func detectEditionFormat(head []byte) (format, contentType string, ok bool) {
+	if bytes.HasPrefix(head, []byte("%PDF-")) {
+		return models.FileFormatPDF, "application/pdf", true
+	}
+	// An EPUB is a ZIP whose first entry is an uncompressed "mimetype" file
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) && len(head) >= 58 &&
+		string(head[30:58]) == "mimetypeapplication/epub+zip" {
+		return models.FileFormatEPUB, "application/epub+zip", true
+	}
+	return "", "", false
+}
+
+// bookFileParams parses the :id and :fileId params. When either is invalid
+// a response is written and ok is false.
+func bookFileParams(c *gin.Context) (bookID, fileID int, ok bool) {
+	bookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return 0, 0, false
+	}
+
+	fileID, err = strconv.Atoi(c.Param("fileId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file ID",
+		})
+		return 0, 0, false
+	}
+	return bookID, fileID, true
+}
+
+// UploadBookFile attaches a PDF or EPUB edition to a book. The file is
+// stored in the blob store under its SHA-256 checksum.
+func UploadBookFile(c *gin.Context) {
+	bookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBookFileSize+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File is required",
+		})
+		return
+	}
+
+	if fileHeader.Size > maxBookFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "File must not exceed 100 MB",
+		})
+		return
+	}
+
+	var bookExists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", bookID).Scan(&bookExists)
+	if err != nil || !bookExists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	upload, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+	defer upload.Close()
+
+	head := make([]byte, 58)
+	n, _ := io.ReadFull(upload, head)
+	format, contentType, ok := detectEditionFormat(head[:n])
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "File must be a PDF or EPUB",
+		})
+		return
+	}
+
+	// Hash while spooling to a temporary file, since the key depends on
+	// the checksum
+	tmp, err := os.CreateTemp("", "book-file-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store file",
+		})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.MultiReader(bytes.NewReader(head[:n]), upload))
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store file",
+		})
+		return
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	key := "editions/" + strconv.Itoa(bookID) + "/" + checksum + "." + format
+	if err := config.Storage.Put(c.Request.Context(), key, tmp, size, contentType); err != nil {
+		log.Println("Book file upload failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store file",
+		})
+		return
+	}
+
+	// Extraction is best effort; a file we cannot parse is still attached
+	var metadata []byte
+	extracted, err := edition.Extract(tmp, size, format)
+	if err == nil {
+		metadata, _ = json.Marshal(extracted)
+	} else {
+		log.Println("Metadata extraction failed:", err)
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var file models.BookFile
+	err = scanBookFile(config.DB.QueryRow(`
+		INSERT INTO book_files (
+			book_id, format, filename, content_type, size, checksum_sha256,
+			storage_key, created_at, created_by, metadata
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (book_id, checksum_sha256) DO NOTHING
+		RETURNING `+bookFileColumns,
+		bookID, format, filepath.Base(fileHeader.Filename), contentType, size, checksum,
+		key, time.Now(), usernameStr, metadata,
+	), &file)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "This file is already attached to the book",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save file",
+		})
+		return
+	}
+
+	proposals := []models.BookProposal{}
+	if book, err := findBook(config.DB, bookID); err == nil {
+		proposals = proposeCorrections(book, extracted)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "File uploaded successfully",
+		"data":      file,
+		"proposals": proposals,
+	})
+}
+
+// GetBookFiles lists the digital editions of a book
+func GetBookFiles(c *gin.Context) {
+	bookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+bookFileColumns+`
+		FROM book_files
+		WHERE book_id = $1
+		ORDER BY id
+	`, bookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch files",
+		})
+		return
+	}
+	defer rows.Close()
+
+	files := []models.BookFile{}
+	for rows.Next() {
+		var file models.BookFile
+		if err := scanBookFile(rows, &file); err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": files,
+	})
+}
+
+// DeleteBookFile removes a digital edition and its stored file
+func DeleteBookFile(c *gin.Context) {
+	bookID, fileID, ok := bookFileParams(c)
+	if !ok {
+		return
+	}
+
+	var key string
+	err := config.DB.QueryRow(`
+		DELETE FROM book_files
+		WHERE id = $1 AND book_id = $2
+		RETURNING storage_key
+	`, fileID, bookID).Scan(&key)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete file",
+		})
+		return
+	}
+
+	err = config.Storage.Delete(c.Request.Context(), key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Println("Failed to delete stored book file:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File deleted successfully",
+	})
+}
+
+// CreateBookFileLink issues a signed download link for the current user.
+// expires_in (seconds) sets its lifetime, default 15 minutes, max 24 hours.
+func CreateBookFileLink(c *gin.Context) {
+	bookID, fileID, ok := bookFileParams(c)
+	if !ok {
+		return
+	}
+
+	ttl := defaultFileLinkTTL
+	if raw := c.Query("expires_in"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxFileLinkTTL {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "expires_in must be between 1 and 86400 seconds",
+			})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	var exists bool
+	err := config.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM book_files WHERE id = $1 AND book_id = $2)",
+		fileID, bookID,
+	).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	path := "/api/files/" + strconv.Itoa(fileID)
+	expires := time.Now().Add(ttl)
+	query := signedurl.Sign(config.FileURLSecret, path, usernameStr, expires)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        path + "?" + query.Encode(),
+		"expires_at": expires.UTC().Format(time.RFC3339),
+	})
+}
+
+// DownloadBookFile serves a file through a signed link. Range requests are
+// supported; a download is recorded for the link's user once per request
+// that starts at the beginning of the file.
+func DownloadBookFile(c *gin.Context) {
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	user, err := signedurl.Verify(config.FileURLSecret, c.Request.URL.Path, c.Request.URL.Query(), time.Now())
+	if errors.Is(err, signedurl.ErrExpired) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Download link has expired",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid download link",
+		})
+		return
+	}
+
+	var file models.BookFile
+	var key string
+	err = scanBookFile(config.DB.QueryRow(`
+		SELECT `+bookFileColumns+`, storage_key
+		FROM book_files
+		WHERE id = $1
+	`, fileID), &file, &key)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return
+	}
+
+	object, err := config.Storage.Get(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return
+	}
+	defer object.Body.Close()
+
+	rangeHeader := c.GetHeader("Range")
+	if c.Request.Method == http.MethodGet && (rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")) {
+		_, err := config.DB.Exec(`
+			INSERT INTO book_file_downloads (file_id, username, ip_address, downloaded_at)
+			VALUES ($1, $2, $3, $4)
+		`, file.ID, user, c.ClientIP(), time.Now())
+		if err != nil {
+			log.Println("Failed to record download:", err)
+		}
+	}
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(file.Filename, `"`, "")+`"`)
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("ETag", `"`+file.ChecksumSHA256+`"`)
+	http.ServeContent(c.Writer, c.Request, file.Filename, object.ModTime, object.Body)
+}
+
+// GetBookFileDownloads lists the recorded downloads of a file, newest first
+func GetBookFileDownloads(c *gin.Context) {
+	bookID, fileID, ok := bookFileParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT d.id, d.file_id, d.username, d.ip_address, d.downloaded_at
+		FROM book_file_downloads d
+		JOIN book_files f ON f.id = d.file_id
+		WHERE d.file_id = $1 AND f.book_id = $2
+		ORDER BY d.downloaded_at DESC
+	`, fileID, bookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch downloads",
+		})
+		return
+	}
+	defer rows.Close()
+
+	downloads := []models.FileDownload{}
+	for rows.Next() {
+		var download models.FileDownload
+		err := rows.Scan(&download.ID, &download.FileID, &download.Username, &download.IPAddress, &download.DownloadedAt)
+		if err != nil {
+			continue
+		}
+		downloads = append(downloads, download)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": downloads,
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bookFilter holds the optional query parameters shared by the book list
+// and export endpoints
+type bookFilter struct {
+	CategoryID int
+	Thickness  string
+	MinYear    int
+	MaxYear    int
+	Search     string
+	Tag        string
+	// ModifiedSince limits results to books changed after this time
+	ModifiedSince time.Time
+	// Sort is the order of the results: newest (default) or rating
+	Sort string
+}
+
+// parseBookFilter reads category_id, thickness, min_year, max_year, q, tag,
+// modified_since (RFC 3339) and sort from the query string
+func parseBookFilter(c *gin.Context) (bookFilter, error) {
+	var filter bookFilter
+
+	intParam := func(name string, dest *int) error {
+		raw := c.Query(name)
+		if raw == "" {
+			return nil
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", name)
+		}
+		*dest = value
+		return nil
+	}
+
+	if err := intParam("category_id", &filter.CategoryID); err != nil {
+		return filter, err
+	}
+	if err := intParam("min_year", &filter.MinYear); err != nil {
+		return filter, err
+	}
+	if err := intParam("max_year", &filter.MaxYear); err != nil {
+		return filter, err
+	}
+	if filter.MinYear != 0 && filter.MaxYear != 0 && filter.MinYear > filter.MaxYear {
+		return filter, errors.New("min_year must not be greater than max_year")
+	}
+
+	if raw := c.Query("modified_since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New("modified_since must be an RFC 3339 timestamp")
+		}
+		filter.ModifiedSince = since
+	}
+
+	switch filter.Sort = c.DefaultQuery("sort", "newest"); filter.Sort {
+	case "newest", "rating":
+	default:
+		return filter, errors.New("sort must be one of newest, rating")
+	}
+
+	filter.Thickness = strings.TrimSpace(c.Query("thickness"))
+	filter.Search = strings.TrimSpace(c.Query("q"))
+	filter.Tag = strings.ToLower(strings.TrimSpace(c.Query("tag")))
+	return filter, nil
+}
+
+// where builds the WHERE clause for the filter, numbering placeholders from
+// $1. The clause is empty when no filter is set.
+func (f bookFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.CategoryID != 0 {
+		add("category_id = $%d", f.CategoryID)
+	}
+	if f.Thickness != "" {
+		add("thickness = $%d", f.Thickness)
+	}
+	if f.MinYear != 0 {
+		add("release_year >= $%d", f.MinYear)
+	}
+	if f.MaxYear != 0 {
+		add("release_year <= $%d", f.MaxYear)
+	}
+	if f.Search != "" {
+		add("title ILIKE '%%' || $%d || '%%'", f.Search)
+	}
+	if f.Tag != "" {
+		add("tags @> ARRAY[$%d::text]", f.Tag)
+	}
+	if !f.ModifiedSince.IsZero() {
+		add("modified_at > $%d", f.ModifiedSince)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderBy is the ORDER BY clause for the filter's sort. Books rated the
+// same are ordered by the number of ratings, then newest first.
+func (f bookFilter) orderBy() string {
+	if f.Sort == "rating" {
+		return "rating_average DESC, rating_count DESC, id DESC"
+	}
+	return "id DESC"
+}
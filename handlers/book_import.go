@@ -0,0 +1,687 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"book-management/money"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxImportSize limits uploaded spreadsheets to 10 MB
+const maxImportSize = 10 << 20
+
+// maxImportRows limits the rows of an import, header included.
+// maxXLSXUnzipSize and maxXLSXSheetSize bound how far an XLSX, which is a
+// zip archive, may expand in total and per sheet kept in memory.
+const (
+	maxImportRows    = 10000
+	maxXLSXUnzipSize = 100 << 20
+	maxXLSXSheetSize = 20 << 20
+)
+
+var errTooManyRows = fmt.Errorf("file has more than %d rows", maxImportRows)
+
+// Import row actions
+const (
+	importCreate = "create"
+	importUpdate = "update"
+	importReject = "reject"
+)
+
+// importFields are the book fields that can be mapped to spreadsheet columns.
+// "category" holds a category name and is resolved to category_id; "authors"
+// and "tags" hold values separated by semicolons. "price" is a decimal amount
+// in "currency", which defaults to money.BaseCurrency.
+var importFields = []string{
+	"title", "isbn", "authors", "publisher", "tags", "description", "image_url",
+	"release_year", "price", "currency", "total_page", "category", "category_id",
+}
+
+// splitList splits an authors or tags cell on semicolons
+func splitList(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, ";") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// importRow is the planned outcome of one imported row or record
+type importRow struct {
+	Row         int              `json:"row"`
+	Action      string           `json:"action"`
+	BookID      int              `json:"book_id,omitempty"`
+	Category    string           `json:"category,omitempty"`
+	Data        models.BookInput `json:"data"`
+	Errors      []string         `json:"errors,omitempty"`
+	version     int
+	newCategory string
+	// provided holds the fields the source gave a value for, named as in
+	// importFields with category_id for the category. An update keeps the
+	// book's other fields.
+	provided map[string]bool
+	// rawMetadata is merged into books.raw_metadata when the row is committed
+	rawMetadata json.RawMessage
+}
+
+// ImportBooks imports books from an uploaded CSV or XLSX file.
+//
+// Form fields:
+//   - file: the .csv or .xlsx file (first row is the header)
+//   - mapping: optional JSON object of book field -> column header
+//   - sheet: optional XLSX sheet name (defaults to the first sheet)
+//   - create_categories: create categories that do not exist yet
+//   - dry_run: only report what would be created, updated or rejected
+//
+// Rows are matched to existing books by ISBN, falling back to title and
+// release year.
+func ImportBooks(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File is required",
+		})
+		return
+	}
+
+	mapping := make(map[string]string)
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid mapping - expected a JSON object of field to column",
+			})
+			return
+		}
+	}
+
+	createCategories := c.PostForm("create_categories") == "true"
+	dryRun := c.PostForm("dry_run") == "true"
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+	defer file.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv":
+		records, err = readCSV(file)
+	case ".xlsx":
+		records, err = readXLSX(file, c.PostForm("sheet"))
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Only .csv and .xlsx files are supported",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to parse file: " + err.Error(),
+		})
+		return
+	}
+
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File must contain a header row and at least one data row",
+		})
+		return
+	}
+
+	columns, err := mapColumns(records[0], mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	categories, err := categoryIDsByName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+
+	rows := make([]importRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		rows = append(rows, parseImportRow(i+2, record, columns, categories, createCategories))
+	}
+
+	if err := matchImportRows(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to match existing books",
+		})
+		return
+	}
+
+	newCategories := plannedCategories(rows)
+
+	if dryRun {
+		respondImport(c, true, rows, newCategories)
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	if err := commitImport(rows, newCategories, usernameStr); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Import failed, no changes were made: " + err.Error(),
+		})
+		return
+	}
+
+	respondImport(c, false, rows, newCategories)
+}
+
+// readCSV reads every record of a CSV file, up to maxImportRows
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == maxImportRows {
+			return nil, errTooManyRows
+		}
+		records = append(records, record)
+	}
+}
+
+// readXLSX reads every row of a sheet (the first sheet when name is empty),
+// up to maxImportRows. Rows are streamed rather than loaded all at once.
+func readXLSX(r io.Reader, sheet string) ([][]string, error) {
+	workbook, err := excelize.OpenReader(r, excelize.Options{
+		UnzipSizeLimit:    maxXLSXUnzipSize,
+		UnzipXMLSizeLimit: maxXLSXSheetSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer workbook.Close()
+
+	if sheet == "" {
+		sheet = workbook.GetSheetName(0)
+	}
+	sheetRows, err := workbook.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer sheetRows.Close()
+
+	var records [][]string
+	for sheetRows.Next() {
+		if len(records) == maxImportRows {
+			return nil, errTooManyRows
+		}
+		record, err := sheetRows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := sheetRows.Error(); err != nil {
+		return nil, err
+	}
+
+	// Like GetRows, drop the empty rows at the end of the sheet
+	for len(records) > 0 && len(records[len(records)-1]) == 0 {
+		records = records[:len(records)-1]
+	}
+	return records, nil
+}
+
+// mapColumns resolves each import field to a column index. Fields without an
+// explicit mapping use the column whose header equals the field name.
+func mapColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range importFields {
+		column, mapped := mapping[field]
+		if !mapped {
+			column = field
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(column))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("column %q mapped to %s not found in header", column, field)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("no column mapped to title")
+	}
+	return columns, nil
+}
+
+// categoryIDsByName returns existing category IDs keyed by lower-cased name
+func categoryIDsByName() (map[string]int, error) {
+	rows, err := config.DB.Query("SELECT id, name FROM categories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		categories[strings.ToLower(strings.TrimSpace(name))] = id
+	}
+	return categories, rows.Err()
+}
+
+// unescapeFormula removes the quote an export puts before text a
+// spreadsheet would run as a formula, so exported files import unchanged
+func unescapeFormula(text string) string {
+	if len(text) > 1 && text[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(text[1])) {
+		return text[1:]
+	}
+	return text
+}
+
+// parseImportRow converts a record into a BookInput and validates it
+func parseImportRow(rowNumber int, record []string, columns map[string]int, categories map[string]int, createCategories bool) importRow {
+	row := importRow{Row: rowNumber, Action: importCreate}
+
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return unescapeFormula(strings.TrimSpace(record[i]))
+	}
+
+	number := func(field string) int {
+		raw := value(field)
+		if raw == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s must be a whole number, got %q", field, raw))
+		}
+		return n
+	}
+
+	currency := models.NormalizeCurrency(value("currency"))
+	var price int64
+	if raw := value("price"); raw != "" {
+		var err error
+		if price, err = money.Parse(raw, currency); err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("price: %v", err))
+		}
+	}
+
+	row.Data = models.BookInput{
+		Title:       value("title"),
+		ISBN:        models.NormalizeISBN(value("isbn")),
+		Authors:     splitList(value("authors")),
+		Publisher:   value("publisher"),
+		Tags:        splitList(value("tags")),
+		Description: value("description"),
+		ImageURL:    value("image_url"),
+		ReleaseYear: number("release_year"),
+		PriceMinor:  price,
+		Currency:    currency,
+		TotalPage:   number("total_page"),
+		CategoryID:  number("category_id"),
+	}
+
+	row.provided = make(map[string]bool)
+	for _, field := range importFields {
+		if value(field) != "" {
+			row.provided[field] = true
+		}
+	}
+	if row.provided["category"] {
+		row.provided["category_id"] = true
+	}
+
+	// Resolve category name to category_id
+	if name := value("category"); name != "" && row.Data.CategoryID == 0 {
+		row.Category = name
+		if id, ok := categories[strings.ToLower(name)]; ok {
+			row.Data.CategoryID = id
+		} else if createCategories {
+			row.newCategory = name
+		} else {
+			row.Errors = append(row.Errors, fmt.Sprintf("category %q does not exist", name))
+		}
+	}
+
+	row.Errors = append(row.Errors, row.validationErrors()...)
+
+	if row.Data.CategoryID > 0 && !categoryKnown(categories, row.Data.CategoryID) {
+		row.Errors = append(row.Errors, "Invalid category ID - category does not exist")
+	}
+
+	if len(row.Errors) > 0 {
+		row.Action = importReject
+	}
+	return row
+}
+
+// validationErrors checks row.Data. A category that will be created still
+// needs to pass the required rule, so it counts as set.
+func (row *importRow) validationErrors() []string {
+	validated := row.Data
+	if row.newCategory != "" {
+		validated.CategoryID = -1
+	}
+	if err := validateInput(&validated); err != nil {
+		return errorMessages(err)
+	}
+	return nil
+}
+
+// providedFields marks the fields of input that hold a value, for sources
+// that leave missing fields zero
+func providedFields(input models.BookInput) map[string]bool {
+	return map[string]bool{
+		"title":        input.Title != "",
+		"isbn":         input.ISBN != "",
+		"authors":      len(input.Authors) > 0,
+		"publisher":    input.Publisher != "",
+		"tags":         len(input.Tags) > 0,
+		"description":  input.Description != "",
+		"image_url":    input.ImageURL != "",
+		"release_year": input.ReleaseYear != 0,
+		"price":        input.PriceMinor != 0,
+		"total_page":   input.TotalPage != 0,
+		"category_id":  input.CategoryID != 0,
+	}
+}
+
+// mergeProvided returns existing with the fields the row provided taken from
+// the row, so that an update never clears data the source did not carry
+func (row *importRow) mergeProvided(existing models.BookInput) models.BookInput {
+	merged := existing
+	for field, ok := range row.provided {
+		if !ok {
+			continue
+		}
+		switch field {
+		case "title":
+			merged.Title = row.Data.Title
+		case "isbn":
+			merged.ISBN = row.Data.ISBN
+		case "authors":
+			merged.Authors = row.Data.Authors
+		case "publisher":
+			merged.Publisher = row.Data.Publisher
+		case "tags":
+			merged.Tags = row.Data.Tags
+		case "description":
+			merged.Description = row.Data.Description
+		case "image_url":
+			merged.ImageURL = row.Data.ImageURL
+		case "release_year":
+			merged.ReleaseYear = row.Data.ReleaseYear
+		case "price":
+			// A price is only meaningful together with its currency
+			merged.PriceMinor = row.Data.PriceMinor
+			merged.Currency = row.Data.Currency
+		case "total_page":
+			merged.TotalPage = row.Data.TotalPage
+		case "category_id":
+			merged.CategoryID = row.Data.CategoryID
+		}
+	}
+	return merged
+}
+
+// categoryKnown reports whether id is one of the existing categories
+func categoryKnown(categories map[string]int, id int) bool {
+	for _, known := range categories {
+		if known == id {
+			return true
+		}
+	}
+	return false
+}
+
+// matchImportRows marks valid rows that match an existing book as updates.
+// Rows are matched by ISBN when present, then by title and release year.
+// A book matched by more than one row rejects the later rows. An update
+// starts from the matched book and only changes the fields the row provided.
+func matchImportRows(rows []importRow) error {
+	var isbns, titles []string
+	var years []int64
+	for _, row := range rows {
+		if row.Action == importReject {
+			continue
+		}
+		if row.Data.ISBN != "" {
+			isbns = append(isbns, row.Data.ISBN)
+		}
+		titles = append(titles, strings.ToLower(row.Data.Title))
+		years = append(years, int64(row.Data.ReleaseYear))
+	}
+
+	byISBN := make(map[string]models.Book)
+	byTitle := make(map[string]models.Book)
+
+	dbRows, err := config.DB.Query(`
+		SELECT `+bookColumns+`
+		FROM books
+		WHERE isbn = ANY($1)
+		   OR (lower(title), release_year) IN (
+		       SELECT * FROM unnest($2::text[], $3::int[])
+		   )
+	`, pq.Array(isbns), pq.Array(titles), pq.Array(years))
+	if err != nil {
+		return err
+	}
+	defer dbRows.Close()
+
+	for dbRows.Next() {
+		var book models.Book
+		if err := scanBook(dbRows, &book); err != nil {
+			return err
+		}
+		if book.ISBN != "" {
+			byISBN[book.ISBN] = book
+		}
+		titleKey := fmt.Sprintf("%s|%d", strings.ToLower(book.Title), book.ReleaseYear)
+		if _, taken := byTitle[titleKey]; taken {
+			continue
+		}
+		byTitle[titleKey] = book
+	}
+	if err := dbRows.Err(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	seenBook := make(map[int]int)
+	for i := range rows {
+		row := &rows[i]
+		if row.Action == importReject {
+			continue
+		}
+
+		titleKey := fmt.Sprintf("%s|%d", strings.ToLower(row.Data.Title), row.Data.ReleaseYear)
+		key := "title:" + titleKey
+		if row.Data.ISBN != "" {
+			key = "isbn:" + row.Data.ISBN
+		}
+
+		// Fall back to title and year for books stored without an ISBN
+		m, found := byISBN[row.Data.ISBN]
+		if !found {
+			m, found = byTitle[titleKey]
+		}
+
+		if first, dup := seen[key]; dup {
+			row.Action = importReject
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate of row %d", first))
+			continue
+		}
+		seen[key] = row.Row
+
+		if first, dup := seenBook[m.ID]; found && dup {
+			row.Action = importReject
+			row.Errors = append(row.Errors, fmt.Sprintf("matches the same book as row %d", first))
+			continue
+		}
+
+		if found {
+			seenBook[m.ID] = row.Row
+			row.Action = importUpdate
+			row.BookID = m.ID
+			row.version = m.Version
+			row.Data = row.mergeProvided(m.Input())
+
+			// The stored fields the row keeps must pass the current rules too
+			if errs := row.validationErrors(); len(errs) > 0 {
+				row.Action = importReject
+				row.Errors = append(row.Errors, errs...)
+			}
+		}
+	}
+	return nil
+}
+
+// plannedCategories lists the distinct categories accepted rows will create
+func plannedCategories(rows []importRow) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if row.Action == importReject || row.newCategory == "" {
+			continue
+		}
+		key := strings.ToLower(row.newCategory)
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, row.newCategory)
+		}
+	}
+	return names
+}
+
+// commitImport creates missing categories and writes every accepted row in a
+// single transaction
+func commitImport(rows []importRow, newCategories []string, username string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	created := make(map[string]int)
+	for _, name := range newCategories {
+		var id int
+		err := tx.QueryRow(`
+			INSERT INTO categories (name, created_at, created_by, modified_at, modified_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, name, time.Now(), username, time.Now(), username).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to create category %q", name)
+		}
+		created[strings.ToLower(name)] = id
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if row.newCategory != "" {
+			row.Data.CategoryID = created[strings.ToLower(row.newCategory)]
+		}
+
+		thickness := row.Data.CalculateThickness()
+		switch row.Action {
+		case importCreate:
+			id, _, err := insertBook(tx, row.Data, thickness, username)
+			if err != nil {
+				return fmt.Errorf("row %d: failed to create book", row.Row)
+			}
+			row.BookID = id
+		case importUpdate:
+			_, err := updateBookRow(tx, row.BookID, row.Data, thickness, username, []int64{int64(row.version)})
+			if err != nil {
+				return fmt.Errorf("row %d: book %d was modified during import", row.Row, row.BookID)
+			}
+		default:
+			continue
+		}
+
+		if row.rawMetadata != nil {
+			_, err := tx.Exec(`
+				UPDATE books
+				SET raw_metadata = COALESCE(raw_metadata, '{}'::jsonb) || $1::jsonb
+				WHERE id = $2
+			`, []byte(row.rawMetadata), row.BookID)
+			if err != nil {
+				return fmt.Errorf("row %d: failed to store raw metadata", row.Row)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	wakeImageMirror()
+	return nil
+}
+
+// respondImport writes the per-row report of an import
+func respondImport(c *gin.Context, dryRun bool, rows []importRow, newCategories []string) {
+	summary := map[string]int{importCreate: 0, importUpdate: 0, importReject: 0}
+	for _, row := range rows {
+		summary[row.Action]++
+	}
+
+	message := "Import completed successfully"
+	if dryRun {
+		message = "Dry run - no changes were made"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        message,
+		"dry_run":        dryRun,
+		"summary":        summary,
+		"new_categories": newCategories,
+		"rows":           rows,
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/marc"
+	"book-management/money"
+	"bufio"
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportMARC imports books from an uploaded binary MARC 21 or MARCXML file.
+//
+// Form fields:
+//   - file: the .mrc or MARCXML file
+//   - category_id: category assigned to every imported book
+//   - price: price in money.BaseCurrency used when a record has no 365 $b
+//   - dry_run: only report what would be created, updated or rejected
+//
+// Each source record is kept in raw_metadata so that fields we do not map
+// survive a later MARC export.
+func ImportMARC(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File is required",
+		})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.PostForm("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "category_id is required",
+		})
+		return
+	}
+
+	var defaultPrice int64
+	if raw := c.PostForm("price"); raw != "" {
+		if defaultPrice, err = money.Parse(raw, money.BaseCurrency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "price: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	dryRun := c.PostForm("dry_run") == "true"
+
+	var categoryExists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)", categoryID).Scan(&categoryExists)
+	if err != nil || !categoryExists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid category ID - category does not exist",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+	defer file.Close()
+
+	// MARCXML starts with '<', binary MARC with the 5-digit record length
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(64)
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+
+	var records []marc.Record
+	if bytes.HasPrefix(head, []byte("<")) {
+		records, err = marc.ReadXML(reader)
+	} else {
+		records, err = marc.ReadBinary(reader)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to parse MARC: " + err.Error(),
+		})
+		return
+	}
+
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File does not contain any MARC records",
+		})
+		return
+	}
+
+	rows := make([]importRow, 0, len(records))
+	for i, record := range records {
+		row := importRow{Row: i + 1, Action: importCreate}
+		row.Data = marc.ToBookInput(record)
+		row.provided = providedFields(row.Data)
+		row.Data.CategoryID = categoryID
+		if row.Data.PriceMinor == 0 {
+			row.Data.PriceMinor = defaultPrice
+			row.Data.Currency = money.BaseCurrency
+		}
+
+		if err := validateInput(&row.Data); err != nil {
+			row.Errors = append(row.Errors, errorMessages(err)...)
+			row.Action = importReject
+		}
+
+		if row.rawMetadata, err = marc.EncodeRaw(record); err != nil {
+			row.Errors = append(row.Errors, "Failed to keep source record")
+			row.Action = importReject
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := matchImportRows(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to match existing books",
+		})
+		return
+	}
+
+	if dryRun {
+		respondImport(c, true, rows, nil)
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	if err := commitImport(rows, nil, usernameStr); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Import failed, no changes were made: " + err.Error(),
+		})
+		return
+	}
+
+	respondImport(c, false, rows, nil)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/metadata"
+	"book-management/models"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Representations of a single book offered by GetBookByID
+const (
+	jsonLDContentType     = "application/ld+json"
+	dublinCoreContentType = "application/xml"
+)
+
+// bookRepresentations lists the media types GetBookByID can serve. The
+// first one is the default when the client accepts anything.
+var bookRepresentations = []string{
+	gin.MIMEJSON,
+	jsonLDContentType,
+	dublinCoreContentType,
+	"text/xml",
+}
+
+// representationVariants are the ETag variants of the representations
+var representationVariants = map[string]string{
+	jsonLDContentType:     "ld",
+	dublinCoreContentType: "dc",
+}
+
+// bookRepresentation picks the representation from the format parameter
+// (json, jsonld or dc), or else from the Accept header. When neither names
+// a supported representation a response is written and false is returned.
+func bookRepresentation(c *gin.Context) (string, bool) {
+	switch c.Query("format") {
+	case "":
+	case "json":
+		return gin.MIMEJSON, true
+	case "jsonld":
+		return jsonLDContentType, true
+	case "dc":
+		return dublinCoreContentType, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be one of json, jsonld, dc",
+		})
+		return "", false
+	}
+
+	switch c.NegotiateFormat(bookRepresentations...) {
+	case gin.MIMEJSON:
+		return gin.MIMEJSON, true
+	case jsonLDContentType:
+		return jsonLDContentType, true
+	case dublinCoreContentType, "text/xml":
+		return dublinCoreContentType, true
+	}
+
+	c.JSON(http.StatusNotAcceptable, gin.H{
+		"error": "Acceptable types are application/json, application/ld+json, application/xml",
+	})
+	return "", false
+}
+
+// bookMetadata renders a book as schema.org JSON-LD or Dublin Core XML
+func bookMetadata(contentType string, book models.Book) ([]byte, error) {
+	var categoryName string
+	err := config.DB.QueryRow("SELECT name FROM categories WHERE id = $1", book.CategoryID).Scan(&categoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	description := metadata.Describe(book, categoryName, config.BaseURL+"/api/books")
+
+	var body []byte
+	if contentType == jsonLDContentType {
+		body, err = json.Marshal(description.SchemaOrg())
+	} else {
+		body, err = xml.MarshalIndent(description.DublinCore(), "", "  ")
+		body = append([]byte(xml.Header), body...)
+	}
+	return body, err
+}
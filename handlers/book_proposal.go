@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/edition"
+	"book-management/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// proposeCorrections compares the metadata extracted from a file with the
+// book and lists the fields that differ
+func proposeCorrections(book models.Book, meta edition.Metadata) []models.BookProposal {
+	proposals := []models.BookProposal{}
+
+	if meta.Title != "" && !strings.EqualFold(strings.TrimSpace(book.Title), meta.Title) {
+		proposals = append(proposals, models.BookProposal{
+			Field: "title", Current: book.Title, Proposed: meta.Title,
+		})
+	}
+
+	if meta.PageCount > 0 && meta.PageCount != book.TotalPage {
+		proposal := models.BookProposal{
+			Field: "total_page", Current: book.TotalPage, Proposed: meta.PageCount,
+		}
+		if meta.PageCountEstimated {
+			proposal.Note = "Estimated from the text length; the EPUB has no page list"
+		}
+		proposals = append(proposals, proposal)
+	}
+
+	if len(meta.Authors) > 0 && strings.Join(meta.Authors, "|") != strings.Join(book.Authors, "|") {
+		proposals = append(proposals, models.BookProposal{
+			Field: "authors", Current: authorsOrEmpty(book.Authors), Proposed: meta.Authors,
+		})
+	}
+
+	if meta.Publisher != "" && !strings.EqualFold(book.Publisher, meta.Publisher) {
+		proposals = append(proposals, models.BookProposal{
+			Field: "publisher", Current: book.Publisher, Proposed: meta.Publisher,
+		})
+	}
+
+	if meta.ISBN != "" && models.NormalizeISBN(book.ISBN) != meta.ISBN {
+		proposals = append(proposals, models.BookProposal{
+			Field: "isbn", Current: book.ISBN, Proposed: meta.ISBN,
+		})
+	}
+
+	return proposals
+}
+
+// loadFileProposals loads a book and the metadata of one of its files. When
+// either cannot be loaded a response is written and false is returned.
+func loadFileProposals(c *gin.Context) (models.Book, edition.Metadata, bool) {
+	var meta edition.Metadata
+
+	bookID, fileID, ok := bookFileParams(c)
+	if !ok {
+		return models.Book{}, meta, false
+	}
+
+	book, err := findBook(config.DB, bookID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return book, meta, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return book, meta, false
+	}
+
+	var raw []byte
+	err = config.DB.QueryRow(
+		"SELECT metadata FROM book_files WHERE id = $1 AND book_id = $2",
+		fileID, bookID,
+	).Scan(&raw)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return book, meta, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return book, meta, false
+	}
+
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to read file metadata",
+			})
+			return book, meta, false
+		}
+	}
+
+	return book, meta, true
+}
+
+// GetBookFileProposals lists the corrections the metadata of a file
+// suggests for its book. Proposals are computed against the current book,
+// so accepted ones disappear from the list.
+func GetBookFileProposals(c *gin.Context) {
+	book, meta, ok := loadFileProposals(c)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", formatETag(book.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"data":     proposeCorrections(book, meta),
+		"metadata": meta,
+	})
+}
+
+// AcceptBookFileProposals applies the chosen proposals to the book. Like
+// PUT it requires If-Match, and the result goes through the same
+// validation; thickness is recalculated when total_page changes.
+func AcceptBookFileProposals(c *gin.Context) {
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var accept models.AcceptProposalsInput
+	if !bindJSON(c, &accept) {
+		return
+	}
+
+	book, meta, ok := loadFileProposals(c)
+	if !ok {
+		return
+	}
+
+	if !versionMatches(versions, book.Version) {
+		c.Header("ETag", formatETag(book.Version))
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Resource has been modified by another request",
+		})
+		return
+	}
+
+	proposed := make(map[string]models.BookProposal)
+	for _, proposal := range proposeCorrections(book, meta) {
+		proposed[proposal.Field] = proposal
+	}
+
+	input := book.Input()
+	for _, field := range accept.Fields {
+		proposal, found := proposed[field]
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "No proposal for field " + field,
+			})
+			return
+		}
+
+		switch field {
+		case "title":
+			input.Title = proposal.Proposed.(string)
+		case "total_page":
+			input.TotalPage = proposal.Proposed.(int)
+		case "authors":
+			input.Authors = proposal.Proposed.([]string)
+		case "publisher":
+			input.Publisher = proposal.Proposed.(string)
+		case "isbn":
+			input.ISBN = proposal.Proposed.(string)
+		}
+	}
+
+	if err := validateInput(&input); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	thickness := book.Thickness
+	if input.TotalPage != book.TotalPage {
+		thickness = input.CalculateThickness()
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	version, err := updateBookRow(config.DB, book.ID, input, thickness, usernameStr, []int64{int64(book.Version)})
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", book.ID, "Book not found")
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update book",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Proposals applied successfully",
+		"applied":   accept.Fields,
+		"thickness": thickness,
+	})
+}
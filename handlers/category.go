@@ -9,13 +9,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // GetAllCategories retrieves all categories
 func GetAllCategories(c *gin.Context) {
 	rows, err := config.DB.Query(`
-		SELECT id, name, created_at, created_by, modified_at, modified_by 
-		FROM categories 
+		SELECT id, name, created_at, created_by, modified_at, modified_by, version
+		FROM categories
 		ORDER BY id DESC
 	`)
 	if err != nil {
@@ -36,6 +37,7 @@ func GetAllCategories(c *gin.Context) {
 			&category.CreatedBy,
 			&category.ModifiedAt,
 			&category.ModifiedBy,
+			&category.Version,
 		)
 		if err != nil {
 			continue
@@ -51,22 +53,19 @@ func GetAllCategories(c *gin.Context) {
 // CreateCategory creates a new category
 func CreateCategory(c *gin.Context) {
 	var input models.CategoryInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	if !bindJSON(c, &input) {
 		return
 	}
 
 	username, _ := c.Get("username")
 	usernameStr := username.(string)
 
-	var categoryID int
+	var categoryID, version int
 	err := config.DB.QueryRow(`
 		INSERT INTO categories (name, created_at, created_by, modified_at, modified_by)
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, input.Name, time.Now(), usernameStr, time.Now(), usernameStr).Scan(&categoryID)
+		RETURNING id, version
+	`, input.Name, time.Now(), usernameStr, time.Now(), usernameStr).Scan(&categoryID, &version)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -75,6 +74,7 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", formatETag(version))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Category created successfully",
 		"id":      categoryID,
@@ -93,8 +93,8 @@ func GetCategoryByID(c *gin.Context) {
 
 	var category models.Category
 	err = config.DB.QueryRow(`
-		SELECT id, name, created_at, created_by, modified_at, modified_by 
-		FROM categories 
+		SELECT id, name, created_at, created_by, modified_at, modified_by, version
+		FROM categories
 		WHERE id = $1
 	`, id).Scan(
 		&category.ID,
@@ -103,6 +103,7 @@ func GetCategoryByID(c *gin.Context) {
 		&category.CreatedBy,
 		&category.ModifiedAt,
 		&category.ModifiedBy,
+		&category.Version,
 	)
 
 	if err == sql.ErrNoRows {
@@ -119,6 +120,10 @@ func GetCategoryByID(c *gin.Context) {
 		return
 	}
 
+	if notModified(c, category.Version) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": category,
 	})
@@ -134,38 +139,40 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
 	var input models.CategoryInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	if !bindJSON(c, &input) {
 		return
 	}
 
 	username, _ := c.Get("username")
 	usernameStr := username.(string)
 
-	result, err := config.DB.Exec(`
-		UPDATE categories 
-		SET name = $1, modified_at = $2, modified_by = $3
-		WHERE id = $4
-	`, input.Name, time.Now(), usernameStr, id)
+	var version int
+	err = config.DB.QueryRow(`
+		UPDATE categories
+		SET name = $1, modified_at = $2, modified_by = $3, version = version + 1
+		WHERE id = $4 AND ($5::bigint[] IS NULL OR version = ANY($5))
+		RETURNING version
+	`, input.Name, time.Now(), usernameStr, id, pq.Array(versions)).Scan(&version)
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update category",
-		})
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "categories", id, "Category not found")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Category not found",
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update category",
 		})
 		return
 	}
 
+	c.Header("ETag", formatETag(version))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Category updated successfully",
 	})
@@ -181,7 +188,61 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	result, err := config.DB.Exec("DELETE FROM categories WHERE id = $1", id)
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	// The books are deleted one by one through deleteBookRow rather than by
+	// the foreign key cascade, so that deletions are recorded for ONIX
+	// feeds, reading lists are renumbered and suggestions are refreshed. A
+	// book with library copies rejects the whole deletion.
+	var rowsAffected int64
+	err = withTx(config.DB, func(q queryer) error {
+		// Locking the category also keeps new books out of it until the end
+		var lockedID int
+		err := q.QueryRow(`
+			SELECT id FROM categories
+			WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))
+			FOR UPDATE
+		`, id, pq.Array(versions)).Scan(&lockedID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var bookIDs []int64
+		err = q.QueryRow(`
+			SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM books WHERE category_id = $1
+		`, id).Scan(pq.Array(&bookIDs))
+		if err != nil {
+			return err
+		}
+		for _, bookID := range bookIDs {
+			if _, err := deleteBookRow(q, int(bookID), nil); err != nil {
+				return err
+			}
+		}
+
+		result, err := q.Exec("DELETE FROM categories WHERE id = $1", id)
+		if isForeignKeyViolation(err) {
+			return errBookHasCopies
+		}
+		if err != nil {
+			return err
+		}
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
+	if err == errBookHasCopies {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Category has books with library copies; delete or withdraw them first",
+		})
+		return
+	}
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete category",
@@ -189,11 +250,8 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Category not found",
-		})
+		preconditionFailed(c, "categories", id, "Category not found")
 		return
 	}
 
@@ -223,10 +281,8 @@ func GetBooksByCategory(c *gin.Context) {
 	}
 
 	rows, err := config.DB.Query(`
-		SELECT id, title, description, image_url, release_year, price, 
-		       total_page, thickness, category_id, created_at, created_by, 
-		       modified_at, modified_by 
-		FROM books 
+		SELECT `+bookColumns+`
+		FROM books
 		WHERE category_id = $1
 		ORDER BY id DESC
 	`, id)
@@ -241,26 +297,12 @@ func GetBooksByCategory(c *gin.Context) {
 	var books []models.Book
 	for rows.Next() {
 		var book models.Book
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Description,
-			&book.ImageURL,
-			&book.ReleaseYear,
-			&book.Price,
-			&book.TotalPage,
-			&book.Thickness,
-			&book.CategoryID,
-			&book.CreatedAt,
-			&book.CreatedBy,
-			&book.ModifiedAt,
-			&book.ModifiedBy,
-		)
-		if err != nil {
+		if err := scanBook(rows, &book); err != nil {
 			continue
 		}
 		books = append(books, book)
 	}
+	translateThickness(books, requestedLanguage(c))
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": books,
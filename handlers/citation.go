@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"book-management/citation"
+	"book-management/config"
+	"book-management/models"
+	"bytes"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// citationFormat picks the format from the format parameter, or else from
+// the Accept header. When neither names a supported format a response is
+// written and false is returned.
+func citationFormat(c *gin.Context) (citation.Format, bool) {
+	if name := c.Query("format"); name != "" {
+		format, ok := citation.ParseFormat(name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "format must be one of bibtex, ris, csl-json",
+			})
+		}
+		return format, ok
+	}
+
+	offered := make([]string, len(citation.ContentTypes))
+	for i, contentType := range citation.ContentTypes {
+		offered[i] = contentType.MediaType
+	}
+
+	accepted := c.NegotiateFormat(offered...)
+	for _, contentType := range citation.ContentTypes {
+		if contentType.MediaType == accepted {
+			return contentType.Format, true
+		}
+	}
+
+	c.JSON(http.StatusNotAcceptable, gin.H{
+		"error": "Acceptable types are " + strings.Join(offered, ", "),
+	})
+	return "", false
+}
+
+// writeCitations renders books in format as a downloadable file
+func writeCitations(c *gin.Context, format citation.Format, filename string, books []models.Book) {
+	var body bytes.Buffer
+	if err := citation.Write(&body, format, books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build citation",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="`+filename+"."+format.Extension()+`"`)
+	c.Header("Vary", "Accept")
+	c.Data(http.StatusOK, format.ContentType(), body.Bytes())
+}
+
+// GetBookCitation renders a single book as BibTeX, RIS or CSL-JSON
+func GetBookCitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	format, ok := citationFormat(c)
+	if !ok {
+		return
+	}
+
+	book, err := findBook(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return
+	}
+
+	writeCitations(c, format, "book-"+strconv.Itoa(id), []models.Book{book})
+}
+
+// GetBookCitations renders several books as one citation document. The
+// books are either listed in ids (comma separated, kept in that order) or
+// selected with the same filters and pagination as GetAllBooks.
+func GetBookCitations(c *gin.Context) {
+	format, ok := citationFormat(c)
+	if !ok {
+		return
+	}
+
+	if raw := c.Query("ids"); raw != "" {
+		var ids []int64
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "ids must be a comma separated list of book IDs",
+				})
+				return
+			}
+			ids = append(ids, id)
+		}
+
+		books, err := booksByIDs(ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch books",
+			})
+			return
+		}
+
+		var missing []int64
+		for _, id := range ids {
+			if _, found := books[int(id)]; !found {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":       "Book not found",
+				"missing_ids": missing,
+			})
+			return
+		}
+
+		ordered := make([]models.Book, len(ids))
+		for i, id := range ids {
+			ordered[i] = books[int(id)]
+		}
+		writeCitations(c, format, "books", ordered)
+		return
+	}
+
+	filter, err := parseBookFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	page, paginated, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var pagePtr *pagination
+	if paginated {
+		pagePtr = &page
+	}
+
+	books, _, err := queryBooks(filter, pagePtr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch books",
+		})
+		return
+	}
+
+	writeCitations(c, format, "books", books)
+}
+
+// booksByIDs loads the books with the given IDs keyed by ID
+func booksByIDs(ids []int64) (map[int]models.Book, error) {
+	rows, err := config.DB.Query(`
+		SELECT `+bookColumns+`
+		FROM books
+		WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := make(map[int]models.Book)
+	for rows.Next() {
+		var book models.Book
+		if err := scanBook(rows, &book); err != nil {
+			return nil, err
+		}
+		books[book.ID] = book
+	}
+	return books, rows.Err()
+}
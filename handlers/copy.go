@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// copyColumns is the column list scanned by scanCopy
+const copyColumns = `
+	id, book_id, barcode, condition, shelf_location, status,
+	created_at, created_by, modified_at, modified_by, version
+`
+
+// scanCopy scans a row selected with copyColumns
+func scanCopy(row rowScanner, bookCopy *models.Copy) error {
+	var createdBy, modifiedBy sql.NullString
+	err := row.Scan(&bookCopy.ID, &bookCopy.BookID, &bookCopy.Barcode, &bookCopy.Condition, &bookCopy.ShelfLocation,
+		&bookCopy.Status, &bookCopy.CreatedAt, &createdBy, &bookCopy.ModifiedAt, &modifiedBy, &bookCopy.Version)
+	bookCopy.CreatedBy = createdBy.String
+	bookCopy.ModifiedBy = modifiedBy.String
+	return err
+}
+
+// bookAvailability counts the copies of a book that can be lent, leaving
+// out lost and withdrawn ones
+func bookAvailability(q queryer, bookID int) (models.CopyAvailability, error) {
+	var availability models.CopyAvailability
+	err := q.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE status = $2)
+		FROM copies
+		WHERE book_id = $1 AND status NOT IN ($3, $4)
+	`, bookID, models.CopyAvailable, models.CopyLost, models.CopyWithdrawn).Scan(&availability.Total, &availability.Available)
+	return availability, err
+}
+
+// holdNewlyAvailable passes a copy that just became available to the
+// first patron waiting for its book, reloading it when it was set aside
+func holdNewlyAvailable(q queryer, bookCopy *models.Copy, username string) error {
+	assigned, err := assignToNextHold(q, bookCopy.ID, bookCopy.BookID, username)
+	if err != nil || !assigned {
+		return err
+	}
+	return scanCopy(q.QueryRow(`
+		SELECT `+copyColumns+`
+		FROM copies
+		WHERE id = $1
+	`, bookCopy.ID), bookCopy)
+}
+
+// GetBookCopies lists the physical copies of a book
+func GetBookCopies(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+copyColumns+`
+		FROM copies
+		WHERE book_id = $1
+		ORDER BY barcode
+	`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch copies",
+		})
+		return
+	}
+	defer rows.Close()
+
+	copies := []models.Copy{}
+	for rows.Next() {
+		var bookCopy models.Copy
+		if err := scanCopy(rows, &bookCopy); err != nil {
+			continue
+		}
+		copies = append(copies, bookCopy)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": copies,
+	})
+}
+
+// CreateBookCopy adds a physical copy to a book
+func CreateBookCopy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.CopyInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Condition == "" {
+		input.Condition = "good"
+	}
+	if input.Status == "" {
+		input.Status = models.CopyAvailable
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", id).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var bookCopy models.Copy
+	err = withTx(config.DB, func(q queryer) error {
+		err := scanCopy(q.QueryRow(`
+			INSERT INTO copies (book_id, barcode, condition, shelf_location, status, created_at, created_by, modified_at, modified_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $6, $7)
+			ON CONFLICT (barcode) DO NOTHING
+			RETURNING `+copyColumns,
+			id, input.Barcode, input.Condition, input.ShelfLocation, input.Status, time.Now(), usernameStr,
+		), &bookCopy)
+		if err != nil || bookCopy.Status != models.CopyAvailable {
+			return err
+		}
+		return holdNewlyAvailable(q, &bookCopy, usernameStr)
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A copy with this barcode already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create copy",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(bookCopy.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Copy created successfully",
+		"data":    bookCopy,
+	})
+}
+
+// GetCopyByID retrieves a copy by ID
+func GetCopyByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid copy ID",
+		})
+		return
+	}
+
+	var bookCopy models.Copy
+	err = scanCopy(config.DB.QueryRow(`
+		SELECT `+copyColumns+`
+		FROM copies
+		WHERE id = $1
+	`, id), &bookCopy)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Copy not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch copy",
+		})
+		return
+	}
+
+	if notModified(c, bookCopy.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": bookCopy,
+	})
+}
+
+// UpdateCopy updates a copy by ID. The status of a copy that is on loan or
+// on hold is kept; it changes through circulation. A copy made available
+// goes to the next hold in line.
+func UpdateCopy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid copy ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var input models.CopyInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Condition == "" {
+		input.Condition = "good"
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var bookCopy models.Copy
+	err = withTx(config.DB, func(q queryer) error {
+		err := scanCopy(q.QueryRow(`
+			UPDATE copies
+			SET barcode = $1, condition = $2, shelf_location = $3,
+			    status = CASE WHEN status IN ($4, $5) OR $6 = '' THEN status ELSE $6 END,
+			    modified_at = $7, modified_by = $8, version = version + 1
+			WHERE id = $9 AND ($10::bigint[] IS NULL OR version = ANY($10))
+			RETURNING `+copyColumns,
+			input.Barcode, input.Condition, input.ShelfLocation, models.CopyOnLoan, models.CopyOnHold,
+			input.Status, time.Now(), usernameStr, id, pq.Array(versions),
+		), &bookCopy)
+		if err != nil || bookCopy.Status != models.CopyAvailable {
+			return err
+		}
+		return holdNewlyAvailable(q, &bookCopy, usernameStr)
+	})
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "copies", id, "Copy not found")
+		return
+	}
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A copy with this barcode already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update copy",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(bookCopy.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Copy updated successfully",
+		"status":  bookCopy.Status,
+	})
+}
+
+// DeleteCopy deletes a copy that was never lent or held. Copies with a
+// circulation history are withdrawn instead, so the history is kept.
+func DeleteCopy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid copy ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var lent bool
+	err = config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM loans WHERE copy_id = $1)
+		    OR EXISTS(SELECT 1 FROM holds WHERE copy_id = $1)
+	`, id).Scan(&lent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete copy",
+		})
+		return
+	}
+
+	if lent {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Copy has a loan or hold history; set its status to withdrawn instead",
+		})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM copies
+		WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))
+	`, id, pq.Array(versions))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete copy",
+		})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		preconditionFailed(c, "copies", id, "Copy not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Copy deleted successfully",
+	})
+}
+
+// GetCopyLoans lists the loans of a copy, newest first
+func GetCopyLoans(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid copy ID",
+		})
+		return
+	}
+
+	writeLoans(c, "l.copy_id = $1", id)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/cover"
+	"book-management/storage"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// maxCoverSize limits uploaded cover images to 5 MB
+const maxCoverSize = 5 << 20
+
+// coverURLPrefix is the path covers are served under; image_url values
+// starting with it point into our blob store
+const coverURLPrefix = "/api/covers/"
+
+// coverFilePattern matches the file part of a cover URL, e.g. "small.jpg"
+var coverFilePattern = regexp.MustCompile(`^(original|small|medium|large)\.(jpg|png)$`)
+
+// coverHashPattern matches the SHA-256 part of a cover URL
+var coverHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// coverKey is the blob store key of one cover rendition
+func coverKey(hash, filename string) string {
+	return "covers/" + hash + "/" + filename
+}
+
+// coverURL is the URL a cover rendition is served from
+func coverURL(hash, filename string) string {
+	return coverURLPrefix + hash + "/" + filename
+}
+
+// UploadBookCover stores a cover image for a book. The image is validated,
+// stripped of EXIF data and rendered in several thumbnail sizes, and the
+// book's image_url is pointed at the stored original. If-Match is honoured
+// when sent.
+func UploadBookCover(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		var ok bool
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCoverSize+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File is required",
+		})
+		return
+	}
+
+	if fileHeader.Size > maxCoverSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Cover image must not exceed 5 MB",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxCoverSize+1))
+	file.Close()
+	if err != nil || len(data) > maxCoverSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+
+	var oldImageURL string
+	err = config.DB.QueryRow("SELECT image_url FROM books WHERE id = $1", id).Scan(&oldImageURL)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return
+	}
+
+	processed, err := cover.Process(data)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := storeCover(ctx, processed); err != nil {
+		log.Println("Cover upload failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store cover image",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	imageURL := coverURL(processed.Hash, processed.Filename(cover.Original))
+	version, err := setBookImageURL(id, imageURL, usernameStr, versions)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", id, "Book not found")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update book",
+		})
+		return
+	}
+
+	if oldImageURL != imageURL {
+		deleteUnusedCover(ctx, oldImageURL)
+	}
+
+	thumbnails := gin.H{}
+	for _, size := range cover.Sizes {
+		thumbnails[size.Name] = coverURL(processed.Hash, processed.Filename(size.Name))
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Cover uploaded successfully",
+		"image_url":  imageURL,
+		"thumbnails": thumbnails,
+		"width":      processed.Width,
+		"height":     processed.Height,
+	})
+}
+
+// storeCover writes every rendition of a cover to the blob store
+func storeCover(ctx context.Context, processed *cover.Cover) error {
+	for _, rendition := range processed.Renditions {
+		key := coverKey(processed.Hash, processed.Filename(rendition.Name))
+		err := config.Storage.Put(ctx, key, bytes.NewReader(rendition.Data), int64(len(rendition.Data)), processed.ContentType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBookImageURL points a book at a new image and returns its new version.
+// sql.ErrNoRows is returned when the book is missing or its version did
+// not match.
+func setBookImageURL(id int, imageURL, username string, versions []int64) (int, error) {
+	var version int
+	err := config.DB.QueryRow(`
+		UPDATE books
+		SET image_url = $1, modified_at = $2, modified_by = $3, version = version + 1,
+		    image_status = '', image_mirror_url = '', image_error = '', image_checked_at = NULL
+		WHERE id = $4 AND ($5::bigint[] IS NULL OR version = ANY($5))
+		RETURNING version
+	`, imageURL, time.Now(), username, id, pq.Array(versions)).Scan(&version)
+	return version, err
+}
+
+// deleteUnusedCover removes the stored renditions of a previous cover once
+// no book points at it any more, either as its image or as the mirror of an
+// external image. Failures are only logged.
+func deleteUnusedCover(ctx context.Context, imageURL string) {
+	if !strings.HasPrefix(imageURL, coverURLPrefix) {
+		return
+	}
+
+	hash, filename := path.Split(strings.TrimPrefix(imageURL, coverURLPrefix))
+	hash = strings.TrimSuffix(hash, "/")
+	if !coverHashPattern.MatchString(hash) || !coverFilePattern.MatchString(filename) {
+		return
+	}
+
+	var inUse bool
+	err := config.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM books WHERE image_url LIKE $1 OR image_mirror_url LIKE $1)",
+		coverURLPrefix+hash+"/%",
+	).Scan(&inUse)
+	if err != nil || inUse {
+		return
+	}
+
+	extension := path.Ext(filename)
+	names := []string{cover.Original}
+	for _, size := range cover.Sizes {
+		names = append(names, size.Name)
+	}
+	for _, name := range names {
+		err := config.Storage.Delete(ctx, coverKey(hash, name+extension))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Println("Failed to delete cover:", err)
+		}
+	}
+}
+
+// GetCover serves a stored cover rendition. Cover URLs contain the hash of
+// the upload, so they never change and can be cached indefinitely.
+func GetCover(c *gin.Context) {
+	hash := c.Param("hash")
+	filename := c.Param("file")
+	if !coverHashPattern.MatchString(hash) || !coverFilePattern.MatchString(filename) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Cover not found",
+		})
+		return
+	}
+
+	object, err := config.Storage.Get(c.Request.Context(), coverKey(hash, filename))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Cover not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch cover",
+		})
+		return
+	}
+	defer object.Body.Close()
+
+	c.Header("Content-Type", object.ContentType)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", `"`+hash+"-"+filename+`"`)
+	http.ServeContent(c.Writer, c.Request, filename, object.ModTime, object.Body)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"book-management/config"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formatETag builds a strong ETag from a row version
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// formatVariantETag builds a strong ETag for one variant of a row, such as
+// another representation. The version comes first so that the tag still
+// works in If-Match.
+func formatVariantETag(version int, variant string) string {
+	if variant == "" {
+		return formatETag(version)
+	}
+	return `"` + strconv.Itoa(version) + "-" + variant + `"`
+}
+
+// formatContentETag builds a strong ETag for a response that depends on
+// more than the row, from the version, the variant and a hash of the body
+func formatContentETag(version int, variant string, body []byte) string {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:8])
+	if variant != "" {
+		hash = variant + "-" + hash
+	}
+	return formatVariantETag(version, hash)
+}
+
+// notModified writes the ETag header and reports whether the request's
+// If-None-Match header already matches it, in which case a 304 is sent
+func notModified(c *gin.Context, version int) bool {
+	return etagNotModified(c, formatETag(version))
+}
+
+// etagNotModified is notModified for a precomputed ETag
+func etagNotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// requireIfMatch parses the If-Match header into the list of versions the
+// client expects to modify. A nil slice means "*" (any current version).
+// When the header is missing or malformed a response is written and ok is false.
+func requireIfMatch(c *gin.Context) (versions []int64, ok bool) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": "If-Match header required",
+		})
+		return nil, false
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		// Weak validators never match for If-Match
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		// Variant tags carry the version before the first "-"
+		value, _, _ := strings.Cut(strings.Trim(tag, `"`), "-")
+		version, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid If-Match header",
+			})
+			return nil, false
+		}
+		versions = append(versions, version)
+	}
+
+	if versions == nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "If-Match does not match the current version",
+		})
+		return nil, false
+	}
+
+	return versions, true
+}
+
+// preconditionFailed is called when a conditional write matched no rows.
+// It distinguishes a missing row (404) from a stale version (412).
+func preconditionFailed(c *gin.Context, table string, id int, notFound string) {
+	var currentVersion int
+	err := config.DB.QueryRow("SELECT version FROM "+table+" WHERE id = $1", id).Scan(&currentVersion)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": notFound,
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check current version",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(currentVersion))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "Resource has been modified by another request",
+	})
+}
+
+// versionMatches reports whether version satisfies the If-Match versions
+func versionMatches(versions []int64, version int) bool {
+	if versions == nil {
+		return true
+	}
+	for _, v := range versions {
+		if v == int64(version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// overdueInterval is how often overdue loans are marked and their fines
+// accrued
+const overdueInterval = 24 * time.Hour
+
+// accrueFine sets the fine of a loan as of until and marks it overdue
+// when it is past due. With notify, a loan marked overdue for the first
+// time notifies its patron.
+func accrueFine(q queryer, loanID int, until time.Time, notify bool) error {
+	var patronID, categoryID int
+	var dueAt time.Time
+	var firstTime bool
+	var title string
+	err := q.QueryRow(`
+		SELECT l.patron_id, l.due_at, l.overdue_at IS NULL, b.category_id, b.title
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		JOIN books b ON b.id = c.book_id
+		WHERE l.id = $1
+		FOR UPDATE OF l
+	`, loanID).Scan(&patronID, &dueAt, &firstTime, &categoryID, &title)
+	if err != nil || !until.After(dueAt) {
+		return err
+	}
+
+	fine := models.CurrentFineRules().Fine(categoryID, dueAt, until)
+	_, err = q.Exec(`
+		UPDATE loans
+		SET fine = $1, overdue_at = COALESCE(overdue_at, $2)
+		WHERE id = $3
+	`, fine, until, loanID)
+	if err != nil || !notify || !firstTime {
+		return err
+	}
+
+	return notifyPatron(q, models.Notification{
+		PatronID: patronID,
+		LoanID:   &loanID,
+		Type:     models.NotificationLoanOverdue,
+		Message:  fmt.Sprintf("%q was due on %s; please return it", title, dueAt.Format("2006-01-02")),
+	})
+}
+
+// patronBalance is what a patron owes: the fines on their loans minus
+// payments and waivers
+func patronBalance(q queryer, patronID int) (int, error) {
+	var balance int
+	err := q.QueryRow(`
+		SELECT COALESCE((SELECT SUM(fine) FROM loans WHERE patron_id = $1), 0)
+		     - COALESCE((SELECT SUM(amount) FROM fine_transactions WHERE patron_id = $1), 0)
+	`, patronID).Scan(&balance)
+	return balance, err
+}
+
+// StartOverdueJob runs the daily job that marks overdue loans and accrues
+// their fines, until ctx is cancelled
+func StartOverdueJob(ctx context.Context) {
+	go func() {
+		for {
+			accrueOverdueFines(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(overdueInterval):
+			}
+		}
+	}()
+}
+
+// accrueOverdueFines updates every loan that is out past its due date, each
+// in its own transaction
+func accrueOverdueFines(ctx context.Context) {
+	now := time.Now()
+	rows, err := config.DB.QueryContext(ctx, `
+		SELECT id
+		FROM loans
+		WHERE returned_at IS NULL AND due_at < $1
+		ORDER BY due_at
+	`, now)
+	if err != nil {
+		log.Println("Overdue job failed:", err)
+		return
+	}
+
+	var loanIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			loanIDs = append(loanIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range loanIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		err := withTx(config.DB, func(q queryer) error {
+			return accrueFine(q, id, now, true)
+		})
+		if err != nil {
+			log.Printf("Overdue job failed for loan %d: %v", id, err)
+		}
+	}
+	log.Printf("Overdue job updated %d loans", len(loanIDs))
+}
+
+// GetOverdueLoans lists the loans that are out past their due date
+func GetOverdueLoans(c *gin.Context) {
+	writeLoans(c, "l.returned_at IS NULL AND l.due_at < $1", time.Now())
+}
+
+// GetPatronFines shows the fine balance of a patron with the fined loans
+// and the payments and waivers made
+func GetPatronFines(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	if _, err := findPatron(config.DB, id); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	}
+
+	account, err := fineAccount(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch fines",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": account,
+	})
+}
+
+// fineAccount loads the fines, payments and waivers of a patron
+func fineAccount(q queryer, patronID int) (models.FineAccount, error) {
+	account := models.FineAccount{
+		PatronID:     patronID,
+		Loans:        []models.Loan{},
+		Transactions: []models.FineTransaction{},
+	}
+
+	rows, err := q.Query(`
+		SELECT `+loanColumns+`
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		WHERE l.patron_id = $1 AND l.fine > 0
+		ORDER BY l.due_at
+	`, patronID)
+	if err != nil {
+		return account, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var loan models.Loan
+		if err := scanLoan(rows, &loan); err != nil {
+			return account, err
+		}
+		account.Charged += loan.Fine
+		account.Loans = append(account.Loans, loan)
+	}
+
+	transactions, err := q.Query(`
+		SELECT `+fineTransactionColumns+`
+		FROM fine_transactions
+		WHERE patron_id = $1
+		ORDER BY created_at, id
+	`, patronID)
+	if err != nil {
+		return account, err
+	}
+	defer transactions.Close()
+
+	for transactions.Next() {
+		var t models.FineTransaction
+		if err := scanFineTransaction(transactions, &t); err != nil {
+			return account, err
+		}
+		if t.Type == models.FineWaiver {
+			account.Waived += t.Amount
+		} else {
+			account.Paid += t.Amount
+		}
+		account.Transactions = append(account.Transactions, t)
+	}
+
+	account.Balance = account.Charged - account.Paid - account.Waived
+	account.Blocked = models.CurrentFineRules().Blocks(account.Balance)
+	return account, nil
+}
+
+// fineTransactionColumns is the column list scanned by scanFineTransaction
+const fineTransactionColumns = `
+	id, patron_id, loan_id, type, amount, note, created_at, created_by
+`
+
+// scanFineTransaction scans a row selected with fineTransactionColumns
+func scanFineTransaction(row rowScanner, t *models.FineTransaction) error {
+	var loanID sql.NullInt64
+	var createdBy sql.NullString
+	err := row.Scan(&t.ID, &t.PatronID, &loanID, &t.Type, &t.Amount, &t.Note, &t.CreatedAt, &createdBy)
+	t.CreatedBy = createdBy.String
+	if loanID.Valid {
+		id := int(loanID.Int64)
+		t.LoanID = &id
+	}
+	return err
+}
+
+// RecordFinePayment records a payment against a patron's balance
+func RecordFinePayment(c *gin.Context) {
+	recordFineTransaction(c, models.FinePayment)
+}
+
+// WaiveFine forgives part or all of a patron's balance
+func WaiveFine(c *gin.Context) {
+	recordFineTransaction(c, models.FineWaiver)
+}
+
+// recordFineTransaction records a payment or waiver. The amount may not
+// exceed the balance, and a loan it refers to must be the patron's.
+func recordFineTransaction(c *gin.Context, transactionType string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	var input models.FineTransactionInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the patron keeps two payments from both passing the check
+	if _, err := scanPatronForUpdate(tx, id); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record " + transactionType,
+		})
+		return
+	}
+
+	if input.LoanID != nil {
+		var owner int
+		err := tx.QueryRow("SELECT patron_id FROM loans WHERE id = $1", *input.LoanID).Scan(&owner)
+		if err == sql.ErrNoRows || (err == nil && owner != id) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Loan does not belong to this patron",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to record " + transactionType,
+			})
+			return
+		}
+	}
+
+	balance, err := patronBalance(tx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record " + transactionType,
+		})
+		return
+	}
+
+	if input.Amount > balance {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Amount exceeds the outstanding balance of %d", balance),
+		})
+		return
+	}
+
+	var t models.FineTransaction
+	err = scanFineTransaction(tx.QueryRow(`
+		INSERT INTO fine_transactions (patron_id, loan_id, type, amount, note, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+fineTransactionColumns,
+		id, input.LoanID, transactionType, input.Amount, input.Note, time.Now(), usernameStr,
+	), &t)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record " + transactionType,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Fine " + transactionType + " recorded successfully",
+		"data":    t,
+		"balance": balance - input.Amount,
+	})
+}
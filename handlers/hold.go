@@ -0,0 +1,605 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// holdExpiryInterval is how often ready holds past their pickup window are
+// expired
+const holdExpiryInterval = 5 * time.Minute
+
+// holdColumns is the column list scanned by scanHold. Queries select it
+// from holds h left joined with copies c. The position counts the pending
+// holds on the same book placed up to and including this one.
+const holdColumns = `
+	h.id, h.book_id, h.patron_id, h.status,
+	CASE WHEN h.status = 'pending' THEN (
+		SELECT COUNT(*)
+		FROM holds q
+		WHERE q.book_id = h.book_id AND q.status = 'pending'
+		  AND (q.created_at, q.id) <= (h.created_at, h.id)
+	) END,
+	h.copy_id, COALESCE(c.barcode, ''), h.created_at, h.ready_at, h.expires_at,
+	h.closed_at, h.created_by
+`
+
+// scanHold scans a row selected with holdColumns
+func scanHold(row rowScanner, hold *models.Hold) error {
+	var position, copyID sql.NullInt64
+	var createdBy sql.NullString
+	err := row.Scan(&hold.ID, &hold.BookID, &hold.PatronID, &hold.Status, &position,
+		&copyID, &hold.Barcode, &hold.CreatedAt, &hold.ReadyAt, &hold.ExpiresAt,
+		&hold.ClosedAt, &createdBy)
+	if err != nil {
+		return err
+	}
+
+	hold.CreatedBy = createdBy.String
+	if position.Valid {
+		p := int(position.Int64)
+		hold.Position = &p
+	}
+	if copyID.Valid {
+		id := int(copyID.Int64)
+		hold.CopyID = &id
+	}
+	return nil
+}
+
+// findHold loads a single hold by ID
+func findHold(q queryer, id int) (models.Hold, error) {
+	var hold models.Hold
+	err := scanHold(q.QueryRow(`
+		SELECT `+holdColumns+`
+		FROM holds h
+		LEFT JOIN copies c ON c.id = h.copy_id
+		WHERE h.id = $1
+	`, id), &hold)
+	return hold, err
+}
+
+// writeHolds responds with the holds matching condition in queue order.
+// active=true keeps only pending and ready holds.
+func writeHolds(c *gin.Context, condition string, args ...interface{}) {
+	if c.Query("active") == "true" {
+		condition += " AND h.status IN ('pending', 'ready')"
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+holdColumns+`
+		FROM holds h
+		LEFT JOIN copies c ON c.id = h.copy_id
+		WHERE `+condition+`
+		ORDER BY h.created_at, h.id
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch holds",
+		})
+		return
+	}
+	defer rows.Close()
+
+	holds := []models.Hold{}
+	for rows.Next() {
+		var hold models.Hold
+		if err := scanHold(rows, &hold); err != nil {
+			continue
+		}
+		holds = append(holds, hold)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": holds,
+	})
+}
+
+// notifyPatron stores a notification for a patron and logs it
+func notifyPatron(q queryer, n models.Notification) error {
+	_, err := q.Exec(`
+		INSERT INTO notifications (patron_id, hold_id, loan_id, type, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, n.PatronID, n.HoldID, n.LoanID, n.Type, n.Message, time.Now())
+	if err == nil {
+		log.Printf("Notified patron %d (%s): %s", n.PatronID, n.Type, n.Message)
+	}
+	return err
+}
+
+// assignToNextHold sets a copy aside for the oldest pending hold on its
+// book and notifies the patron. It reports false, leaving the copy alone,
+// when nobody is waiting.
+func assignToNextHold(q queryer, copyID, bookID int, username string) (bool, error) {
+	var holdID, patronID int
+	var title string
+	err := q.QueryRow(`
+		SELECT h.id, h.patron_id, b.title
+		FROM holds h
+		JOIN books b ON b.id = h.book_id
+		WHERE h.book_id = $1 AND h.status = $2
+		ORDER BY h.created_at, h.id
+		LIMIT 1
+		FOR UPDATE OF h
+	`, bookID, models.HoldPending).Scan(&holdID, &patronID, &title)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(models.CurrentCirculationRules().HoldPickup())
+	_, err = q.Exec(`
+		UPDATE holds
+		SET status = $1, copy_id = $2, ready_at = $3, expires_at = $4
+		WHERE id = $5
+	`, models.HoldReady, copyID, now, expiresAt, holdID)
+	if err != nil {
+		return false, err
+	}
+
+	if err := setCopyStatus(q, copyID, models.CopyOnHold, username); err != nil {
+		return false, err
+	}
+
+	return true, notifyPatron(q, models.Notification{
+		PatronID: patronID,
+		HoldID:   &holdID,
+		Type:     models.NotificationHoldReady,
+		Message:  fmt.Sprintf("%q is ready for pickup until %s", title, expiresAt.Format("2006-01-02 15:04")),
+	})
+}
+
+// releaseCopy passes a copy that came back, or whose hold ended, to the
+// next hold in line, or makes it available when nobody is waiting
+func releaseCopy(q queryer, copyID, bookID int, username string) (bool, error) {
+	assigned, err := assignToNextHold(q, copyID, bookID, username)
+	if err != nil || assigned {
+		return assigned, err
+	}
+	return false, setCopyStatus(q, copyID, models.CopyAvailable, username)
+}
+
+// PlaceHold puts a patron in the queue of a book that has no copy
+// available
+func PlaceHold(c *gin.Context) {
+	var input models.HoldInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	patron, err := scanPatronForUpdate(tx, input.PatronID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to place hold",
+		})
+		return
+	}
+
+	if patron.Status != models.PatronActive {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Patron is " + patron.Status,
+		})
+		return
+	}
+
+	var exists, onLoan bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM books WHERE id = $1),
+		       EXISTS(
+		           SELECT 1
+		           FROM loans l
+		           JOIN copies c ON c.id = l.copy_id
+		           WHERE c.book_id = $1 AND l.patron_id = $2 AND l.returned_at IS NULL
+		       )
+	`, input.BookID, patron.ID).Scan(&exists, &onLoan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to place hold",
+		})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if onLoan {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Patron already has a copy of this book on loan",
+		})
+		return
+	}
+
+	availability, err := bookAvailability(tx, input.BookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to place hold",
+		})
+		return
+	}
+
+	if availability.Available > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A copy of this book is available for checkout",
+		})
+		return
+	}
+
+	var holdID int
+	err = tx.QueryRow(`
+		INSERT INTO holds (book_id, patron_id, status, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, input.BookID, patron.ID, models.HoldPending, time.Now(), usernameStr).Scan(&holdID)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Patron already has an active hold on this book",
+		})
+		return
+	}
+
+	var hold models.Hold
+	if err == nil {
+		hold, err = findHold(tx, holdID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to place hold",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Hold placed successfully",
+		"data":    hold,
+	})
+}
+
+// GetHolds lists every hold in queue order
+func GetHolds(c *gin.Context) {
+	writeHolds(c, "TRUE")
+}
+
+// GetHoldByID retrieves a hold by ID
+func GetHoldByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid hold ID",
+		})
+		return
+	}
+
+	hold, err := findHold(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Hold not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch hold",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": hold,
+	})
+}
+
+// GetBookHolds lists the reservation queue of a book
+func GetBookHolds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	writeHolds(c, "h.book_id = $1", id)
+}
+
+// GetPatronHolds lists the holds of a patron with their queue positions
+func GetPatronHolds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	writeHolds(c, "h.patron_id = $1", id)
+}
+
+// CancelHold cancels an active hold. A copy set aside for it goes to the
+// next hold in line.
+func CancelHold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid hold ID",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var bookID int
+	var status string
+	var copyID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT book_id, status, copy_id
+		FROM holds
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&bookID, &status, &copyID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Hold not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel hold",
+		})
+		return
+	}
+
+	if status != models.HoldPending && status != models.HoldReady {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Hold is already " + status,
+		})
+		return
+	}
+
+	err = closeHold(tx, id, models.HoldCancelled)
+	if err == nil && status == models.HoldReady {
+		_, err = releaseCopy(tx, int(copyID.Int64), bookID, usernameStr)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel hold",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Hold cancelled successfully",
+	})
+}
+
+// closeHold ends a hold with a final status
+func closeHold(q queryer, id int, status string) error {
+	_, err := q.Exec("UPDATE holds SET status = $1, closed_at = $2 WHERE id = $3", status, time.Now(), id)
+	return err
+}
+
+// GetPatronNotifications lists the notifications of a patron, newest first
+func GetPatronNotifications(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	writeNotifications(c, id)
+}
+
+// writeNotifications responds with the notifications of a patron
+func writeNotifications(c *gin.Context, patronID int) {
+	rows, err := config.DB.Query(`
+		SELECT id, patron_id, hold_id, loan_id, type, message, created_at
+		FROM notifications
+		WHERE patron_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, patronID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notifications",
+		})
+		return
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var holdID, loanID sql.NullInt64
+		if err := rows.Scan(&n.ID, &n.PatronID, &holdID, &loanID, &n.Type, &n.Message, &n.CreatedAt); err != nil {
+			continue
+		}
+		if holdID.Valid {
+			id := int(holdID.Int64)
+			n.HoldID = &id
+		}
+		if loanID.Valid {
+			id := int(loanID.Int64)
+			n.LoanID = &id
+		}
+		notifications = append(notifications, n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notifications,
+	})
+}
+
+// currentPatronID finds the patron linked to the logged in user. It
+// responds with 404 and reports false when there is none.
+func currentPatronID(c *gin.Context) (int, bool) {
+	username, _ := c.Get("username")
+
+	var id int
+	err := config.DB.QueryRow("SELECT id FROM patrons WHERE username = $1", username).Scan(&id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No patron is linked to this user",
+		})
+		return 0, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch patron",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetMyHolds lists the holds of the logged in patron with their queue
+// positions
+func GetMyHolds(c *gin.Context) {
+	id, ok := currentPatronID(c)
+	if !ok {
+		return
+	}
+
+	writeHolds(c, "h.patron_id = $1", id)
+}
+
+// GetMyNotifications lists the notifications of the logged in patron
+func GetMyNotifications(c *gin.Context) {
+	id, ok := currentPatronID(c)
+	if !ok {
+		return
+	}
+
+	writeNotifications(c, id)
+}
+
+// StartHoldExpiry runs the worker that expires ready holds whose pickup
+// window has passed, until ctx is cancelled
+func StartHoldExpiry(ctx context.Context) {
+	go func() {
+		for {
+			expireDueHolds(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(holdExpiryInterval):
+			}
+		}
+	}()
+}
+
+// expireDueHolds expires holds one at a time until none are left
+func expireDueHolds(ctx context.Context) {
+	for ctx.Err() == nil {
+		expired, err := expireNextHold(ctx)
+		if err != nil {
+			log.Println("Hold expiry failed:", err)
+			return
+		}
+		if !expired {
+			return
+		}
+	}
+}
+
+// expireNextHold expires the oldest overdue ready hold, notifies the
+// patron and passes the copy on. It reports false when nothing was due.
+func expireNextHold(ctx context.Context) (bool, error) {
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var holdID, bookID, patronID, copyID int
+	var title string
+	err = tx.QueryRowContext(ctx, `
+		SELECT h.id, h.book_id, h.patron_id, h.copy_id, b.title
+		FROM holds h
+		JOIN books b ON b.id = h.book_id
+		WHERE h.status = $1 AND h.expires_at <= $2
+		ORDER BY h.expires_at, h.id
+		LIMIT 1
+		FOR UPDATE OF h SKIP LOCKED
+	`, models.HoldReady, time.Now()).Scan(&holdID, &bookID, &patronID, &copyID, &title)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := closeHold(tx, holdID, models.HoldExpired); err != nil {
+		return false, err
+	}
+
+	err = notifyPatron(tx, models.Notification{
+		PatronID: patronID,
+		HoldID:   &holdID,
+		Type:     models.NotificationHoldExpired,
+		Message:  fmt.Sprintf("Your hold on %q expired because it was not picked up", title),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := releaseCopy(tx, copyID, bookID, "system"); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
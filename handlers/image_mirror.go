@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/cover"
+	"book-management/models"
+	"book-management/safehttp"
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// imageMirrorInterval is how often the mirror worker looks for pending
+// images when it is not woken up by a save
+const imageMirrorInterval = 30 * time.Second
+
+// imageMirrorBatchSize is the number of pending images claimed per pass
+const imageMirrorBatchSize = 20
+
+// imageMirrorWake nudges the worker after a book is saved
+var imageMirrorWake = make(chan struct{}, 1)
+
+// imageStatusFor is the mirroring state of a newly saved image_url: external
+// URLs are queued, empty URLs and our own covers need nothing
+func imageStatusFor(imageURL string) string {
+	if imageURL == "" || strings.HasPrefix(imageURL, coverURLPrefix) {
+		return ""
+	}
+	return models.ImageStatusPending
+}
+
+// wakeImageMirror asks the worker to look for pending images now. It never
+// blocks.
+func wakeImageMirror() {
+	select {
+	case imageMirrorWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartImageMirror runs the worker that fetches external image_url values,
+// verifies they are images and mirrors them into the blob store, until ctx
+// is cancelled
+func StartImageMirror(ctx context.Context) {
+	go func() {
+		for {
+			mirrorPendingImages(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-imageMirrorWake:
+			case <-time.After(imageMirrorInterval):
+			}
+		}
+	}()
+}
+
+// mirrorPendingImages processes pending images until none are left
+func mirrorPendingImages(ctx context.Context) {
+	for ctx.Err() == nil {
+		rows, err := config.DB.QueryContext(ctx, `
+			SELECT id, COALESCE(image_url, '')
+			FROM books
+			WHERE image_status = $1
+			ORDER BY id
+			LIMIT $2
+		`, models.ImageStatusPending, imageMirrorBatchSize)
+		if err != nil {
+			log.Println("Image mirror query failed:", err)
+			return
+		}
+
+		type pendingImage struct {
+			id  int
+			url string
+		}
+		var pending []pendingImage
+		for rows.Next() {
+			var p pendingImage
+			if err := rows.Scan(&p.id, &p.url); err != nil {
+				rows.Close()
+				log.Println("Image mirror query failed:", err)
+				return
+			}
+			pending = append(pending, p)
+		}
+		rows.Close()
+
+		if len(pending) == 0 {
+			return
+		}
+
+		for _, p := range pending {
+			status, fetchErr := models.ImageStatusMirrored, ""
+			mirrorURL, err := mirrorImage(ctx, p.url)
+			if err != nil {
+				status, fetchErr = models.ImageStatusFailed, err.Error()
+			}
+
+			// Skip the result if image_url was changed while fetching
+			_, err = config.DB.ExecContext(ctx, `
+				UPDATE books
+				SET image_status = $1, image_mirror_url = $2, image_error = $3, image_checked_at = $4
+				WHERE id = $5 AND image_url = $6 AND image_status = $7
+			`, status, mirrorURL, fetchErr, time.Now(), p.id, p.url, models.ImageStatusPending)
+			if err != nil {
+				log.Println("Failed to record image mirror status:", err)
+				return
+			}
+		}
+	}
+}
+
+// mirrorImage downloads an external image, validates it like an uploaded
+// cover and stores its renditions. It returns the URL of the mirrored
+// original.
+func mirrorImage(ctx context.Context, imageURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	data, _, err := safehttp.Get(ctx, imageURL, maxCoverSize)
+	if err != nil {
+		return "", err
+	}
+
+	processed, err := cover.Process(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := storeCover(ctx, processed); err != nil {
+		return "", err
+	}
+	return coverURL(processed.Hash, processed.Filename(cover.Original)), nil
+}
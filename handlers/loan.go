@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loanColumns is the column list scanned by scanLoan. Queries select it
+// from loans l joined with copies c.
+const loanColumns = `
+	l.id, l.copy_id, c.book_id, c.barcode, l.patron_id, l.checked_out_at,
+	l.due_at, l.returned_at, l.renewals, l.checked_out_by, l.returned_by,
+	l.fine, l.overdue_at
+`
+
+// scanLoan scans a row selected with loanColumns
+func scanLoan(row rowScanner, loan *models.Loan) error {
+	var checkedOutBy, returnedBy sql.NullString
+	err := row.Scan(&loan.ID, &loan.CopyID, &loan.BookID, &loan.Barcode, &loan.PatronID,
+		&loan.CheckedOutAt, &loan.DueAt, &loan.ReturnedAt, &loan.Renewals, &checkedOutBy, &returnedBy,
+		&loan.Fine, &loan.OverdueAt)
+	loan.CheckedOutBy = checkedOutBy.String
+	loan.ReturnedBy = returnedBy.String
+	return err
+}
+
+// findLoan loads a single loan by ID
+func findLoan(q queryer, id int) (models.Loan, error) {
+	var loan models.Loan
+	err := scanLoan(q.QueryRow(`
+		SELECT `+loanColumns+`
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		WHERE l.id = $1
+	`, id), &loan)
+	return loan, err
+}
+
+// writeLoans responds with the loans matching condition, newest first.
+// active=true keeps only the copies still out.
+func writeLoans(c *gin.Context, condition string, args ...interface{}) {
+	if c.Query("active") == "true" {
+		condition += " AND l.returned_at IS NULL"
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+loanColumns+`
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		WHERE `+condition+`
+		ORDER BY l.checked_out_at DESC, l.id DESC
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch loans",
+		})
+		return
+	}
+	defer rows.Close()
+
+	loans := []models.Loan{}
+	for rows.Next() {
+		var loan models.Loan
+		if err := scanLoan(rows, &loan); err != nil {
+			continue
+		}
+		loans = append(loans, loan)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": loans,
+	})
+}
+
+// GetLoans lists every loan, newest first
+func GetLoans(c *gin.Context) {
+	writeLoans(c, "TRUE")
+}
+
+// CheckoutCopy lends a copy to a patron. The copy must be available and
+// the patron active, below their loan limit and not owing too much in fines.
+func CheckoutCopy(c *gin.Context) {
+	var input models.CheckoutInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var copyID, bookID int
+	var status string
+	err = tx.QueryRow("SELECT id, book_id, status FROM copies WHERE barcode = $1 FOR UPDATE", input.Barcode).Scan(&copyID, &bookID, &status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Copy not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check out copy",
+		})
+		return
+	}
+
+	// A copy set aside for a hold only goes to the patron who placed it
+	if status == models.CopyOnHold {
+		var holder int
+		err = tx.QueryRow("SELECT patron_id FROM holds WHERE copy_id = $1 AND status = $2", copyID, models.HoldReady).Scan(&holder)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check out copy",
+			})
+			return
+		}
+		if err == nil && holder != input.PatronID {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Copy is on hold for another patron",
+			})
+			return
+		}
+	} else if status != models.CopyAvailable {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Copy is not available (" + status + ")",
+		})
+		return
+	}
+
+	// Locking the patron keeps two desks from going over the loan limit
+	patron, err := scanPatronForUpdate(tx, input.PatronID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check out copy",
+		})
+		return
+	}
+
+	if patron.Status != models.PatronActive {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Patron is " + patron.Status,
+		})
+		return
+	}
+
+	balance, err := patronBalance(tx, patron.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check out copy",
+		})
+		return
+	}
+
+	if fines := models.CurrentFineRules(); fines.Blocks(balance) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("Patron owes %d in fines, above the limit of %d", balance, fines.BlockThreshold),
+		})
+		return
+	}
+
+	rules := models.CurrentCirculationRules()
+	limit := rules.LoanLimit
+	if patron.LoanLimit != nil {
+		limit = *patron.LoanLimit
+	}
+
+	var active int
+	err = tx.QueryRow("SELECT COUNT(*) FROM loans WHERE patron_id = $1 AND returned_at IS NULL", patron.ID).Scan(&active)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check out copy",
+		})
+		return
+	}
+
+	if active >= limit {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Patron has reached the loan limit of %d", limit),
+		})
+		return
+	}
+
+	now := time.Now()
+	var loanID int
+	err = tx.QueryRow(`
+		INSERT INTO loans (copy_id, patron_id, checked_out_at, due_at, checked_out_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, copyID, patron.ID, now, now.Add(rules.LoanPeriod()), usernameStr).Scan(&loanID)
+	if err == nil {
+		err = setCopyStatus(tx, copyID, models.CopyOnLoan, usernameStr)
+	}
+	if err == nil {
+		err = fulfillHold(tx, bookID, patron.ID, copyID, usernameStr)
+	}
+	if err == nil {
+		err = queueBorrowerPairs(tx, patron.ID, bookID)
+	}
+
+	var loan models.Loan
+	if err == nil {
+		loan, err = findLoan(tx, loanID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check out copy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Copy checked out successfully",
+		"data":    loan,
+	})
+}
+
+// scanPatronForUpdate loads a patron and locks it until the transaction ends
+func scanPatronForUpdate(q queryer, id int) (models.Patron, error) {
+	var patron models.Patron
+	err := scanPatron(q.QueryRow(`
+		SELECT `+patronColumns+`
+		FROM patrons
+		WHERE id = $1
+		FOR UPDATE
+	`, id), &patron)
+	return patron, err
+}
+
+// fulfillHold closes the active hold of a patron on a book once they
+// check out a copy of it. A different copy set aside for them is passed on.
+func fulfillHold(q queryer, bookID, patronID, copyID int, username string) error {
+	var holdID int
+	var heldCopy sql.NullInt64
+	err := q.QueryRow(`
+		SELECT id, copy_id
+		FROM holds
+		WHERE book_id = $1 AND patron_id = $2 AND status IN ($3, $4)
+		FOR UPDATE
+	`, bookID, patronID, models.HoldPending, models.HoldReady).Scan(&holdID, &heldCopy)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := closeHold(q, holdID, models.HoldFulfilled); err != nil {
+		return err
+	}
+	if heldCopy.Valid && int(heldCopy.Int64) != copyID {
+		_, err = releaseCopy(q, int(heldCopy.Int64), bookID, username)
+	}
+	return err
+}
+
+// setCopyStatus moves a copy to another circulation state
+func setCopyStatus(q queryer, copyID int, status, username string) error {
+	_, err := q.Exec(`
+		UPDATE copies
+		SET status = $1, modified_at = $2, modified_by = $3, version = version + 1
+		WHERE id = $4
+	`, status, time.Now(), username, copyID)
+	return err
+}
+
+// ReturnCopy ends the active loan of a copy and makes it available again,
+// or sets it aside for the next hold on the book. on_hold in the response
+// tells the desk to shelve it for pickup.
+func ReturnCopy(c *gin.Context) {
+	var input models.ReturnInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var copyID, bookID int
+	err = tx.QueryRow("SELECT id, book_id FROM copies WHERE barcode = $1 FOR UPDATE", input.Barcode).Scan(&copyID, &bookID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Copy not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to return copy",
+		})
+		return
+	}
+
+	now := time.Now()
+	var loanID int
+	err = tx.QueryRow(`
+		UPDATE loans
+		SET returned_at = $1, returned_by = $2
+		WHERE copy_id = $3 AND returned_at IS NULL
+		RETURNING id
+	`, now, usernameStr, copyID).Scan(&loanID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Copy is not on loan",
+		})
+		return
+	}
+
+	// A late return settles the fine at the day it came back
+	if err == nil {
+		err = accrueFine(tx, loanID, now, false)
+	}
+
+	// The copy goes straight to the next hold in line, if any
+	var onHold bool
+	if err == nil {
+		onHold, err = releaseCopy(tx, copyID, bookID, usernameStr)
+	}
+
+	var loan models.Loan
+	if err == nil {
+		loan, err = findLoan(tx, loanID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to return copy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Copy returned successfully",
+		"data":    loan,
+		"on_hold": onHold,
+	})
+}
+
+// RenewLoan extends an active loan by another loan period, up to
+// MAX_RENEWALS times. Overdue loans and books with patrons waiting cannot
+// be renewed.
+func RenewLoan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid loan ID",
+		})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var returned, overdue, waiting bool
+	var renewals int
+	err = tx.QueryRow(`
+		SELECT l.returned_at IS NOT NULL, l.renewals, l.due_at < $3,
+		       EXISTS(SELECT 1 FROM holds h WHERE h.book_id = c.book_id AND h.status = $2)
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		WHERE l.id = $1
+		FOR UPDATE OF l
+	`, id, models.HoldPending, time.Now()).Scan(&returned, &renewals, &overdue, &waiting)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Loan not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to renew loan",
+		})
+		return
+	}
+
+	if returned {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Loan has already been returned",
+		})
+		return
+	}
+
+	if overdue {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Overdue loans cannot be renewed",
+		})
+		return
+	}
+
+	if waiting {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Loan cannot be renewed while other patrons are waiting for the book",
+		})
+		return
+	}
+
+	rules := models.CurrentCirculationRules()
+	if renewals >= rules.MaxRenewals {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Loan has reached the limit of %d renewals", rules.MaxRenewals),
+		})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE loans
+		SET due_at = $1, renewals = renewals + 1
+		WHERE id = $2
+	`, time.Now().Add(rules.LoanPeriod()), id)
+
+	var loan models.Loan
+	if err == nil {
+		loan, err = findLoan(tx, id)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to renew loan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Loan renewed successfully",
+		"data":    loan,
+	})
+}
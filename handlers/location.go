@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// locationColumns is the column list scanned by scanLocation
+const locationColumns = `
+	id, code, name, address, created_at, created_by, modified_at, modified_by, version
+`
+
+// scanLocation scans a row selected with locationColumns
+func scanLocation(row rowScanner, location *models.Location) error {
+	var createdBy, modifiedBy sql.NullString
+	err := row.Scan(&location.ID, &location.Code, &location.Name, &location.Address,
+		&location.CreatedAt, &createdBy, &location.ModifiedAt, &modifiedBy, &location.Version)
+	location.CreatedBy = createdBy.String
+	location.ModifiedBy = modifiedBy.String
+	return err
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key
+// violation, e.g. deleting a row that is still referenced
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// GetAllLocations retrieves all stock locations
+func GetAllLocations(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT ` + locationColumns + `
+		FROM locations
+		ORDER BY code
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch locations",
+		})
+		return
+	}
+	defer rows.Close()
+
+	locations := []models.Location{}
+	for rows.Next() {
+		var location models.Location
+		if err := scanLocation(rows, &location); err != nil {
+			continue
+		}
+		locations = append(locations, location)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": locations,
+	})
+}
+
+// CreateLocation creates a new stock location
+func CreateLocation(c *gin.Context) {
+	var input models.LocationInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var location models.Location
+	err := scanLocation(config.DB.QueryRow(`
+		INSERT INTO locations (code, name, address, created_at, created_by, modified_at, modified_by)
+		VALUES ($1, $2, $3, $4, $5, $4, $5)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING `+locationColumns,
+		strings.ToUpper(input.Code), input.Name, input.Address, time.Now(), usernameStr,
+	), &location)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A location with this code already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create location",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(location.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Location created successfully",
+		"data":    location,
+	})
+}
+
+// GetLocationByID retrieves a stock location by ID
+func GetLocationByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid location ID",
+		})
+		return
+	}
+
+	var location models.Location
+	err = scanLocation(config.DB.QueryRow(`
+		SELECT `+locationColumns+`
+		FROM locations
+		WHERE id = $1
+	`, id), &location)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Location not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch location",
+		})
+		return
+	}
+
+	if notModified(c, location.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": location,
+	})
+}
+
+// UpdateLocation updates a stock location by ID
+func UpdateLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid location ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var input models.LocationInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var version int
+	err = config.DB.QueryRow(`
+		UPDATE locations
+		SET code = $1, name = $2, address = $3, modified_at = $4, modified_by = $5, version = version + 1
+		WHERE id = $6 AND ($7::bigint[] IS NULL OR version = ANY($7))
+		RETURNING version
+	`, strings.ToUpper(input.Code), input.Name, input.Address, time.Now(), usernameStr, id, pq.Array(versions)).Scan(&version)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "locations", id, "Location not found")
+		return
+	}
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A location with this code already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update location",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Location updated successfully",
+	})
+}
+
+// DeleteLocation deletes a stock location. Locations with stock movements
+// are kept, since the ledger must stay complete.
+func DeleteLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid location ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var used bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM stock_movements WHERE location_id = $1)", id).Scan(&used)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete location",
+		})
+		return
+	}
+
+	if used {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Location has stock movements and cannot be deleted",
+		})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM locations
+		WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))
+	`, id, pq.Array(versions))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete location",
+		})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		preconditionFailed(c, "locations", id, "Location not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Location deleted successfully",
+	})
+}
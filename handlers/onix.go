@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"book-management/onix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readONIXUpload parses the ONIX message uploaded in the "file" form field.
+// When it cannot be read a response is written and false is returned.
+func readONIXUpload(c *gin.Context) (onix.Message, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "File is required",
+		})
+		return onix.Message{}, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read file",
+		})
+		return onix.Message{}, false
+	}
+	defer file.Close()
+
+	message, err := onix.Read(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to parse ONIX: " + err.Error(),
+		})
+		return onix.Message{}, false
+	}
+	return message, true
+}
+
+// ValidateONIX validates an uploaded ONIX 3.0 message without importing it
+func ValidateONIX(c *gin.Context) {
+	message, ok := readONIXUpload(c)
+	if !ok {
+		return
+	}
+
+	errs := onix.Validate(message)
+	c.JSON(http.StatusOK, gin.H{
+		"valid":    len(errs) == 0,
+		"products": len(message.Products),
+		"errors":   errs,
+	})
+}
+
+// ImportONIX imports products from a publisher's ONIX 3.0 message.
+//
+// Form fields:
+//   - file: the ONIX 3.0 XML file (reference tags)
+//   - category_id: category for products whose subject is not a known category
+//   - create_categories: create categories named by product subjects
+//   - dry_run: only report what would be created, updated or rejected
+//
+// Products that fail validation are rejected; the original <Product> XML is
+// kept in raw_metadata.
+func ImportONIX(c *gin.Context) {
+	message, ok := readONIXUpload(c)
+	if !ok {
+		return
+	}
+
+	defaultCategoryID := 0
+	if raw := c.PostForm("category_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "category_id must be a number",
+			})
+			return
+		}
+		defaultCategoryID = id
+	}
+
+	createCategories := c.PostForm("create_categories") == "true"
+	dryRun := c.PostForm("dry_run") == "true"
+
+	if len(message.Products) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Message does not contain any products",
+		})
+		return
+	}
+
+	categories, err := categoryIDsByName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+
+	if defaultCategoryID != 0 && !categoryKnown(categories, defaultCategoryID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid category ID - category does not exist",
+		})
+		return
+	}
+
+	// Group validation errors by product
+	invalid := make(map[string][]string)
+	for _, e := range onix.Validate(message) {
+		if e.Product != "" {
+			invalid[e.Product] = append(invalid[e.Product], e.Path+": "+e.Message)
+		}
+	}
+
+	rows := make([]importRow, 0, len(message.Products))
+	for i, product := range message.Products {
+		row := importRow{Row: i + 1, Action: importCreate}
+
+		ref := product.RecordReference
+		if ref == "" {
+			ref = fmt.Sprintf("Product[%d]", i+1)
+		}
+		row.Errors = append(row.Errors, invalid[ref]...)
+
+		if product.NotificationType == onix.NotificationDelete {
+			row.Errors = append(row.Errors, "delete notifications are not applied - remove the book manually")
+		}
+
+		row.Data, row.Category = onix.ToBookInput(product)
+		row.provided = providedFields(row.Data)
+		if id, found := categories[strings.ToLower(strings.TrimSpace(row.Category))]; found && row.Category != "" {
+			row.Data.CategoryID = id
+			row.provided["category_id"] = true
+		} else if row.Category != "" && createCategories {
+			row.newCategory = row.Category
+			row.provided["category_id"] = true
+		} else if defaultCategoryID != 0 {
+			row.Data.CategoryID = defaultCategoryID
+		} else {
+			row.Errors = append(row.Errors, fmt.Sprintf("category %q does not exist and no category_id was given", row.Category))
+		}
+
+		validated := row.Data
+		if row.newCategory != "" {
+			validated.CategoryID = -1
+		}
+		if err := validateInput(&validated); err != nil {
+			row.Errors = append(row.Errors, errorMessages(err)...)
+		}
+
+		raw, err := json.Marshal(map[string]string{"onix": "<Product>" + product.Raw + "</Product>"})
+		if err == nil {
+			row.rawMetadata = raw
+		}
+
+		if len(row.Errors) > 0 {
+			row.Action = importReject
+		}
+		rows = append(rows, row)
+	}
+
+	if err := matchImportRows(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to match existing books",
+		})
+		return
+	}
+
+	newCategories := plannedCategories(rows)
+
+	if dryRun {
+		respondImport(c, true, rows, newCategories)
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	if err := commitImport(rows, newCategories, usernameStr); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Import failed, no changes were made: " + err.Error(),
+		})
+		return
+	}
+
+	respondImport(c, false, rows, newCategories)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"book-management/opds"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// opdsBase is the path the OPDS catalog is mounted on
+const opdsBase = "/api/opds"
+
+// writeOPDS writes an Atom or OpenSearch document with the given media type
+func writeOPDS(c *gin.Context, contentType string, doc interface{}) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build feed",
+		})
+		return
+	}
+	c.Data(http.StatusOK, contentType, append([]byte(xml.Header), body...))
+}
+
+// writeOPDS2 writes an OPDS 2.0 JSON document
+func writeOPDS2(c *gin.Context, doc interface{}) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build feed",
+		})
+		return
+	}
+	c.Data(http.StatusOK, opds.OPDS2Type, body)
+}
+
+// opdsCategories loads every category for navigation and entry subjects
+func opdsCategories() ([]models.Category, map[int]string, error) {
+	rows, err := config.DB.Query(`
+		SELECT id, name, modified_at
+		FROM categories
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	names := make(map[int]string)
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.ModifiedAt); err != nil {
+			return nil, nil, err
+		}
+		categories = append(categories, category)
+		names[category.ID] = category.Name
+	}
+	return categories, names, rows.Err()
+}
+
+// opdsFeed is the data of one page of an acquisition feed
+type opdsFeed struct {
+	page       opds.Page
+	books      []models.Book
+	categories map[int]string
+}
+
+// loadOPDSFeed fetches one page of books for an acquisition feed, using the
+// same page and page_size parameters as the book list. When the feed cannot
+// be loaded a response is written and false is returned.
+func loadOPDSFeed(c *gin.Context, path, title, feedID string, filter bookFilter, query url.Values) (opdsFeed, bool) {
+	page, _, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return opdsFeed{}, false
+	}
+
+	books, total, err := queryBooks(filter, &page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch books",
+		})
+		return opdsFeed{}, false
+	}
+
+	_, names, err := opdsCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return opdsFeed{}, false
+	}
+
+	return opdsFeed{
+		page: opds.Page{
+			Path:   path,
+			Query:  query,
+			Number: page.Page,
+			Size:   page.PageSize,
+			Total:  total,
+			Title:  title,
+			FeedID: feedID,
+		},
+		books:      books,
+		categories: names,
+	}, true
+}
+
+// categoryOPDSFeed loads the acquisition feed of the category in the :id param
+func categoryOPDSFeed(c *gin.Context, path string) (opdsFeed, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid category ID",
+		})
+		return opdsFeed{}, false
+	}
+
+	var name string
+	err = config.DB.QueryRow("SELECT name FROM categories WHERE id = $1", id).Scan(&name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return opdsFeed{}, false
+	}
+
+	return loadOPDSFeed(c, path+"/"+strconv.Itoa(id), name,
+		"urn:book-management:category:"+strconv.Itoa(id), bookFilter{CategoryID: id}, nil)
+}
+
+// searchOPDSFeed loads the acquisition feed for the q (or query) parameter
+func searchOPDSFeed(c *gin.Context, path string) (opdsFeed, bool) {
+	term := c.Query("q")
+	if term == "" {
+		term = c.Query("query")
+	}
+
+	return loadOPDSFeed(c, path, "Search: "+term, "urn:book-management:search:"+url.QueryEscape(term),
+		bookFilter{Search: term}, url.Values{"q": {term}})
+}
+
+// GetOPDSRoot serves the OPDS 1.2 navigation feed
+func GetOPDSRoot(c *gin.Context) {
+	categories, _, err := opdsCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+	writeOPDS(c, opds.NavigationType, opds.NavigationFeed(opdsBase, categories))
+}
+
+// GetOPDSBooks serves the OPDS 1.2 acquisition feed of all books
+func GetOPDSBooks(c *gin.Context) {
+	feed, ok := loadOPDSFeed(c, opdsBase+"/books", "All books", "urn:book-management:books", bookFilter{}, nil)
+	if ok {
+		writeOPDS(c, opds.AcquisitionType, opds.AcquisitionFeed(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
+
+// GetOPDSCategoryBooks serves the OPDS 1.2 acquisition feed of a category
+func GetOPDSCategoryBooks(c *gin.Context) {
+	feed, ok := categoryOPDSFeed(c, opdsBase+"/categories")
+	if ok {
+		writeOPDS(c, opds.AcquisitionType, opds.AcquisitionFeed(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
+
+// SearchOPDS serves OPDS 1.2 search results
+func SearchOPDS(c *gin.Context) {
+	feed, ok := searchOPDSFeed(c, opdsBase+"/search")
+	if ok {
+		writeOPDS(c, opds.AcquisitionType, opds.AcquisitionFeed(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
+
+// GetOpenSearchDescription serves the OpenSearch descriptor of the catalog
+func GetOpenSearchDescription(c *gin.Context) {
+	writeOPDS(c, opds.OpenSearchType, opds.NewOpenSearchDescription(opdsBase))
+}
+
+// GetOPDS2Root serves the OPDS 2.0 navigation feed
+func GetOPDS2Root(c *gin.Context) {
+	categories, _, err := opdsCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+	writeOPDS2(c, opds.NavigationFeed2(opdsBase, categories))
+}
+
+// GetOPDS2Books serves the OPDS 2.0 feed of all books
+func GetOPDS2Books(c *gin.Context) {
+	feed, ok := loadOPDSFeed(c, opdsBase+"/v2/books", "All books", "urn:book-management:books", bookFilter{}, nil)
+	if ok {
+		writeOPDS2(c, opds.AcquisitionFeed2(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
+
+// GetOPDS2CategoryBooks serves the OPDS 2.0 feed of a category
+func GetOPDS2CategoryBooks(c *gin.Context) {
+	feed, ok := categoryOPDSFeed(c, opdsBase+"/v2/categories")
+	if ok {
+		writeOPDS2(c, opds.AcquisitionFeed2(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
+
+// SearchOPDS2 serves OPDS 2.0 search results
+func SearchOPDS2(c *gin.Context) {
+	feed, ok := searchOPDSFeed(c, opdsBase+"/v2/search")
+	if ok {
+		writeOPDS2(c, opds.AcquisitionFeed2(opdsBase, feed.page, feed.books, feed.categories))
+	}
+}
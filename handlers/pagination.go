@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pagination is a 1-based page of a list
+type pagination struct {
+	Page     int
+	PageSize int
+}
+
+// parsePagination reads page and page_size from the query string.
+// enabled is false when neither parameter is present, in which case list
+// endpoints keep returning every row.
+func parsePagination(c *gin.Context) (p pagination, enabled bool, err error) {
+	p = pagination{Page: 1, PageSize: defaultPageSize}
+	rawPage, hasPage := c.GetQuery("page")
+	rawSize, hasSize := c.GetQuery("page_size")
+
+	if hasPage {
+		if p.Page, err = strconv.Atoi(rawPage); err != nil || p.Page < 1 {
+			return p, true, errors.New("page must be a positive number")
+		}
+	}
+	if hasSize {
+		if p.PageSize, err = strconv.Atoi(rawSize); err != nil || p.PageSize < 1 || p.PageSize > maxPageSize {
+			return p, true, errors.New("page_size must be between 1 and 100")
+		}
+	}
+	return p, hasPage || hasSize, nil
+}
+
+// offset is the number of rows skipped before the page
+func (p pagination) offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// totalPages is the number of pages needed for total rows
+func (p pagination) totalPages(total int) int {
+	if total == 0 {
+		return 1
+	}
+	return (total + p.PageSize - 1) / p.PageSize
+}
+
+// meta describes the page for list responses
+func (p pagination) meta(total int) gin.H {
+	return gin.H{
+		"page":        p.Page,
+		"page_size":   p.PageSize,
+		"total":       total,
+		"total_pages": p.totalPages(total),
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// applyPatch applies the request body to original according to the request
+// Content-Type (JSON Merge Patch or JSON Patch) and decodes the result into out.
+// When the patch cannot be applied a response is written and false is returned.
+func applyPatch(c *gin.Context, original interface{}, out interface{}) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read request body",
+		})
+		return false
+	}
+
+	doc, err := json.Marshal(original)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to prepare patch",
+		})
+		return false
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case mergePatchContentType:
+		patched, err = jsonpatch.MergePatch(doc, body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid merge patch: " + err.Error(),
+			})
+			return false
+		}
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid JSON patch: " + err.Error(),
+			})
+			return false
+		}
+		patched, err = patch.Apply(doc)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Failed to apply JSON patch: " + err.Error(),
+			})
+			return false
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Content-Type must be " + mergePatchContentType + " or " + jsonPatchContentType,
+		})
+		return false
+	}
+
+	if err := json.Unmarshal(patched, out); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+
+	// Patched documents go through the same binding rules as PUT
+	if err := validateInput(out); err != nil {
+		validationFailed(c, err)
+		return false
+	}
+
+	return true
+}
+
+// PatchBook partially updates a book by ID
+func PatchBook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	book, err := findBook(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return
+	}
+
+	if !versionMatches(versions, book.Version) {
+		c.Header("ETag", formatETag(book.Version))
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Resource has been modified by another request",
+		})
+		return
+	}
+
+	var input models.BookInput
+	if !applyPatch(c, book.Input(), &input) {
+		return
+	}
+
+	// Check if category exists
+	if input.CategoryID != book.CategoryID {
+		var categoryExists bool
+		err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)", input.CategoryID).Scan(&categoryExists)
+		if err != nil || !categoryExists {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid category ID - category does not exist",
+			})
+			return
+		}
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	// Only recalculate thickness when the page count changed
+	thickness := book.Thickness
+	if input.TotalPage != book.TotalPage {
+		thickness = input.CalculateThickness()
+	}
+
+	// Pin the write to the version the patch was applied to
+	version, err := updateBookRow(config.DB, id, input, thickness, usernameStr, []int64{int64(book.Version)})
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", id, "Book not found")
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update book",
+		})
+		return
+	}
+
+	wakeImageMirror()
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Book updated successfully",
+		"thickness": thickness,
+	})
+}
+
+// PatchCategory partially updates a category by ID
+func PatchCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid category ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var category models.Category
+	err = config.DB.QueryRow(`
+		SELECT id, name, version
+		FROM categories
+		WHERE id = $1
+	`, id).Scan(&category.ID, &category.Name, &category.Version)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch category",
+		})
+		return
+	}
+
+	if !versionMatches(versions, category.Version) {
+		c.Header("ETag", formatETag(category.Version))
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Resource has been modified by another request",
+		})
+		return
+	}
+
+	var input models.CategoryInput
+	if !applyPatch(c, category.Input(), &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var version int
+	err = config.DB.QueryRow(`
+		UPDATE categories
+		SET name = $1, modified_at = $2, modified_by = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`, input.Name, time.Now(), usernameStr, id, category.Version).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "categories", id, "Category not found")
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update category",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Category updated successfully",
+	})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// patronColumns is the column list scanned by scanPatron
+const patronColumns = `
+	id, card_number, name, email, username, loan_limit, status,
+	created_at, created_by, modified_at, modified_by, version
+`
+
+// scanPatron scans a row selected with patronColumns
+func scanPatron(row rowScanner, patron *models.Patron) error {
+	var username, createdBy, modifiedBy sql.NullString
+	var loanLimit sql.NullInt64
+	err := row.Scan(&patron.ID, &patron.CardNumber, &patron.Name, &patron.Email, &username,
+		&loanLimit, &patron.Status, &patron.CreatedAt, &createdBy, &patron.ModifiedAt,
+		&modifiedBy, &patron.Version)
+	if err != nil {
+		return err
+	}
+
+	patron.Username = username.String
+	patron.CreatedBy = createdBy.String
+	patron.ModifiedBy = modifiedBy.String
+	if loanLimit.Valid {
+		limit := int(loanLimit.Int64)
+		patron.LoanLimit = &limit
+	}
+	return nil
+}
+
+// findPatron loads a single patron by ID
+func findPatron(q queryer, id int) (models.Patron, error) {
+	var patron models.Patron
+	err := scanPatron(q.QueryRow(`
+		SELECT `+patronColumns+`
+		FROM patrons
+		WHERE id = $1
+	`, id), &patron)
+	return patron, err
+}
+
+// nullIfEmpty stores an empty string as NULL, for optional unique columns
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// GetAllPatrons retrieves all patrons
+func GetAllPatrons(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT ` + patronColumns + `
+		FROM patrons
+		ORDER BY name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch patrons",
+		})
+		return
+	}
+	defer rows.Close()
+
+	patrons := []models.Patron{}
+	for rows.Next() {
+		var patron models.Patron
+		if err := scanPatron(rows, &patron); err != nil {
+			continue
+		}
+		patrons = append(patrons, patron)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": patrons,
+	})
+}
+
+// CreatePatron registers a new patron
+func CreatePatron(c *gin.Context) {
+	var input models.PatronInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = models.PatronActive
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var patron models.Patron
+	err := scanPatron(config.DB.QueryRow(`
+		INSERT INTO patrons (card_number, name, email, username, loan_limit, status, created_at, created_by, modified_at, modified_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $7, $8)
+		RETURNING `+patronColumns,
+		input.CardNumber, input.Name, input.Email, nullIfEmpty(input.Username), input.LoanLimit,
+		input.Status, time.Now(), usernameStr,
+	), &patron)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A patron with this card number or username already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create patron",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(patron.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Patron created successfully",
+		"data":    patron,
+	})
+}
+
+// GetPatronByID retrieves a patron by ID
+func GetPatronByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	patron, err := findPatron(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch patron",
+		})
+		return
+	}
+
+	if notModified(c, patron.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": patron,
+	})
+}
+
+// UpdatePatron updates a patron by ID
+func UpdatePatron(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	versions, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var input models.PatronInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = models.PatronActive
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var version int
+	err = config.DB.QueryRow(`
+		UPDATE patrons
+		SET card_number = $1, name = $2, email = $3, username = $4, loan_limit = $5,
+		    status = $6, modified_at = $7, modified_by = $8, version = version + 1
+		WHERE id = $9 AND ($10::bigint[] IS NULL OR version = ANY($10))
+		RETURNING version
+	`, input.CardNumber, input.Name, input.Email, nullIfEmpty(input.Username), input.LoanLimit,
+		input.Status, time.Now(), usernameStr, id, pq.Array(versions)).Scan(&version)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "patrons", id, "Patron not found")
+		return
+	}
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A patron with this card number or username already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update patron",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Patron updated successfully",
+	})
+}
+
+// GetPatronLoans lists the loans of a patron, newest first. active=true
+// keeps only the copies still out.
+func GetPatronLoans(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid patron ID",
+		})
+		return
+	}
+
+	if _, err := findPatron(config.DB, id); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Patron not found",
+		})
+		return
+	}
+
+	writeLoans(c, "l.patron_id = $1", id)
+}
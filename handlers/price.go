@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"book-management/money"
+	"database/sql"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// marketPattern matches a market code such as "ID", "US" or "EU"
+var marketPattern = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+// loadBookPrices loads the market prices of the given books keyed by book ID
+func loadBookPrices(q queryer, ids []int64) (map[int][]models.BookPrice, error) {
+	rows, err := q.Query(`
+		SELECT book_id, market, currency, amount_minor, modified_at, modified_by
+		FROM book_prices
+		WHERE book_id = ANY($1)
+		ORDER BY book_id, market
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[int][]models.BookPrice)
+	for rows.Next() {
+		var bookID int
+		var price models.BookPrice
+		var minor int64
+		var modifiedBy sql.NullString
+		err := rows.Scan(&bookID, &price.Market, &price.Currency, &minor, &price.ModifiedAt, &modifiedBy)
+		if err != nil {
+			return nil, err
+		}
+		price.Price = money.NewPrice(price.Currency, minor)
+		price.ModifiedBy = modifiedBy.String
+		prices[bookID] = append(prices[bookID], price)
+	}
+	return prices, rows.Err()
+}
+
+// requestedCurrency reads the currency parameter. It is empty when no
+// conversion was asked for; when the currency cannot be converted a
+// response is written and false is returned.
+func requestedCurrency(c *gin.Context) (string, bool) {
+	currency := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+	if currency == "" {
+		return "", true
+	}
+
+	if !money.Current().Has(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No exchange rate for currency " + currency,
+		})
+		return "", false
+	}
+	return currency, true
+}
+
+// attachPrices loads the market prices of books and, when currency is set,
+// their price in that currency
+func attachPrices(books []models.Book, currency string) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(books))
+	for i, book := range books {
+		ids[i] = int64(book.ID)
+	}
+
+	prices, err := loadBookPrices(config.DB, ids)
+	if err != nil {
+		return err
+	}
+
+	rates := money.Current()
+	for i := range books {
+		books[i].Prices = prices[books[i].ID]
+		if currency == "" {
+			continue
+		}
+		if books[i].DisplayPrice, err = displayPrice(books[i], currency, rates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// displayPrice is the price of book in currency: its own price in that
+// currency when it has one, otherwise the converted base price
+func displayPrice(book models.Book, currency string, rates *money.Rates) (*models.DisplayPrice, error) {
+	if book.Currency == currency {
+		return &models.DisplayPrice{Price: money.NewPrice(currency, book.PriceMinor)}, nil
+	}
+	for _, price := range book.Prices {
+		if price.Currency == currency {
+			return &models.DisplayPrice{Price: price.Price, Market: price.Market}, nil
+		}
+	}
+
+	minor, err := rates.Convert(book.PriceMinor, book.Currency, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DisplayPrice{Price: money.NewPrice(currency, minor), Converted: true}, nil
+}
+
+// GetBookPrices shows the price timeline of a book: its current base and
+// market prices, every earlier price and the changes still scheduled
+func GetBookPrices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	book, err := findBook(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return
+	}
+
+	prices, err := loadBookPrices(config.DB, []int64{int64(id)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch prices",
+		})
+		return
+	}
+
+	markets := prices[id]
+	if markets == nil {
+		markets = []models.BookPrice{}
+	}
+
+	history, err := loadPriceHistory(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch price history",
+		})
+		return
+	}
+
+	scheduled, err := loadScheduledPrices(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch scheduled prices",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(book.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"base":      money.NewPrice(book.Currency, book.PriceMinor),
+		"data":      markets,
+		"history":   history,
+		"scheduled": scheduled,
+	})
+}
+
+// bookPriceParams reads the book ID and market of a price route. When one
+// is invalid a response is written and false is returned.
+func bookPriceParams(c *gin.Context) (int, string, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return 0, "", false
+	}
+
+	market := strings.ToUpper(c.Param("market"))
+	if !marketPattern.MatchString(market) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "market must be 2 to 10 letters or digits",
+		})
+		return 0, "", false
+	}
+	return id, market, true
+}
+
+// touchBook gives a book a new version after one of its sub-resources
+// changed. sql.ErrNoRows is returned when the book is missing or its
+// version did not match.
+func touchBook(q queryer, id int, username string, versions []int64) (int, error) {
+	var version int
+	err := q.QueryRow(`
+		UPDATE books
+		SET modified_at = $1, modified_by = $2, version = version + 1
+		WHERE id = $3 AND ($4::bigint[] IS NULL OR version = ANY($4))
+		RETURNING version
+	`, time.Now(), username, id, pq.Array(versions)).Scan(&version)
+	return version, err
+}
+
+// upsertBookPrice sets the price of a book in a market and records it in
+// the price history as effective from at
+func upsertBookPrice(q queryer, bookID int, market, currency string, minor int64, at time.Time, username string) error {
+	_, err := q.Exec(`
+		INSERT INTO book_prices (book_id, market, currency, amount_minor, modified_at, modified_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (book_id, market) DO UPDATE
+		SET currency = EXCLUDED.currency, amount_minor = EXCLUDED.amount_minor,
+		    modified_at = EXCLUDED.modified_at, modified_by = EXCLUDED.modified_by
+	`, bookID, market, currency, minor, time.Now(), username)
+	if err != nil {
+		return err
+	}
+	return recordPrice(q, bookID, market, currency, minor, at, username)
+}
+
+// SetBookPrice sets the price of a book in a market. Prices are part of
+// the book, so its version changes; If-Match is honoured when sent.
+func SetBookPrice(c *gin.Context) {
+	id, market, ok := bookPriceParams(c)
+	if !ok {
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	var input models.BookPriceInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if !money.ValidCode(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "currency must be an ISO 4217 code",
+		})
+		return
+	}
+
+	minor, err := money.Parse(input.Amount, currency)
+	if err != nil || minor < 0 {
+		message := "amount must not be negative"
+		if err != nil {
+			message = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": message,
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	version, err := touchBook(tx, id, usernameStr, versions)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", id, "Book not found")
+		return
+	}
+
+	if err == nil {
+		err = upsertBookPrice(tx, id, market, currency, minor, time.Now(), usernameStr)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to set price",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Price set successfully",
+		"data": models.BookPrice{
+			Market:     market,
+			Price:      money.NewPrice(currency, minor),
+			ModifiedAt: time.Now(),
+			ModifiedBy: usernameStr,
+		},
+	})
+}
+
+// DeleteBookPrice removes the price of a book in a market
+func DeleteBookPrice(c *gin.Context) {
+	id, market, ok := bookPriceParams(c)
+	if !ok {
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM book_prices WHERE book_id = $1 AND market = $2", id, market)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete price",
+		})
+		return
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Price not found",
+		})
+		return
+	}
+
+	version, err := touchBook(tx, id, usernameStr, versions)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "books", id, "Book not found")
+		return
+	}
+
+	if err == nil {
+		err = closePrice(tx, id, market, time.Now())
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete price",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Price deleted successfully",
+	})
+}
+
+// queryExchangeRates reads the rate table
+func queryExchangeRates(q queryer) ([]models.ExchangeRate, error) {
+	rows, err := q.Query(`
+		SELECT currency, rate::text, modified_at, modified_by
+		FROM exchange_rates
+		ORDER BY currency
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		var modifiedBy sql.NullString
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.ModifiedAt, &modifiedBy); err != nil {
+			return nil, err
+		}
+		rate.ModifiedBy = modifiedBy.String
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// LoadExchangeRates makes the stored rates the ones used for conversion.
+// It is called at startup and after every change.
+func LoadExchangeRates() error {
+	stored, err := queryExchangeRates(config.DB)
+	if err != nil {
+		return err
+	}
+
+	rates := make(map[string]*big.Rat, len(stored))
+	for _, rate := range stored {
+		value, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok {
+			continue
+		}
+		rates[rate.Currency] = value
+	}
+	money.SetRates(money.NewRates(rates))
+	return nil
+}
+
+// GetExchangeRates lists the conversion rates
+func GetExchangeRates(c *gin.Context) {
+	rates, err := queryExchangeRates(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch exchange rates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base": money.BaseCurrency,
+		"data": rates,
+	})
+}
+
+// UpdateExchangeRates sets the listed rates. Each rate is the value of one
+// unit of the currency in the base currency, whose own rate stays 1.
+func UpdateExchangeRates(c *gin.Context) {
+	var input models.ExchangeRatesInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	for i, rate := range input.Rates {
+		currency := strings.ToUpper(rate.Currency)
+		value, ok := new(big.Rat).SetString(strings.TrimSpace(rate.Rate))
+		if !money.ValidCode(currency) || currency == money.BaseCurrency || !ok || value.Sign() <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "rates[" + strconv.Itoa(i) + "] must be an ISO 4217 code other than " +
+					money.BaseCurrency + " with a positive decimal rate",
+			})
+			return
+		}
+		input.Rates[i].Currency = currency
+		input.Rates[i].Rate = value.FloatString(12)
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, rate := range input.Rates {
+		_, err := tx.Exec(`
+			INSERT INTO exchange_rates (currency, rate, modified_at, modified_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (currency) DO UPDATE
+			SET rate = EXCLUDED.rate, modified_at = EXCLUDED.modified_at, modified_by = EXCLUDED.modified_by
+		`, rate.Currency, rate.Rate, time.Now(), usernameStr)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update exchange rates",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update exchange rates",
+		})
+		return
+	}
+
+	if err := LoadExchangeRates(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Exchange rates updated but could not be reloaded",
+		})
+		return
+	}
+
+	rates, err := queryExchangeRates(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch exchange rates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Exchange rates updated successfully",
+		"base":    money.BaseCurrency,
+		"data":    rates,
+	})
+}
+
+// DeleteExchangeRate removes a currency from the rate table
+func DeleteExchangeRate(c *gin.Context) {
+	currency := strings.ToUpper(c.Param("currency"))
+	if currency == money.BaseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The base currency cannot be removed",
+		})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM exchange_rates WHERE currency = $1", currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete exchange rate",
+		})
+		return
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Exchange rate not found",
+		})
+		return
+	}
+
+	if err := LoadExchangeRates(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Exchange rate deleted but the rates could not be reloaded",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Exchange rate deleted successfully",
+	})
+}
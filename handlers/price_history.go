@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"book-management/money"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceSchedulerInterval is how often due price changes are applied
+const priceSchedulerInterval = time.Minute
+
+// withTx runs fn in a transaction unless q already is one
+func withTx(q queryer, fn func(q queryer) error) error {
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordPrice adds a price to the history of a book when it differs from
+// the current one, closing the current entry at the same moment. Market is
+// empty for the base price.
+func recordPrice(q queryer, bookID int, market, currency string, minor int64, at time.Time, username string) error {
+	_, err := q.Exec(`
+		WITH closed AS (
+			UPDATE price_history
+			SET effective_to = $6
+			WHERE book_id = $1 AND market = $2 AND effective_to IS NULL
+			  AND (currency <> $3 OR amount_minor <> $4)
+			RETURNING id
+		)
+		INSERT INTO price_history (book_id, market, currency, amount_minor, effective_from, created_by)
+		SELECT $1, $2, $3, $4, $6, $5
+		WHERE EXISTS (SELECT 1 FROM closed)
+		   OR NOT EXISTS (
+			SELECT 1 FROM price_history
+			WHERE book_id = $1 AND market = $2 AND effective_to IS NULL
+		   )
+	`, bookID, market, currency, minor, username, at)
+	return err
+}
+
+// recordBasePrice records the base price of a book written from input
+func recordBasePrice(q queryer, bookID int, input models.BookInput, username string) error {
+	currency := models.NormalizeCurrency(input.Currency)
+	return recordPrice(q, bookID, "", currency, input.PriceMinor, time.Now(), username)
+}
+
+// closePrice ends the current history entry of a market price
+func closePrice(q queryer, bookID int, market string, at time.Time) error {
+	_, err := q.Exec(`
+		UPDATE price_history
+		SET effective_to = $3
+		WHERE book_id = $1 AND market = $2 AND effective_to IS NULL
+	`, bookID, market, at)
+	return err
+}
+
+// loadPriceHistory loads every price a book has had, oldest first
+func loadPriceHistory(q queryer, bookID int) ([]models.PriceHistoryEntry, error) {
+	rows, err := q.Query(`
+		SELECT market, currency, amount_minor, effective_from, effective_to, created_by
+		FROM price_history
+		WHERE book_id = $1
+		ORDER BY effective_from, id
+	`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.PriceHistoryEntry{}
+	for rows.Next() {
+		var entry models.PriceHistoryEntry
+		var minor int64
+		var createdBy sql.NullString
+		err := rows.Scan(&entry.Market, &entry.Currency, &minor, &entry.EffectiveFrom, &entry.EffectiveTo, &createdBy)
+		if err != nil {
+			return nil, err
+		}
+		entry.Price = money.NewPrice(entry.Currency, minor)
+		entry.CreatedBy = createdBy.String
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// scheduledPriceColumns is the column list scanned by scanScheduledPrice
+const scheduledPriceColumns = `
+	id, book_id, market, currency, amount_minor, effective_at, status,
+	error, applied_at, created_at, created_by
+`
+
+// scanScheduledPrice scans a row selected with scheduledPriceColumns
+func scanScheduledPrice(row rowScanner, change *models.ScheduledPriceChange) error {
+	var minor int64
+	var createdBy sql.NullString
+	err := row.Scan(&change.ID, &change.BookID, &change.Market, &change.Currency, &minor,
+		&change.EffectiveAt, &change.Status, &change.Error, &change.AppliedAt,
+		&change.CreatedAt, &createdBy)
+	if err != nil {
+		return err
+	}
+	change.Price = money.NewPrice(change.Currency, minor)
+	change.CreatedBy = createdBy.String
+	return nil
+}
+
+// loadScheduledPrices loads the pending price changes of a book, soonest
+// first
+func loadScheduledPrices(q queryer, bookID int) ([]models.ScheduledPriceChange, error) {
+	rows, err := q.Query(`
+		SELECT `+scheduledPriceColumns+`
+		FROM scheduled_price_changes
+		WHERE book_id = $1 AND status = $2
+		ORDER BY effective_at, id
+	`, bookID, models.PriceChangePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.ScheduledPriceChange{}
+	for rows.Next() {
+		var change models.ScheduledPriceChange
+		if err := scanScheduledPrice(rows, &change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// SchedulePriceChange schedules a new base or market price for a book.
+// A base price goes through the same validation as PUT at scheduling time.
+func SchedulePriceChange(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.ScheduledPriceInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if !input.EffectiveAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "effective_at must be in the future",
+		})
+		return
+	}
+
+	market := strings.ToUpper(strings.TrimSpace(input.Market))
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if market != "" && (!marketPattern.MatchString(market) || !money.ValidCode(currency)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "market must be 2 to 10 letters or digits and currency an ISO 4217 code",
+		})
+		return
+	}
+
+	book, err := findBook(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch book",
+		})
+		return
+	}
+
+	// The base price stays in the book's currency unless another is given
+	if market == "" && currency == "" {
+		currency = book.Currency
+	}
+
+	minor, err := money.Parse(input.Amount, currency)
+	if err != nil || minor < 0 {
+		message := "amount must not be negative"
+		if err != nil {
+			message = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": message,
+		})
+		return
+	}
+
+	if market == "" {
+		bookInput := book.Input()
+		bookInput.PriceMinor = minor
+		bookInput.Currency = currency
+		if err := validateInput(&bookInput); err != nil {
+			validationFailed(c, err)
+			return
+		}
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var change models.ScheduledPriceChange
+	err = scanScheduledPrice(config.DB.QueryRow(`
+		INSERT INTO scheduled_price_changes (book_id, market, currency, amount_minor, effective_at, status, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+scheduledPriceColumns,
+		id, market, currency, minor, input.EffectiveAt, models.PriceChangePending, time.Now(), usernameStr,
+	), &change)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to schedule price change",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Price change scheduled successfully",
+		"data":    change,
+	})
+}
+
+// CancelScheduledPriceChange cancels a price change that has not been
+// applied yet
+func CancelScheduledPriceChange(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	changeID, err := strconv.Atoi(c.Param("changeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid price change ID",
+		})
+		return
+	}
+
+	var status string
+	err = config.DB.QueryRow(`
+		UPDATE scheduled_price_changes
+		SET status = CASE WHEN status = $3 THEN $4 ELSE status END
+		WHERE id = $1 AND book_id = $2
+		RETURNING status
+	`, changeID, id, models.PriceChangePending, models.PriceChangeCancelled).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Price change not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel price change",
+		})
+		return
+	}
+
+	if status != models.PriceChangeCancelled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Price change is already " + status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Price change cancelled successfully",
+	})
+}
+
+// StartPriceScheduler runs the worker that applies scheduled price changes
+// once they are due, until ctx is cancelled
+func StartPriceScheduler(ctx context.Context) {
+	go func() {
+		for {
+			applyDuePriceChanges(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(priceSchedulerInterval):
+			}
+		}
+	}()
+}
+
+// applyDuePriceChanges applies due changes one at a time until none are
+// left. A change that cannot be applied is marked failed.
+func applyDuePriceChanges(ctx context.Context) {
+	for ctx.Err() == nil {
+		applied, err := applyNextPriceChange(ctx)
+		if err != nil {
+			log.Println("Price scheduler failed:", err)
+			return
+		}
+		if !applied {
+			return
+		}
+	}
+}
+
+// applyNextPriceChange claims the oldest due change and applies it in one
+// transaction. It reports false when nothing was due.
+func applyNextPriceChange(ctx context.Context) (bool, error) {
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var change models.ScheduledPriceChange
+	err = scanScheduledPrice(tx.QueryRowContext(ctx, `
+		SELECT `+scheduledPriceColumns+`
+		FROM scheduled_price_changes
+		WHERE status = $1 AND effective_at <= $2
+		ORDER BY effective_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, models.PriceChangePending, time.Now()), &change)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// A savepoint keeps the claim when the change itself fails, so the
+	// failure can still be recorded
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT apply_price"); err != nil {
+		return false, err
+	}
+
+	status, message := models.PriceChangeApplied, ""
+	if err := applyPriceChange(tx, change); err != nil {
+		log.Printf("Scheduled price change %d failed: %v", change.ID, err)
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT apply_price"); rollbackErr != nil {
+			return false, rollbackErr
+		}
+		status, message = models.PriceChangeFailed, err.Error()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE scheduled_price_changes
+		SET status = $1, error = $2, applied_at = $3
+		WHERE id = $4
+	`, status, message, time.Now(), change.ID)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// applyPriceChange writes a scheduled price to the book and its history.
+// The book gets a new version, like any other price change.
+func applyPriceChange(q queryer, change models.ScheduledPriceChange) error {
+	if change.Market != "" {
+		if err := upsertBookPrice(q, change.BookID, change.Market, change.Currency, change.AmountMinor, change.EffectiveAt, change.CreatedBy); err != nil {
+			return err
+		}
+		_, err := touchBook(q, change.BookID, change.CreatedBy, nil)
+		return err
+	}
+
+	_, err := q.Exec(`
+		UPDATE books
+		SET price_minor = $1, currency = $2, modified_at = $3, modified_by = $4, version = version + 1
+		WHERE id = $5
+	`, change.AmountMinor, change.Currency, time.Now(), change.CreatedBy, change.BookID)
+	if err != nil {
+		return err
+	}
+
+	return recordPrice(q, change.BookID, "", change.Currency, change.AmountMinor, change.EffectiveAt, change.CreatedBy)
+}
@@ -0,0 +1,791 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// sharedListPath is the public path of a shared reading list, followed by
+// its share token
+const sharedListPath = "/api/shared/lists/"
+
+// readingListColumns is the column list scanned by scanReadingList.
+// Queries select it from reading_lists l.
+const readingListColumns = `
+	l.id, l.username, l.name, l.kind, l.share_token,
+	(SELECT COUNT(*) FROM reading_list_items i WHERE i.list_id = l.id),
+	l.created_at, l.modified_at, l.version
+`
+
+// scanReadingList scans a row selected with readingListColumns
+func scanReadingList(row rowScanner, list *models.ReadingList) error {
+	var shareToken sql.NullString
+	err := row.Scan(&list.ID, &list.Username, &list.Name, &list.Kind, &shareToken,
+		&list.ItemCount, &list.CreatedAt, &list.ModifiedAt, &list.Version)
+	if shareToken.Valid {
+		list.ShareURL = sharedListPath + shareToken.String
+	}
+	return err
+}
+
+// ensureShelves creates the built-in shelves of a user that do not exist
+// yet
+func ensureShelves(q queryer, username string) error {
+	for _, shelf := range models.Shelves {
+		_, err := q.Exec(`
+			INSERT INTO reading_lists (username, name, kind, created_at, modified_at)
+			VALUES ($1, $2, $3, $4, $4)
+			ON CONFLICT DO NOTHING
+		`, username, shelf.Name, shelf.Kind, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownReadingList loads a list of the logged in user from the id
+// parameter. Lists of other users are reported as not found.
+func ownReadingList(c *gin.Context) (models.ReadingList, bool) {
+	var list models.ReadingList
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid reading list ID",
+		})
+		return list, false
+	}
+
+	username, _ := c.Get("username")
+	err = scanReadingList(config.DB.QueryRow(`
+		SELECT `+readingListColumns+`
+		FROM reading_lists l
+		WHERE l.id = $1 AND l.username = $2
+	`, id, username), &list)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Reading list not found",
+		})
+		return list, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading list",
+		})
+		return list, false
+	}
+	return list, true
+}
+
+// loadListItems loads the books on a list in order. The reading progress
+// of username is attached; pass "" to leave it out.
+func loadListItems(q queryer, listID int, username string) ([]models.ReadingListItem, error) {
+	rows, err := q.Query(`
+		SELECT i.book_id, b.title, i.position, i.note, i.added_at, b.total_page,
+		       p.current_page, p.started_at, p.modified_at, p.finished_at
+		FROM reading_list_items i
+		JOIN books b ON b.id = i.book_id
+		LEFT JOIN reading_progress p ON p.book_id = i.book_id AND p.username = $2
+		WHERE i.list_id = $1
+		ORDER BY i.position
+	`, listID, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ReadingListItem{}
+	for rows.Next() {
+		var item models.ReadingListItem
+		var totalPage int
+		var currentPage sql.NullInt64
+		var startedAt, modifiedAt, finishedAt *time.Time
+		err := rows.Scan(&item.BookID, &item.Title, &item.Position, &item.Note, &item.AddedAt,
+			&totalPage, &currentPage, &startedAt, &modifiedAt, &finishedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if currentPage.Valid {
+			item.Progress = &models.ReadingProgress{
+				BookID:      item.BookID,
+				CurrentPage: int(currentPage.Int64),
+				TotalPage:   totalPage,
+				Percentage:  models.ProgressPercentage(int(currentPage.Int64), totalPage),
+				StartedAt:   *startedAt,
+				ModifiedAt:  *modifiedAt,
+				FinishedAt:  finishedAt,
+			}
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// lockReadingList locks a list until the transaction ends, so item
+// positions are changed one writer at a time, and returns its item count
+func lockReadingList(q queryer, listID int) (int, error) {
+	var count int
+	_, err := q.Exec("SELECT 1 FROM reading_lists WHERE id = $1 FOR UPDATE", listID)
+	if err == nil {
+		err = q.QueryRow("SELECT COUNT(*) FROM reading_list_items WHERE list_id = $1", listID).Scan(&count)
+	}
+	return count, err
+}
+
+// lockBookReadingLists locks the lists a book is on, in ID order so that
+// concurrent writers cannot deadlock, and returns their IDs
+func lockBookReadingLists(q queryer, bookID int) ([]int64, error) {
+	var listIDs []int64
+	err := q.QueryRow(`
+		WITH locked AS (
+			SELECT id FROM reading_lists
+			WHERE id IN (SELECT list_id FROM reading_list_items WHERE book_id = $1)
+			ORDER BY id
+			FOR UPDATE
+		)
+		SELECT COALESCE(array_agg(id), '{}') FROM locked
+	`, bookID).Scan(pq.Array(&listIDs))
+	return listIDs, err
+}
+
+// renumberReadingLists closes the gaps left in item positions after items
+// were removed from lists other than through DeleteReadingListItem, and
+// gives the lists a new version
+func renumberReadingLists(q queryer, listIDs []int64) error {
+	if len(listIDs) == 0 {
+		return nil
+	}
+
+	_, err := q.Exec(`
+		UPDATE reading_list_items i
+		SET position = n.position
+		FROM (
+			SELECT list_id, book_id,
+			       ROW_NUMBER() OVER (PARTITION BY list_id ORDER BY position) AS position
+			FROM reading_list_items
+			WHERE list_id = ANY($1)
+		) n
+		WHERE i.list_id = n.list_id AND i.book_id = n.book_id AND i.position <> n.position
+	`, pq.Array(listIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		UPDATE reading_lists
+		SET modified_at = $1, version = version + 1
+		WHERE id = ANY($2)
+	`, time.Now(), pq.Array(listIDs))
+	return err
+}
+
+// touchReadingList gives a list a new version after it or its items changed
+func touchReadingList(q queryer, listID int) (int, error) {
+	var version int
+	err := q.QueryRow(`
+		UPDATE reading_lists
+		SET modified_at = $1, version = version + 1
+		WHERE id = $2
+		RETURNING version
+	`, time.Now(), listID).Scan(&version)
+	return version, err
+}
+
+// GetReadingLists lists the reading lists of the logged in user, shelves
+// first
+func GetReadingLists(c *gin.Context) {
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	if err := ensureShelves(config.DB, usernameStr); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading lists",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+readingListColumns+`
+		FROM reading_lists l
+		WHERE l.username = $1
+		ORDER BY l.kind = $2, l.id
+	`, usernameStr, models.ListCustom)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading lists",
+		})
+		return
+	}
+	defer rows.Close()
+
+	lists := []models.ReadingList{}
+	for rows.Next() {
+		var list models.ReadingList
+		if err := scanReadingList(rows, &list); err != nil {
+			continue
+		}
+		lists = append(lists, list)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": lists,
+	})
+}
+
+// CreateReadingList creates a custom list for the logged in user
+func CreateReadingList(c *gin.Context) {
+	var input models.ReadingListInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	// The shelves claim their names before a custom list can
+	var list models.ReadingList
+	err := ensureShelves(config.DB, usernameStr)
+	if err == nil {
+		err = scanReadingList(config.DB.QueryRow(`
+			INSERT INTO reading_lists AS l (username, name, kind, created_at, modified_at)
+			VALUES ($1, $2, $3, $4, $4)
+			ON CONFLICT DO NOTHING
+			RETURNING `+readingListColumns,
+			usernameStr, input.Name, models.ListCustom, time.Now(),
+		), &list)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A reading list with this name already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(list.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Reading list created successfully",
+		"data":    list,
+	})
+}
+
+// GetReadingList retrieves a list of the logged in user with its books and
+// their reading progress
+func GetReadingList(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	// The reading progress is not covered by the list version
+	c.Header("ETag", formatETag(list.Version))
+
+	items, err := loadListItems(config.DB, list.ID, list.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading list",
+		})
+		return
+	}
+	list.Items = items
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": list,
+	})
+}
+
+// UpdateReadingList renames a custom list. If-Match is optional.
+func UpdateReadingList(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	var input models.ReadingListInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if list.Kind != models.ListCustom {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Shelves cannot be renamed",
+		})
+		return
+	}
+
+	var version int
+	err := config.DB.QueryRow(`
+		UPDATE reading_lists
+		SET name = $1, modified_at = $2, version = version + 1
+		WHERE id = $3 AND ($4::bigint[] IS NULL OR version = ANY($4))
+		RETURNING version
+	`, input.Name, time.Now(), list.ID, pq.Array(versions)).Scan(&version)
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "reading_lists", list.ID, "Reading list not found")
+		return
+	}
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A reading list with this name already exists",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reading list updated successfully",
+	})
+}
+
+// DeleteReadingList deletes a custom list. If-Match is optional.
+func DeleteReadingList(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	if list.Kind != models.ListCustom {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Shelves cannot be deleted",
+		})
+		return
+	}
+
+	var rowsAffected int64
+	err := withTx(config.DB, func(q queryer) error {
+		var bookIDs []int64
+		err := q.QueryRow(`
+			SELECT COALESCE(array_agg(book_id), '{}')
+			FROM reading_list_items
+			WHERE list_id = $1
+		`, list.ID).Scan(pq.Array(&bookIDs))
+		if err != nil {
+			return err
+		}
+
+		result, err := q.Exec(`
+			DELETE FROM reading_lists
+			WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))
+		`, list.ID, pq.Array(versions))
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, _ = result.RowsAffected()
+		if rowsAffected == 0 || len(bookIDs) == 0 {
+			return nil
+		}
+		return queueReaderPairs(q, list.Username, bookIDs)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete reading list",
+		})
+		return
+	}
+
+	if rowsAffected == 0 {
+		preconditionFailed(c, "reading_lists", list.ID, "Reading list not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reading list deleted successfully",
+	})
+}
+
+// AddReadingListItem adds a book to a list at a position, moving the books
+// from there down. Without a position the book is appended.
+func AddReadingListItem(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	var input models.ReadingListItemInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	var exists bool
+	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", input.BookID).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	var duplicate bool
+	var version int
+	position := input.Position
+	err = withTx(config.DB, func(q queryer) error {
+		count, err := lockReadingList(q, list.ID)
+		if err != nil {
+			return err
+		}
+
+		err = q.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM reading_list_items WHERE list_id = $1 AND book_id = $2)
+		`, list.ID, input.BookID).Scan(&duplicate)
+		if err != nil || duplicate {
+			return err
+		}
+
+		if position == 0 || position > count+1 {
+			position = count + 1
+		}
+
+		_, err = q.Exec(`
+			UPDATE reading_list_items
+			SET position = position + 1
+			WHERE list_id = $1 AND position >= $2
+		`, list.ID, position)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(`
+			INSERT INTO reading_list_items (list_id, book_id, position, note, added_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, list.ID, input.BookID, position, input.Note, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if err := queueReaderPairs(q, list.Username, []int64{int64(input.BookID)}); err != nil {
+			return err
+		}
+
+		version, err = touchReadingList(q, list.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add book to reading list",
+		})
+		return
+	}
+
+	if duplicate {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Book is already on this reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Book added to reading list",
+		"position": position,
+	})
+}
+
+// UpdateReadingListItem changes the note of a book on a list and moves it
+// to another position
+func UpdateReadingListItem(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.Atoi(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.ReadingListItemUpdate
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	var version int
+	position := input.Position
+	err = withTx(config.DB, func(q queryer) error {
+		count, err := lockReadingList(q, list.ID)
+		if err != nil {
+			return err
+		}
+
+		var current int
+		err = q.QueryRow(`
+			SELECT position FROM reading_list_items WHERE list_id = $1 AND book_id = $2
+		`, list.ID, bookID).Scan(&current)
+		if err != nil {
+			return err
+		}
+
+		if position == 0 {
+			position = current
+		} else if position > count {
+			position = count
+		}
+
+		// The books between the old and new position shift by one
+		if position < current {
+			_, err = q.Exec(`
+				UPDATE reading_list_items
+				SET position = position + 1
+				WHERE list_id = $1 AND position >= $2 AND position < $3
+			`, list.ID, position, current)
+		} else if position > current {
+			_, err = q.Exec(`
+				UPDATE reading_list_items
+				SET position = position - 1
+				WHERE list_id = $1 AND position > $2 AND position <= $3
+			`, list.ID, current, position)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(`
+			UPDATE reading_list_items
+			SET position = $1, note = $2
+			WHERE list_id = $3 AND book_id = $4
+		`, position, input.Note, list.ID, bookID)
+		if err != nil {
+			return err
+		}
+
+		version, err = touchReadingList(q, list.ID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book is not on this reading list",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update reading list item",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Reading list item updated successfully",
+		"position": position,
+	})
+}
+
+// DeleteReadingListItem removes a book from a list
+func DeleteReadingListItem(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.Atoi(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var version int
+	err = withTx(config.DB, func(q queryer) error {
+		if _, err := lockReadingList(q, list.ID); err != nil {
+			return err
+		}
+
+		var position int
+		err := q.QueryRow(`
+			DELETE FROM reading_list_items
+			WHERE list_id = $1 AND book_id = $2
+			RETURNING position
+		`, list.ID, bookID).Scan(&position)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(`
+			UPDATE reading_list_items
+			SET position = position - 1
+			WHERE list_id = $1 AND position > $2
+		`, list.ID, position)
+		if err != nil {
+			return err
+		}
+
+		if err := queueReaderPairs(q, list.Username, []int64{int64(bookID)}); err != nil {
+			return err
+		}
+
+		version, err = touchReadingList(q, list.ID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book is not on this reading list",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove book from reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Book removed from reading list",
+	})
+}
+
+// ShareReadingList makes a list readable by anyone with its link. Sharing
+// a shared list returns the same link.
+func ShareReadingList(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to share reading list",
+		})
+		return
+	}
+
+	var shareToken string
+	var version int
+	err := config.DB.QueryRow(`
+		UPDATE reading_lists
+		SET share_token = COALESCE(share_token, $1),
+		    version = CASE WHEN share_token IS NULL THEN version + 1 ELSE version END
+		WHERE id = $2
+		RETURNING share_token, version
+	`, base64.RawURLEncoding.EncodeToString(token), list.ID).Scan(&shareToken, &version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to share reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reading list shared",
+		"url":     sharedListPath + shareToken,
+	})
+}
+
+// UnshareReadingList revokes the link of a list. Sharing it again creates
+// a new link.
+func UnshareReadingList(c *gin.Context) {
+	list, ok := ownReadingList(c)
+	if !ok {
+		return
+	}
+
+	var version int
+	err := config.DB.QueryRow(`
+		UPDATE reading_lists
+		SET share_token = NULL,
+		    version = CASE WHEN share_token IS NULL THEN version ELSE version + 1 END
+		WHERE id = $1
+		RETURNING version
+	`, list.ID).Scan(&version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unshare reading list",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reading list is no longer shared",
+	})
+}
+
+// GetSharedReadingList shows a shared list to anyone with its link,
+// without the owner's reading progress
+func GetSharedReadingList(c *gin.Context) {
+	var list models.ReadingList
+	err := scanReadingList(config.DB.QueryRow(`
+		SELECT `+readingListColumns+`
+		FROM reading_lists l
+		WHERE l.share_token = $1
+	`, c.Param("token")), &list)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Reading list not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading list",
+		})
+		return
+	}
+
+	if notModified(c, list.Version) {
+		return
+	}
+
+	items, err := loadListItems(config.DB, list.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading list",
+		})
+		return
+	}
+	list.Items = items
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": list,
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// progressColumns is the column list scanned by scanProgress. Queries
+// select it from reading_progress p joined with books b.
+const progressColumns = `
+	p.book_id, b.title, p.current_page, b.total_page, p.started_at,
+	p.modified_at, p.finished_at
+`
+
+// scanProgress scans a row selected with progressColumns
+func scanProgress(row rowScanner, progress *models.ReadingProgress) error {
+	err := row.Scan(&progress.BookID, &progress.Title, &progress.CurrentPage, &progress.TotalPage,
+		&progress.StartedAt, &progress.ModifiedAt, &progress.FinishedAt)
+	progress.Percentage = models.ProgressPercentage(progress.CurrentPage, progress.TotalPage)
+	return err
+}
+
+// GetReadingProgress shows how far the logged in user has read a book
+func GetReadingProgress(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+
+	var progress models.ReadingProgress
+	err = scanProgress(config.DB.QueryRow(`
+		SELECT `+progressColumns+`
+		FROM reading_progress p
+		JOIN books b ON b.id = p.book_id
+		WHERE p.username = $1 AND p.book_id = $2
+	`, username, id), &progress)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No reading progress for this book",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading progress",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": progress,
+	})
+}
+
+// UpdateReadingProgress sets the page the logged in user is on. Reaching
+// the last page marks the book finished.
+func UpdateReadingProgress(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.ReadingProgressInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	var totalPage int
+	err = config.DB.QueryRow("SELECT total_page FROM books WHERE id = $1", id).Scan(&totalPage)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update reading progress",
+		})
+		return
+	}
+
+	if *input.CurrentPage > totalPage {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("current_page must not exceed total_page (%d)", totalPage),
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+
+	var progress models.ReadingProgress
+	err = scanProgress(config.DB.QueryRow(`
+		WITH p AS (
+			INSERT INTO reading_progress (username, book_id, current_page, started_at, modified_at, finished_at)
+			VALUES ($1, $2, $3, $4, $4, CASE WHEN $3::int >= $5::int THEN $4::timestamp END)
+			ON CONFLICT (username, book_id) DO UPDATE
+			SET current_page = EXCLUDED.current_page,
+			    modified_at = EXCLUDED.modified_at,
+			    finished_at = CASE WHEN EXCLUDED.current_page >= $5::int
+			                       THEN COALESCE(reading_progress.finished_at, EXCLUDED.modified_at) END
+			RETURNING *
+		)
+		SELECT `+progressColumns+`
+		FROM p
+		JOIN books b ON b.id = p.book_id
+	`, username, id, *input.CurrentPage, time.Now(), totalPage), &progress)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update reading progress",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reading progress updated successfully",
+		"data":    progress,
+	})
+}
+
+// GetMyReadingProgress lists the reading progress of the logged in user,
+// most recently read first
+func GetMyReadingProgress(c *gin.Context) {
+	username, _ := c.Get("username")
+
+	rows, err := config.DB.Query(`
+		SELECT `+progressColumns+`
+		FROM reading_progress p
+		JOIN books b ON b.id = p.book_id
+		WHERE p.username = $1
+		ORDER BY p.modified_at DESC
+	`, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reading progress",
+		})
+		return
+	}
+	defer rows.Close()
+
+	progress := []models.ReadingProgress{}
+	for rows.Next() {
+		var p models.ReadingProgress
+		if err := scanProgress(rows, &p); err != nil {
+			continue
+		}
+		progress = append(progress, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": progress,
+	})
+}
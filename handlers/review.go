@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// reviewColumns is the column list scanned by scanReview
+const reviewColumns = `
+	id, book_id, username, rating, body, status, hidden_reason, moderated_by,
+	moderated_at, created_at, modified_at, version
+`
+
+// scanReview scans a row selected with reviewColumns
+func scanReview(row rowScanner, review *models.Review) error {
+	var moderatedBy sql.NullString
+	err := row.Scan(&review.ID, &review.BookID, &review.Username, &review.Rating, &review.Body,
+		&review.Status, &review.HiddenReason, &moderatedBy, &review.ModeratedAt,
+		&review.CreatedAt, &review.ModifiedAt, &review.Version)
+	review.ModeratedBy = moderatedBy.String
+	return err
+}
+
+// findReview loads a single review by ID
+func findReview(q queryer, id int) (models.Review, error) {
+	var review models.Review
+	err := scanReview(q.QueryRow(`
+		SELECT `+reviewColumns+`
+		FROM reviews
+		WHERE id = $1
+	`, id), &review)
+	return review, err
+}
+
+// refreshBookRating recomputes the rating summary of a book from its
+// visible reviews. The book version is left alone, since reviews are not
+// edits of the book.
+func refreshBookRating(q queryer, bookID int) error {
+	// Locking the book first makes the summary below see reviews committed
+	// by concurrent writers
+	if _, err := q.Exec("SELECT 1 FROM books WHERE id = $1 FOR NO KEY UPDATE", bookID); err != nil {
+		return err
+	}
+
+	_, err := q.Exec(`
+		UPDATE books
+		SET rating_average = COALESCE(r.average, 0), rating_count = r.count
+		FROM (
+			SELECT ROUND(AVG(rating), 2) AS average, COUNT(*) AS count
+			FROM reviews
+			WHERE book_id = $1 AND status = $2
+		) r
+		WHERE id = $1
+	`, bookID, models.ReviewVisible)
+	return err
+}
+
+// writeReviews responds with the reviews matching condition, newest first,
+// paginated with page and page_size
+func writeReviews(c *gin.Context, condition string, args ...interface{}) {
+	page, _, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var total int
+	err = config.DB.QueryRow("SELECT COUNT(*) FROM reviews WHERE "+condition, args...).Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reviews",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+reviewColumns+`
+		FROM reviews
+		WHERE `+condition+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+strconv.Itoa(page.PageSize)+` OFFSET `+strconv.Itoa(page.offset()), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch reviews",
+		})
+		return
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		var review models.Review
+		if err := scanReview(rows, &review); err != nil {
+			continue
+		}
+		reviews = append(reviews, review)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       reviews,
+		"pagination": page.meta(total),
+	})
+}
+
+// GetBookReviews lists the visible reviews of a book with its rating
+// summary
+func GetBookReviews(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", id).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	writeReviews(c, "book_id = $1 AND status = $2", id, models.ReviewVisible)
+}
+
+// CreateBookReview adds the logged in user's review of a book
+func CreateBookReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.ReviewInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", id).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var review models.Review
+	err = withTx(config.DB, func(q queryer) error {
+		err := scanReview(q.QueryRow(`
+			INSERT INTO reviews (book_id, username, rating, body, created_at, modified_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			ON CONFLICT (book_id, username) DO NOTHING
+			RETURNING `+reviewColumns,
+			id, usernameStr, input.Rating, input.Body, time.Now(),
+		), &review)
+		if err != nil {
+			return err
+		}
+		return refreshBookRating(q, id)
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "You have already reviewed this book",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create review",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(review.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Review created successfully",
+		"data":    review,
+	})
+}
+
+// ownReview parses the review ID and checks that the review belongs to the
+// logged in user. It responds and reports false otherwise.
+func ownReview(c *gin.Context) (models.Review, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid review ID",
+		})
+		return models.Review{}, false
+	}
+
+	review, err := findReview(config.DB, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Review not found",
+		})
+		return review, false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch review",
+		})
+		return review, false
+	}
+
+	username, _ := c.Get("username")
+	if review.Username != username {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You can only change your own review",
+		})
+		return review, false
+	}
+	return review, true
+}
+
+// UpdateReview changes the logged in user's review. If-Match is optional.
+// A hidden review stays hidden.
+func UpdateReview(c *gin.Context) {
+	review, ok := ownReview(c)
+	if !ok {
+		return
+	}
+
+	var versions []int64
+	if c.GetHeader("If-Match") != "" {
+		if versions, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	var input models.ReviewInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	err := withTx(config.DB, func(q queryer) error {
+		err := scanReview(q.QueryRow(`
+			UPDATE reviews
+			SET rating = $1, body = $2, modified_at = $3, version = version + 1
+			WHERE id = $4 AND ($5::bigint[] IS NULL OR version = ANY($5))
+			RETURNING `+reviewColumns,
+			input.Rating, input.Body, time.Now(), review.ID, pq.Array(versions),
+		), &review)
+		if err != nil {
+			return err
+		}
+		return refreshBookRating(q, review.BookID)
+	})
+	if err == sql.ErrNoRows {
+		preconditionFailed(c, "reviews", review.ID, "Review not found")
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update review",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(review.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review updated successfully",
+		"data":    review,
+	})
+}
+
+// DeleteReview deletes the logged in user's review
+func DeleteReview(c *gin.Context) {
+	review, ok := ownReview(c)
+	if !ok {
+		return
+	}
+
+	err := withTx(config.DB, func(q queryer) error {
+		if _, err := q.Exec("DELETE FROM reviews WHERE id = $1", review.ID); err != nil {
+			return err
+		}
+		return refreshBookRating(q, review.BookID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete review",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review deleted successfully",
+	})
+}
+
+// GetReviews lists reviews for moderation, filtered by status, book_id and
+// username
+func GetReviews(c *gin.Context) {
+	condition := "($1 = '' OR status = $1) AND ($2 = 0 OR book_id = $2) AND ($3 = '' OR username = $3)"
+
+	bookID := 0
+	if raw := c.Query("book_id"); raw != "" {
+		var err error
+		if bookID, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "book_id must be a number",
+			})
+			return
+		}
+	}
+
+	writeReviews(c, condition, c.Query("status"), bookID, c.Query("username"))
+}
+
+// HideReview hides an abusive review from the book and its rating. The
+// body with a reason is optional.
+func HideReview(c *gin.Context) {
+	var input models.ModerationInput
+	if c.Request.ContentLength != 0 && !bindJSON(c, &input) {
+		return
+	}
+
+	moderateReview(c, models.ReviewHidden, input.Reason)
+}
+
+// UnhideReview makes a hidden review visible again
+func UnhideReview(c *gin.Context) {
+	moderateReview(c, models.ReviewVisible, "")
+}
+
+// moderateReview sets the status of a review and refreshes the rating of
+// its book
+func moderateReview(c *gin.Context, status, reason string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid review ID",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var review models.Review
+	err = withTx(config.DB, func(q queryer) error {
+		err := scanReview(q.QueryRow(`
+			UPDATE reviews
+			SET status = $1, hidden_reason = $2, moderated_by = $3, moderated_at = $4, version = version + 1
+			WHERE id = $5
+			RETURNING `+reviewColumns,
+			status, reason, usernameStr, time.Now(), id,
+		), &review)
+		if err != nil {
+			return err
+		}
+		return refreshBookRating(q, review.BookID)
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Review not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to moderate review",
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(review.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review " + status,
+		"data":    review,
+	})
+}
@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// similarRefreshInterval is how often queued pairs are rescored and stale
+// suggestions recomputed in the background
+const similarRefreshInterval = time.Minute
+
+// similarMaxAge is a safety net: suggestions are recomputed after this long
+// even though changes keep them up to date
+const similarMaxAge = 7 * 24 * time.Hour
+
+// similarBatchSize is how many books the background refresh picks at a time
+const similarBatchSize = 100
+
+// similarRefreshWake wakes the background refresh before its next interval,
+// when a request found stale suggestions
+var similarRefreshWake = make(chan struct{}, 1)
+
+// similaritySignature hashes the fields of books b that scores depend on.
+// Other edits, such as a new thickness, leave the suggestions alone.
+const similaritySignature = `md5(ROW(b.category_id, b.release_year, b.authors, b.tags)::text)`
+
+// staleSimilarBooks is the condition, on books b left joined with
+// book_similarity_refreshes r, for suggestions that need recomputing. $1 is
+// the oldest computed_at still served.
+const staleSimilarBooks = `
+	r.book_id IS NULL OR r.signature IS DISTINCT FROM ` + similaritySignature + ` OR r.computed_at < $1
+`
+
+// scoreSimilarPairs scores book $1 against the books in $15, or against
+// every candidate when $15 is NULL, into the similar_pairs table. Candidates
+// share the category, an author or a tag, or were read or borrowed by the
+// same people; a close release year only adds to their score.
+const scoreSimilarPairs = `
+	WITH target AS (
+		SELECT id, category_id, authors, tags, release_year
+		FROM books
+		WHERE id = $1
+	),
+	readers AS (
+		SELECT i2.book_id, COUNT(DISTINCT l2.username) AS n
+		FROM reading_list_items i1
+		JOIN reading_lists l1 ON l1.id = i1.list_id
+		JOIN reading_lists l2 ON l2.username = l1.username
+		JOIN reading_list_items i2 ON i2.list_id = l2.id
+		WHERE i1.book_id = $1 AND i2.book_id <> $1
+		GROUP BY i2.book_id
+	),
+	borrowers AS (
+		SELECT c2.book_id, COUNT(DISTINCT l2.patron_id) AS n
+		FROM copies c1
+		JOIN loans l1 ON l1.copy_id = c1.id
+		JOIN loans l2 ON l2.patron_id = l1.patron_id
+		JOIN copies c2 ON c2.id = l2.copy_id
+		WHERE c1.book_id = $1 AND c2.book_id <> $1
+		GROUP BY c2.book_id
+	),
+	candidates AS (
+		SELECT unnest($15::int[]) AS id
+		UNION
+		SELECT b.id FROM books b JOIN target t ON b.category_id = t.category_id WHERE $15::int[] IS NULL
+		UNION
+		SELECT b.id FROM books b JOIN target t ON b.authors && t.authors WHERE $15::int[] IS NULL
+		UNION
+		SELECT b.id FROM books b JOIN target t ON b.tags && t.tags WHERE $15::int[] IS NULL
+		UNION
+		SELECT book_id FROM readers WHERE $15::int[] IS NULL
+		UNION
+		SELECT book_id FROM borrowers WHERE $15::int[] IS NULL
+	),
+	signals AS (
+		SELECT b.id,
+		       CASE WHEN b.category_id = t.category_id THEN $2 ELSE 0 END AS category,
+		       $3 * (SELECT COUNT(DISTINCT a) FROM unnest(b.authors) a WHERE a = ANY(t.authors)) AS authors,
+		       $13 * (SELECT COUNT(DISTINCT g) FROM unnest(b.tags) g WHERE g = ANY(t.tags)) AS tags,
+		       GREATEST($4 - ABS(b.release_year - t.release_year), 0) AS release_year,
+		       $5 * LEAST(COALESCE(r.n, 0), $7) AS readers,
+		       $6 * LEAST(COALESCE(bo.n, 0), $7) AS borrowers
+		FROM candidates cand
+		JOIN books b ON b.id = cand.id
+		CROSS JOIN target t
+		LEFT JOIN readers r ON r.book_id = b.id
+		LEFT JOIN borrowers bo ON bo.book_id = b.id
+		WHERE b.id <> t.id
+	)
+	INSERT INTO similar_pairs (book_id, similar_book_id, score, reasons)
+	SELECT $1, id, score, CASE WHEN score > 0 THEN reasons ELSE '{}' END
+	FROM (
+		SELECT id,
+		       CASE WHEN category + authors + tags + readers + borrowers > 0
+		            THEN category + authors + tags + release_year + readers + borrowers
+		            ELSE 0 END AS score,
+		       array_remove(ARRAY[
+		           CASE WHEN category > 0 THEN $8::text END,
+		           CASE WHEN authors > 0 THEN $9::text END,
+		           CASE WHEN tags > 0 THEN $14::text END,
+		           CASE WHEN release_year > 0 THEN $10::text END,
+		           CASE WHEN readers > 0 THEN $11::text END,
+		           CASE WHEN borrowers > 0 THEN $12::text END
+		       ], NULL) AS reasons
+		FROM signals
+	) scored
+	ON CONFLICT (book_id, similar_book_id) DO NOTHING
+`
+
+// lockSimilarities serializes writers of the suggestion cache until the
+// transaction ends, so that updates of overlapping lists cannot deadlock
+func lockSimilarities(q queryer) error {
+	_, err := q.Exec("SELECT pg_advisory_xact_lock(hashtext('book_similarities'))")
+	return err
+}
+
+// beginSimilarityWrite locks the suggestion cache and creates the
+// similar_pairs table for the new scores
+func beginSimilarityWrite(q queryer) error {
+	if err := lockSimilarities(q); err != nil {
+		return err
+	}
+	_, err := q.Exec(`
+		CREATE TEMP TABLE similar_pairs (
+			book_id INTEGER NOT NULL,
+			similar_book_id INTEGER NOT NULL,
+			score INTEGER NOT NULL,
+			reasons TEXT[] NOT NULL,
+			PRIMARY KEY (book_id, similar_book_id)
+		) ON COMMIT DROP
+	`)
+	return err
+}
+
+// scorePairs scores bookID against others, or every candidate when others
+// is nil, into similar_pairs
+func scorePairs(q queryer, bookID int, others []int64) error {
+	var restrict interface{}
+	if others != nil {
+		restrict = pq.Array(others)
+	}
+	_, err := q.Exec(scoreSimilarPairs, bookID,
+		models.SimilarCategoryWeight, models.SimilarAuthorWeight, models.SimilarYearWindow,
+		models.SimilarReaderWeight, models.SimilarBorrowerWeight, models.SimilarPeopleCap,
+		models.SimilarCategory, models.SimilarAuthors, models.SimilarReleaseYear,
+		models.SimilarReaders, models.SimilarBorrowers, models.SimilarTagWeight,
+		models.SimilarTags, restrict)
+	return err
+}
+
+// applySimilarPairs writes the scores in similar_pairs into the cached
+// lists of books that have one, keeping each list to its best
+// SimilarBooksKept. A full list that loses or lowers a pair may now miss a
+// book that was not kept, so it is marked stale to be recomputed.
+func applySimilarPairs(q queryer) error {
+	statements := []string{`
+		UPDATE book_similarity_refreshes r
+		SET signature = NULL
+		FROM similar_pairs p
+		JOIN book_similarities s ON s.book_id = p.book_id AND s.similar_book_id = p.similar_book_id
+		WHERE r.book_id = p.book_id AND p.score < s.score
+		  AND (SELECT COUNT(*) FROM book_similarities f WHERE f.book_id = p.book_id) >= $1
+	`, `
+		DELETE FROM book_similarities s
+		USING similar_pairs p
+		WHERE s.book_id = p.book_id AND s.similar_book_id = p.similar_book_id AND p.score = 0
+	`, `
+		UPDATE book_similarities s
+		SET score = p.score, reasons = p.reasons
+		FROM similar_pairs p
+		WHERE s.book_id = p.book_id AND s.similar_book_id = p.similar_book_id AND p.score > 0
+		  AND (s.score <> p.score OR s.reasons <> p.reasons)
+	`, `
+		INSERT INTO book_similarities (book_id, similar_book_id, score, reasons)
+		SELECT p.book_id, p.similar_book_id, p.score, p.reasons
+		FROM similar_pairs p
+		JOIN book_similarity_refreshes r ON r.book_id = p.book_id
+		WHERE p.score > 0
+		  AND NOT EXISTS (
+		      SELECT 1 FROM book_similarities s
+		      WHERE s.book_id = p.book_id AND s.similar_book_id = p.similar_book_id
+		  )
+		  AND (
+		      (SELECT COUNT(*) FROM book_similarities s WHERE s.book_id = p.book_id) < $1
+		      OR (p.score, -p.similar_book_id) > (
+		          SELECT s.score, -s.similar_book_id
+		          FROM book_similarities s
+		          WHERE s.book_id = p.book_id
+		          ORDER BY s.score, s.similar_book_id DESC
+		          LIMIT 1
+		      )
+		  )
+	`, `
+		DELETE FROM book_similarities s
+		USING (
+			SELECT book_id, similar_book_id,
+			       ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY score DESC, similar_book_id) AS rank
+			FROM book_similarities
+			WHERE book_id IN (SELECT book_id FROM similar_pairs)
+		) ranked
+		WHERE ranked.rank > $1 AND s.book_id = ranked.book_id AND s.similar_book_id = ranked.similar_book_id
+	`}
+
+	for _, statement := range statements {
+		if _, err := q.Exec(statement, models.SimilarBooksKept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshSimilarBooks recomputes the suggestions of a book and updates its
+// score in the lists of the other books, including the lists it no longer
+// belongs to. It returns sql.ErrNoRows when the book does not exist.
+func refreshSimilarBooks(q queryer, bookID int) error {
+	if err := beginSimilarityWrite(q); err != nil {
+		return err
+	}
+
+	var id int
+	err := q.QueryRow(`
+		INSERT INTO book_similarity_refreshes (book_id, signature, computed_at)
+		SELECT b.id, `+similaritySignature+`, $2
+		FROM books b
+		WHERE b.id = $1
+		ON CONFLICT (book_id) DO UPDATE
+		SET signature = EXCLUDED.signature, computed_at = EXCLUDED.computed_at
+		RETURNING book_id
+	`, bookID, time.Now()).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if err := scorePairs(q, bookID, nil); err != nil {
+		return err
+	}
+
+	statements := []string{
+		"DELETE FROM book_similarities WHERE book_id = $1",
+		`INSERT INTO book_similarities (book_id, similar_book_id, score, reasons)
+		 SELECT book_id, similar_book_id, score, reasons
+		 FROM similar_pairs
+		 WHERE book_id = $1
+		 ORDER BY score DESC, similar_book_id
+		 LIMIT $2`,
+		// The same scores seen from the other books, and a zero score for
+		// the books whose lists it is no longer a candidate for
+		`INSERT INTO similar_pairs (book_id, similar_book_id, score, reasons)
+		 SELECT similar_book_id, book_id, score, reasons
+		 FROM similar_pairs
+		 WHERE book_id = $1
+		 UNION ALL
+		 SELECT s.book_id, $1, 0, '{}'
+		 FROM book_similarities s
+		 WHERE s.similar_book_id = $1 AND NOT EXISTS (
+		     SELECT 1 FROM similar_pairs p WHERE p.book_id = $1 AND p.similar_book_id = s.book_id
+		 )`,
+		"DELETE FROM similar_pairs WHERE book_id = $1",
+	}
+	for i, statement := range statements {
+		args := []interface{}{bookID}
+		if i == 1 {
+			args = append(args, models.SimilarBooksKept)
+		}
+		if _, err := q.Exec(statement, args...); err != nil {
+			return err
+		}
+	}
+
+	return applySimilarPairs(q)
+}
+
+// rescoreSimilarPairs updates the scores of bookID with others in the lists
+// of both books
+func rescoreSimilarPairs(q queryer, bookID int, others []int64) error {
+	if err := beginSimilarityWrite(q); err != nil {
+		return err
+	}
+	if err := scorePairs(q, bookID, others); err != nil {
+		return err
+	}
+
+	_, err := q.Exec(`
+		INSERT INTO similar_pairs (book_id, similar_book_id, score, reasons)
+		SELECT similar_book_id, book_id, score, reasons
+		FROM similar_pairs
+		WHERE book_id = $1
+	`, bookID)
+	if err != nil {
+		return err
+	}
+	return applySimilarPairs(q)
+}
+
+// queueReaderPairs queues the pairs of each of bookIDs with the books in
+// username's reading lists and with each other, whose reader counts may
+// have changed
+func queueReaderPairs(q queryer, username string, bookIDs []int64) error {
+	_, err := q.Exec(`
+		INSERT INTO book_similarity_queue (book_id, similar_book_id)
+		SELECT DISTINCT b.id, o.book_id
+		FROM unnest($2::int[]) AS b(id)
+		CROSS JOIN (
+			SELECT i.book_id
+			FROM reading_list_items i
+			JOIN reading_lists l ON l.id = i.list_id
+			WHERE l.username = $1
+			UNION
+			SELECT unnest($2::int[])
+		) o
+		WHERE o.book_id <> b.id
+		  AND EXISTS (SELECT 1 FROM books WHERE id = b.id)
+		  AND EXISTS (SELECT 1 FROM books WHERE id = o.book_id)
+		ON CONFLICT DO NOTHING
+	`, username, pq.Array(bookIDs))
+	return err
+}
+
+// queueBorrowerPairs queues the pairs of bookID with the other books
+// patronID borrowed, whose borrower counts may have changed
+func queueBorrowerPairs(q queryer, patronID, bookID int) error {
+	_, err := q.Exec(`
+		INSERT INTO book_similarity_queue (book_id, similar_book_id)
+		SELECT DISTINCT $2::int, c.book_id
+		FROM loans l
+		JOIN copies c ON c.id = l.copy_id
+		WHERE l.patron_id = $1 AND c.book_id <> $2
+		ON CONFLICT DO NOTHING
+	`, patronID, bookID)
+	return err
+}
+
+// markSimilarListsStale marks the lists that contain bookID stale, before
+// the book is deleted, so they are refilled
+func markSimilarListsStale(q queryer, bookID int) error {
+	if err := lockSimilarities(q); err != nil {
+		return err
+	}
+	_, err := q.Exec(`
+		UPDATE book_similarity_refreshes
+		SET signature = NULL
+		WHERE book_id IN (SELECT book_id FROM book_similarities WHERE similar_book_id = $1)
+	`, bookID)
+	return err
+}
+
+// StartSimilarityRefresh keeps suggestions up to date in the background,
+// until ctx is cancelled
+func StartSimilarityRefresh(ctx context.Context) {
+	go func() {
+		for {
+			rescoreQueuedPairs(ctx)
+			refreshStaleSimilarities(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-similarRefreshWake:
+			case <-time.After(similarRefreshInterval):
+			}
+		}
+	}()
+}
+
+// markSimilarBooksStale marks the suggestions of bookID stale and wakes the
+// background refresh to recompute them
+func markSimilarBooksStale(q queryer, bookID int) error {
+	_, err := q.Exec(`
+		UPDATE book_similarity_refreshes
+		SET signature = NULL
+		WHERE book_id = $1 AND signature IS NOT NULL
+	`, bookID)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case similarRefreshWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rescoreQueuedPairs rescores the queued pairs, one book's pairs per
+// transaction, until the queue is empty. Rows another instance is already
+// rescoring are skipped rather than waited for.
+func rescoreQueuedPairs(ctx context.Context) {
+	rescored := 0
+	for ctx.Err() == nil {
+		done := false
+		err := withTx(config.DB, func(q queryer) error {
+			rows, err := q.Query(`
+				DELETE FROM book_similarity_queue
+				WHERE (book_id, similar_book_id) IN (
+					SELECT book_id, similar_book_id
+					FROM book_similarity_queue
+					WHERE book_id = (
+						SELECT book_id FROM book_similarity_queue
+						LIMIT 1
+						FOR UPDATE SKIP LOCKED
+					)
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING book_id, similar_book_id
+			`)
+			if err != nil {
+				return err
+			}
+
+			bookID := 0
+			var others []int64
+			for rows.Next() {
+				var other int64
+				if err := rows.Scan(&bookID, &other); err != nil {
+					rows.Close()
+					return err
+				}
+				others = append(others, other)
+			}
+			rows.Close()
+
+			if len(others) == 0 {
+				done = true
+				return nil
+			}
+			rescored += len(others)
+			return rescoreSimilarPairs(q, bookID, others)
+		})
+		if err != nil {
+			log.Println("Similar books rescoring failed:", err)
+			return
+		}
+		if done {
+			break
+		}
+	}
+
+	if rescored > 0 {
+		log.Printf("Similar books rescored %d pairs", rescored)
+	}
+}
+
+// refreshStaleSimilarities recomputes stale suggestions a batch at a time,
+// each book in its own transaction, until none are left
+func refreshStaleSimilarities(ctx context.Context) {
+	refreshed := 0
+	for ctx.Err() == nil {
+		rows, err := config.DB.QueryContext(ctx, `
+			SELECT b.id
+			FROM books b
+			LEFT JOIN book_similarity_refreshes r ON r.book_id = b.id
+			WHERE `+staleSimilarBooks+`
+			ORDER BY r.computed_at NULLS FIRST, b.id
+			LIMIT $2
+		`, time.Now().Add(-similarMaxAge), similarBatchSize)
+		if err != nil {
+			log.Println("Similar books refresh failed:", err)
+			return
+		}
+
+		var bookIDs []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err == nil {
+				bookIDs = append(bookIDs, id)
+			}
+		}
+		rows.Close()
+
+		if len(bookIDs) == 0 {
+			break
+		}
+
+		for _, id := range bookIDs {
+			err := withTx(config.DB, func(q queryer) error {
+				return refreshSimilarBooks(q, id)
+			})
+			if err != nil && err != sql.ErrNoRows {
+				// Stop rather than pick the same book again in the next batch
+				log.Printf("Similar books refresh failed for book %d: %v", id, err)
+				return
+			}
+			refreshed++
+		}
+	}
+
+	if refreshed > 0 {
+		log.Printf("Similar books refreshed for %d books", refreshed)
+	}
+}
+
+// GetSimilarBooks suggests books related to a book, best first. Suggestions
+// are always served from the cache; when they are missing or out of date the
+// response says so and the background refresh recomputes them, so a request
+// never scores the catalog itself. limit is 10 by default.
+func GetSimilarBooks(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	limit := 10
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.SimilarBooksKept {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(models.SimilarBooksKept),
+			})
+			return
+		}
+	}
+
+	var stale bool
+	err = config.DB.QueryRow(`
+		SELECT `+staleSimilarBooks+`
+		FROM books b
+		LEFT JOIN book_similarity_refreshes r ON r.book_id = b.id
+		WHERE b.id = $2
+	`, time.Now().Add(-similarMaxAge), id).Scan(&stale)
+	if err == nil && stale {
+		err = markSimilarBooksStale(config.DB, id)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch similar books",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+bookColumns+`, s.score, s.reasons
+		FROM book_similarities s
+		JOIN books ON books.id = s.similar_book_id
+		WHERE s.book_id = $1
+		ORDER BY s.score DESC, s.similar_book_id
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch similar books",
+		})
+		return
+	}
+	defer rows.Close()
+
+	similar := []models.SimilarBook{}
+	for rows.Next() {
+		var s models.SimilarBook
+		if err := scanBook(rows, &s.Book, &s.Score, pq.Array(&s.Reasons)); err != nil {
+			continue
+		}
+		similar = append(similar, s)
+	}
+
+	lang := requestedLanguage(c)
+	for i := range similar {
+		similar[i].Book.ThicknessLabel = models.ThicknessLabel(similar[i].Book.Thickness, lang)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  similar,
+		"stale": stale,
+	})
+}
@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// stockMovementColumns is the column list scanned by scanStockMovement
+const stockMovementColumns = `
+	id, book_id, location_id, type, quantity, transfer_id, reference, note,
+	created_at, created_by
+`
+
+// scanStockMovement scans a row selected with stockMovementColumns
+func scanStockMovement(row rowScanner, movement *models.StockMovement) error {
+	var createdBy sql.NullString
+	err := row.Scan(&movement.ID, &movement.BookID, &movement.LocationID, &movement.Type,
+		&movement.Quantity, &movement.TransferID, &movement.Reference, &movement.Note,
+		&movement.CreatedAt, &createdBy)
+	movement.CreatedBy = createdBy.String
+	return err
+}
+
+// insertStockMovement appends one entry to the ledger
+func insertStockMovement(q queryer, movement models.StockMovement) (models.StockMovement, error) {
+	var inserted models.StockMovement
+	err := scanStockMovement(q.QueryRow(`
+		INSERT INTO stock_movements (book_id, location_id, type, quantity, transfer_id, reference, note, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+stockMovementColumns,
+		movement.BookID, movement.LocationID, movement.Type, movement.Quantity, movement.TransferID,
+		movement.Reference, movement.Note, time.Now(), movement.CreatedBy,
+	), &inserted)
+	return inserted, err
+}
+
+// onHand is the quantity of a book at a location according to the ledger
+func onHand(q queryer, bookID, locationID int) (int, error) {
+	var quantity int
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM stock_movements
+		WHERE book_id = $1 AND location_id = $2
+	`, bookID, locationID).Scan(&quantity)
+	return quantity, err
+}
+
+// bookStock sums the ledger of a book per location
+func bookStock(q queryer, bookID int) (models.BookStock, error) {
+	stock := models.BookStock{BookID: bookID, Locations: []models.LocationStock{}}
+
+	rows, err := q.Query(`
+		SELECT l.id, l.code, SUM(m.quantity)
+		FROM stock_movements m
+		JOIN locations l ON l.id = m.location_id
+		WHERE m.book_id = $1
+		GROUP BY l.id, l.code
+		ORDER BY l.code
+	`, bookID)
+	if err != nil {
+		return stock, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		location := models.LocationStock{BookID: bookID}
+		if err := rows.Scan(&location.LocationID, &location.LocationCode, &location.OnHand); err != nil {
+			return stock, err
+		}
+		stock.OnHand += location.OnHand
+		stock.Locations = append(stock.Locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return stock, err
+	}
+
+	var threshold int
+	err = q.QueryRow("SELECT low_stock_threshold FROM book_stock_thresholds WHERE book_id = $1", bookID).Scan(&threshold)
+	if err == nil {
+		stock.LowStockThreshold = &threshold
+		stock.LowStock = stock.OnHand <= threshold
+	} else if err != sql.ErrNoRows {
+		return stock, err
+	}
+	return stock, nil
+}
+
+// checkStockAlert raises an alert when the stock of a book is at or below
+// its threshold and resolves the open one when it is above again
+func checkStockAlert(q queryer, bookID int) error {
+	stock, err := bookStock(q, bookID)
+	if err != nil {
+		return err
+	}
+
+	if !stock.LowStock {
+		_, err := q.Exec(`
+			UPDATE stock_alerts
+			SET status = $1, resolved_at = $2
+			WHERE book_id = $3 AND status = $4
+		`, models.StockAlertResolved, time.Now(), bookID, models.StockAlertOpen)
+		return err
+	}
+
+	// xmax is 0 only for a freshly inserted row, i.e. a new alert
+	var raised bool
+	err = q.QueryRow(`
+		INSERT INTO stock_alerts (book_id, on_hand, threshold, status, raised_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (book_id) WHERE status = 'open' DO UPDATE
+		SET on_hand = EXCLUDED.on_hand, threshold = EXCLUDED.threshold
+		RETURNING (xmax = 0)
+	`, bookID, stock.OnHand, *stock.LowStockThreshold, models.StockAlertOpen, time.Now()).Scan(&raised)
+	if err != nil {
+		return err
+	}
+	if raised {
+		log.Printf("Low stock: book %d has %d on hand (threshold %d)", bookID, stock.OnHand, *stock.LowStockThreshold)
+	}
+	return nil
+}
+
+// RecordStockMovement adds a receipt, sale, transfer or adjustment to the
+// ledger. Stock at a location can never go below zero.
+func RecordStockMovement(c *gin.Context) {
+	var input models.StockMovementInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if input.Type != models.StockAdjustment && input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "quantity must be positive for " + input.Type,
+		})
+		return
+	}
+
+	if input.Type == models.StockTransfer && (input.ToLocationID == 0 || input.ToLocationID == input.LocationID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to_location_id is required for transfer and must differ from location_id",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the book serialises movements of the same book, so the
+	// on-hand check below cannot race
+	var bookID int
+	err = tx.QueryRow("SELECT id FROM books WHERE id = $1 FOR NO KEY UPDATE", input.BookID).Scan(&bookID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID - book does not exist",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record stock movement",
+		})
+		return
+	}
+
+	locationIDs := []int64{int64(input.LocationID)}
+	if input.Type == models.StockTransfer {
+		locationIDs = append(locationIDs, int64(input.ToLocationID))
+	}
+	var found int
+	err = tx.QueryRow("SELECT COUNT(*) FROM locations WHERE id = ANY($1)", pq.Array(locationIDs)).Scan(&found)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record stock movement",
+		})
+		return
+	}
+
+	if found != len(locationIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid location ID - location does not exist",
+		})
+		return
+	}
+
+	delta := input.Quantity
+	if input.Type == models.StockSale || input.Type == models.StockTransfer {
+		delta = -input.Quantity
+	}
+
+	if delta < 0 {
+		available, err := onHand(tx, input.BookID, input.LocationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to record stock movement",
+			})
+			return
+		}
+
+		if available+delta < 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   fmt.Sprintf("Insufficient stock: %d on hand", available),
+				"on_hand": available,
+			})
+			return
+		}
+	}
+
+	movement := models.StockMovement{
+		BookID:     input.BookID,
+		LocationID: input.LocationID,
+		Type:       input.Type,
+		Quantity:   delta,
+		Reference:  input.Reference,
+		Note:       input.Note,
+		CreatedBy:  usernameStr,
+	}
+
+	var movements []models.StockMovement
+	if input.Type == models.StockTransfer {
+		movements, err = insertTransfer(tx, movement, input.ToLocationID)
+	} else {
+		movement, err = insertStockMovement(tx, movement)
+		movements = []models.StockMovement{movement}
+	}
+	if err == nil {
+		err = checkStockAlert(tx, input.BookID)
+	}
+
+	var stock models.BookStock
+	if err == nil {
+		stock, err = bookStock(tx, input.BookID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record stock movement",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Stock movement recorded successfully",
+		"data":    movements,
+		"stock":   stock,
+	})
+}
+
+// insertTransfer records a transfer as a transfer_out at the source and a
+// transfer_in at the destination. Both carry the ID of the transfer_out.
+func insertTransfer(q queryer, out models.StockMovement, toLocationID int) ([]models.StockMovement, error) {
+	out.Type = models.StockTransferOut
+	out, err := insertStockMovement(q, out)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := q.Exec("UPDATE stock_movements SET transfer_id = id WHERE id = $1", out.ID); err != nil {
+		return nil, err
+	}
+	out.TransferID = &out.ID
+
+	in := out
+	in.Type = models.StockTransferIn
+	in.LocationID = toLocationID
+	in.Quantity = -out.Quantity
+	in, err = insertStockMovement(q, in)
+	if err != nil {
+		return nil, err
+	}
+	return []models.StockMovement{out, in}, nil
+}
+
+// GetStockMovements lists the ledger, newest first, optionally filtered by
+// book_id, location_id and type and paginated
+func GetStockMovements(c *gin.Context) {
+	var conditions []string
+	var args []interface{}
+	for _, param := range []string{"book_id", "location_id"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": param + " must be a number",
+			})
+			return
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", param, len(args)))
+	}
+	if movementType := c.Query("type"); movementType != "" {
+		args = append(args, movementType)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page, paginated, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var total int
+	limit := ""
+	if paginated {
+		err := config.DB.QueryRow("SELECT COUNT(*) FROM stock_movements "+where, args...).Scan(&total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch stock movements",
+			})
+			return
+		}
+		limit = fmt.Sprintf("LIMIT %d OFFSET %d", page.PageSize, page.offset())
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT `+stockMovementColumns+`
+		FROM stock_movements
+		`+where+`
+		ORDER BY id DESC
+		`+limit, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch stock movements",
+		})
+		return
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		if err := scanStockMovement(rows, &movement); err != nil {
+			continue
+		}
+		movements = append(movements, movement)
+	}
+
+	if paginated {
+		c.JSON(http.StatusOK, gin.H{
+			"data":       movements,
+			"pagination": page.meta(total),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": movements,
+	})
+}
+
+// GetBookStock shows the on-hand quantity of a book per location
+func GetBookStock(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", id).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+
+	stock, err := bookStock(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch stock",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stock,
+	})
+}
+
+// GetLocationStock lists the books held at a location
+func GetLocationStock(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid location ID",
+		})
+		return
+	}
+
+	var code string
+	err = config.DB.QueryRow("SELECT code FROM locations WHERE id = $1", id).Scan(&code)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Location not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch location",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT book_id, SUM(quantity)
+		FROM stock_movements
+		WHERE location_id = $1
+		GROUP BY book_id
+		HAVING SUM(quantity) <> 0
+		ORDER BY book_id
+	`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch stock",
+		})
+		return
+	}
+	defer rows.Close()
+
+	stock := []models.LocationStock{}
+	for rows.Next() {
+		item := models.LocationStock{LocationID: id, LocationCode: code}
+		if err := rows.Scan(&item.BookID, &item.OnHand); err != nil {
+			continue
+		}
+		stock = append(stock, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stock,
+	})
+}
+
+// SetStockThreshold sets the low-stock threshold of a book and raises or
+// resolves its alert right away
+func SetStockThreshold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid book ID",
+		})
+		return
+	}
+
+	var input models.StockThresholdInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO book_stock_thresholds (book_id, low_stock_threshold, modified_at, modified_by)
+		SELECT id, $2, $3, $4 FROM books WHERE id = $1
+		ON CONFLICT (book_id) DO UPDATE
+		SET low_stock_threshold = EXCLUDED.low_stock_threshold,
+		    modified_at = EXCLUDED.modified_at, modified_by = EXCLUDED.modified_by
+	`, id, *input.LowStockThreshold, time.Now(), usernameStr)
+
+	var stock models.BookStock
+	if err == nil {
+		err = checkStockAlert(tx, id)
+	}
+	if err == nil {
+		stock, err = bookStock(tx, id)
+	}
+	if err == nil && stock.LowStockThreshold == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Book not found",
+		})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to set threshold",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Threshold set successfully",
+		"data":    stock,
+	})
+}
+
+// GetStockAlerts lists low-stock alerts; status defaults to open
+func GetStockAlerts(c *gin.Context) {
+	status := c.DefaultQuery("status", models.StockAlertOpen)
+	if status != models.StockAlertOpen && status != models.StockAlertResolved {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status must be one of open, resolved",
+		})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, book_id, on_hand, threshold, status, raised_at, resolved_at
+		FROM stock_alerts
+		WHERE status = $1
+		ORDER BY raised_at DESC
+	`, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch stock alerts",
+		})
+		return
+	}
+	defer rows.Close()
+
+	alerts := []models.StockAlert{}
+	for rows.Next() {
+		var alert models.StockAlert
+		err := rows.Scan(&alert.ID, &alert.BookID, &alert.OnHand, &alert.Threshold,
+			&alert.Status, &alert.RaisedAt, &alert.ResolvedAt)
+		if err != nil {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": alerts,
+	})
+}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"book-management/config"
+	"book-management/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// thicknessBatchSize is the number of books re-derived per statement by a
+// recalculation, so a large catalogue is never locked in one go
+const thicknessBatchSize = 1000
+
+// thicknessStaleAfter is how long a running recalculation may go without
+// reporting progress before it is considered interrupted
+const thicknessStaleAfter = 5 * time.Minute
+
+// errRecalculationRunning is returned when a recalculation is requested
+// while another one is still running
+var errRecalculationRunning = errors.New("a thickness recalculation is already running")
+
+// thicknessReloadInterval is how often every instance reloads the bands,
+// so that a change made through another instance is picked up
+const thicknessReloadInterval = 30 * time.Second
+
+// queryThicknessBands reads the bands ordered by page range
+func queryThicknessBands(q queryer) ([]models.ThicknessBand, error) {
+	rows, err := q.Query(`
+		SELECT id, name, min_pages, max_pages, translations, modified_at, modified_by
+		FROM thickness_bands
+		ORDER BY min_pages
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bands := []models.ThicknessBand{}
+	for rows.Next() {
+		var band models.ThicknessBand
+		var translations []byte
+		var modifiedBy sql.NullString
+		err := rows.Scan(&band.ID, &band.Name, &band.MinPages, &band.MaxPages, &translations, &band.ModifiedAt, &modifiedBy)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(translations, &band.Translations); err != nil {
+			return nil, err
+		}
+		band.ModifiedBy = modifiedBy.String
+		bands = append(bands, band)
+	}
+	return bands, rows.Err()
+}
+
+// LoadThicknessBands makes the stored bands the rules used by
+// CalculateThickness. It is called at startup, after every change and
+// periodically by StartThicknessBandsReload.
+func LoadThicknessBands() error {
+	bands, err := queryThicknessBands(config.DB)
+	if err != nil {
+		return err
+	}
+	models.SetThicknessBands(bands)
+	return nil
+}
+
+// StartThicknessBandsReload runs the worker that reloads the bands from the
+// database, until ctx is cancelled
+func StartThicknessBandsReload(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(thicknessReloadInterval):
+			}
+
+			if err := LoadThicknessBands(); err != nil {
+				log.Println("Failed to reload thickness bands:", err)
+			}
+		}
+	}()
+}
+
+// requestedLanguage is the language asked for with the lang parameter or,
+// failing that, the first Accept-Language entry, as a lower-case primary
+// subtag ("en" for "en-US")
+func requestedLanguage(c *gin.Context) string {
+	lang := c.Query("lang")
+	if lang == "" {
+		lang, _, _ = strings.Cut(c.GetHeader("Accept-Language"), ",")
+		lang, _, _ = strings.Cut(lang, ";")
+	}
+	lang, _, _ = strings.Cut(strings.TrimSpace(lang), "-")
+	if lang == "*" {
+		return ""
+	}
+	return strings.ToLower(lang)
+}
+
+// translateThickness sets the thickness label of books in lang
+func translateThickness(books []models.Book, lang string) {
+	for i := range books {
+		books[i].ThicknessLabel = models.ThicknessLabel(books[i].Thickness, lang)
+	}
+}
+
+// GetThicknessBands lists the thickness bands
+func GetThicknessBands(c *gin.Context) {
+	bands, err := queryThicknessBands(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch thickness bands",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": bands,
+	})
+}
+
+// UpdateThicknessBands replaces every band and starts a recalculation of
+// the stored thickness of all books
+func UpdateThicknessBands(c *gin.Context) {
+	var input models.ThicknessBandsInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM thickness_bands"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update thickness bands",
+		})
+		return
+	}
+
+	for _, band := range input.Bands {
+		translations := band.Translations
+		if translations == nil {
+			translations = map[string]string{}
+		}
+		encoded, _ := json.Marshal(translations)
+
+		_, err := tx.Exec(`
+			INSERT INTO thickness_bands (name, min_pages, max_pages, translations, modified_at, modified_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, band.Name, band.MinPages, band.MaxPages, encoded, time.Now(), usernameStr)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update thickness bands",
+			})
+			return
+		}
+	}
+
+	// The bands are only replaced together with starting their recalculation
+	job, err := createThicknessRecalculation(tx, usernameStr)
+	if err == errRecalculationRunning {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A thickness recalculation is already running; try again when it has finished",
+		})
+		return
+	}
+
+	var bands []models.ThicknessBand
+	if err == nil {
+		bands, err = queryThicknessBands(tx)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update thickness bands",
+		})
+		return
+	}
+	models.SetThicknessBands(bands)
+
+	go runThicknessRecalculation(context.Background(), job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       "Thickness bands updated successfully",
+		"data":          bands,
+		"recalculation": job,
+	})
+}
+
+// RecalculateThickness starts a recalculation without changing the bands
+func RecalculateThickness(c *gin.Context) {
+	username, _ := c.Get("username")
+	usernameStr := username.(string)
+
+	var job models.ThicknessRecalculation
+	err := withTx(config.DB, func(q queryer) error {
+		var err error
+		job, err = createThicknessRecalculation(q, usernameStr)
+		return err
+	})
+	if err == errRecalculationRunning {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A thickness recalculation is already running",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start recalculation",
+		})
+		return
+	}
+	go runThicknessRecalculation(context.Background(), job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Recalculation started",
+		"data":    job,
+	})
+}
+
+// GetThicknessRecalculation reports the progress of a recalculation
+func GetThicknessRecalculation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid recalculation ID",
+		})
+		return
+	}
+
+	var job models.ThicknessRecalculation
+	err = config.DB.QueryRow(`
+		SELECT id, status, total, processed, updated, error, started_at, finished_at, created_by
+		FROM thickness_recalculations
+		WHERE id = $1
+	`, id).Scan(&job.ID, &job.Status, &job.Total, &job.Processed, &job.Updated, &job.Error,
+		&job.StartedAt, &job.FinishedAt, &job.CreatedBy)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Recalculation not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch recalculation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": job,
+	})
+}
+
+// createThicknessRecalculation records a new job, which the caller runs
+// with runThicknessRecalculation once q is committed. Only one job runs at a
+// time: errRecalculationRunning is returned while another one is running.
+// A job that stopped reporting progress, for example because its instance
+// was restarted, is marked failed instead.
+func createThicknessRecalculation(q queryer, username string) (models.ThicknessRecalculation, error) {
+	job := models.ThicknessRecalculation{
+		Status:    models.RecalculationRunning,
+		StartedAt: time.Now(),
+		CreatedBy: username,
+	}
+
+	if _, err := q.Exec("SELECT pg_advisory_xact_lock(hashtext('thickness_recalculations'))"); err != nil {
+		return job, err
+	}
+
+	_, err := q.Exec(`
+		UPDATE thickness_recalculations
+		SET status = $1, error = 'interrupted', finished_at = $2
+		WHERE status = $3 AND COALESCE(heartbeat_at, started_at) < $4
+	`, models.RecalculationFailed, job.StartedAt, models.RecalculationRunning, job.StartedAt.Add(-thicknessStaleAfter))
+	if err != nil {
+		return job, err
+	}
+
+	var running bool
+	err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM thickness_recalculations WHERE status = $1)", models.RecalculationRunning).Scan(&running)
+	if err != nil {
+		return job, err
+	}
+	if running {
+		return job, errRecalculationRunning
+	}
+
+	if err := q.QueryRow("SELECT COUNT(*) FROM books").Scan(&job.Total); err != nil {
+		return job, err
+	}
+
+	err = q.QueryRow(`
+		INSERT INTO thickness_recalculations (status, total, started_at, heartbeat_at, created_by)
+		VALUES ($1, $2, $3, $3, $4)
+		RETURNING id
+	`, job.Status, job.Total, job.StartedAt, username).Scan(&job.ID)
+	return job, err
+}
+
+// runThicknessRecalculation re-derives books.thickness from the stored
+// bands in batches of book IDs. Changed books get a new version, since
+// their representation (and ETag) changes.
+func runThicknessRecalculation(ctx context.Context, jobID int) {
+	lastID, processed, updated := 0, 0, 0
+
+	fail := func(err error) {
+		log.Println("Thickness recalculation failed:", err)
+		_, err = config.DB.ExecContext(ctx, `
+			UPDATE thickness_recalculations
+			SET status = $1, error = $2, finished_at = $3
+			WHERE id = $4
+		`, models.RecalculationFailed, err.Error(), time.Now(), jobID)
+		if err != nil {
+			log.Println("Failed to record recalculation status:", err)
+		}
+	}
+
+	for {
+		var batchEnd sql.NullInt64
+		var batchSize int
+		err := config.DB.QueryRowContext(ctx, `
+			SELECT MAX(id), COUNT(*)
+			FROM (SELECT id FROM books WHERE id > $1 ORDER BY id LIMIT $2) batch
+		`, lastID, thicknessBatchSize).Scan(&batchEnd, &batchSize)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		if !batchEnd.Valid {
+			break
+		}
+
+		result, err := config.DB.ExecContext(ctx, `
+			UPDATE books
+			SET thickness = bands.name, version = books.version + 1
+			FROM thickness_bands bands
+			WHERE books.id > $1 AND books.id <= $2
+			  AND books.total_page >= bands.min_pages
+			  AND (bands.max_pages IS NULL OR books.total_page <= bands.max_pages)
+			  AND books.thickness <> bands.name
+		`, lastID, batchEnd.Int64)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		changed, _ := result.RowsAffected()
+		lastID = int(batchEnd.Int64)
+		processed += batchSize
+		updated += int(changed)
+
+		_, err = config.DB.ExecContext(ctx, `
+			UPDATE thickness_recalculations
+			SET processed = $1, updated = $2, heartbeat_at = $3
+			WHERE id = $4
+		`, processed, updated, time.Now(), jobID)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	_, err := config.DB.ExecContext(ctx, `
+		UPDATE thickness_recalculations
+		SET status = $1, finished_at = $2
+		WHERE id = $3
+	`, models.RecalculationDone, time.Now(), jobID)
+	if err != nil {
+		log.Println("Failed to record recalculation status:", err)
+	}
+}
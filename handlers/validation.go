@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"book-management/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation errors name fields the way clients send them
+func init() {
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// validatable is implemented by inputs with rules beyond their binding tags
+type validatable interface {
+	Validate() error
+}
+
+// bindJSON decodes the request body into obj and validates it. When that
+// fails a response is written and false is returned.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	var tagErrors validator.ValidationErrors
+	if err != nil && !errors.As(err, &tagErrors) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+
+	if err := checkInput(obj, err); err != nil {
+		validationFailed(c, err)
+		return false
+	}
+	return true
+}
+
+// validateInput checks obj against its binding tags and its own rules
+func validateInput(obj interface{}) error {
+	return checkInput(obj, binding.Validator.ValidateStruct(obj))
+}
+
+// checkInput merges the result of binding validation with the rules of obj
+// itself, so every rejected field is reported at once
+func checkInput(obj interface{}, err error) error {
+	var errs models.ValidationErrors
+
+	var tagErrors validator.ValidationErrors
+	if errors.As(err, &tagErrors) {
+		for _, tagError := range tagErrors {
+			errs = append(errs, fieldError(tagError))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if v, ok := obj.(validatable); ok {
+		if err := v.Validate(); err != nil {
+			var ruleErrors models.ValidationErrors
+			if !errors.As(err, &ruleErrors) {
+				return err
+			}
+			errs = append(errs, ruleErrors...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldError describes a failed binding tag
+func fieldError(tagError validator.FieldError) models.FieldError {
+	field := tagError.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	unit := ""
+	switch tagError.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		unit = " items"
+	}
+
+	var message string
+	switch tagError.Tag() {
+	case "required":
+		message = "is required"
+	case "min":
+		message = fmt.Sprintf("must be at least %s%s", tagError.Param(), unit)
+	case "max":
+		message = fmt.Sprintf("must be at most %s%s", tagError.Param(), unit)
+	case "oneof":
+		message = "must be one of " + strings.ReplaceAll(tagError.Param(), " ", ", ")
+	case "isbn":
+		message = "must be a valid ISBN-10 or ISBN-13"
+	default:
+		message = "failed the " + tagError.Tag() + " rule"
+	}
+
+	return models.FieldError{Field: field, Rule: tagError.Tag(), Message: message}
+}
+
+// errorMessages flattens a validation error into one message per field
+func errorMessages(err error) []string {
+	var errs models.ValidationErrors
+	if !errors.As(err, &errs) {
+		return []string{err.Error()}
+	}
+
+	messages := make([]string, len(errs))
+	for i, fieldError := range errs {
+		messages[i] = fieldError.Field + ": " + fieldError.Message
+	}
+	return messages
+}
+
+// validationFailed writes a 400 listing every rejected field
+func validationFailed(c *gin.Context, err error) {
+	var errs models.ValidationErrors
+	if !errors.As(err, &errs) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "Validation failed",
+		"errors": errs,
+	})
+}
+
+// GetBookValidationRules shows the limits books are currently validated
+// against, so clients can check input before sending it
+func GetBookValidationRules(c *gin.Context) {
+	rules := models.CurrentBookRules()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":             rules,
+		"max_release_year": rules.MaxReleaseYear(time.Now()),
+	})
+}
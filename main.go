@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"book-management/config"
+	"book-management/handlers"
 	"book-management/routes"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +23,46 @@ func main() {
 	config.InitDB()
 	defer config.CloseDB()
 
+	// Load the thickness classification rules
+	if err := handlers.LoadThicknessBands(); err != nil {
+		log.Println("Failed to load thickness bands, using the default rule:", err)
+	}
+
+	// Pick up thickness band changes made through other instances
+	handlers.StartThicknessBandsReload(context.Background())
+
+	// Load the exchange rates used for price conversion
+	if err := handlers.LoadExchangeRates(); err != nil {
+		log.Println("Failed to load exchange rates:", err)
+	}
+
+	// Load the public base URL used in absolute links
+	config.InitServer()
+
+	// Initialize blob storage for uploaded files
+	config.InitStorage()
+
+	// Load the configurable book validation rules
+	config.InitValidation()
+
+	// Load the loan period, loan limit and renewal rules
+	config.InitCirculation()
+
+	// Mirror external cover images in the background
+	handlers.StartImageMirror(context.Background())
+
+	// Apply scheduled price changes when they become due
+	handlers.StartPriceScheduler(context.Background())
+
+	// Expire holds that were not picked up in time
+	handlers.StartHoldExpiry(context.Background())
+
+	// Mark overdue loans and accrue fines once a day
+	handlers.StartOverdueJob(context.Background())
+
+	// Recompute stale similar book suggestions
+	handlers.StartSimilarityRefresh(context.Background())
+
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -0,0 +1,303 @@
+package marc
+
+import (
+	"book-management/models"
+	"book-management/money"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLeader is used for records built from books without a source record
+const DefaultLeader = "00000nam a2200000 i 4500"
+
+// RawKey is the key MARC records are stored under in books.raw_metadata
+const RawKey = "marc"
+
+var (
+	pagesPattern = regexp.MustCompile(`(?i)(\d+)\s*(?:p\b|p\.|pages|page|halaman|hlm)`)
+	numberRegexp = regexp.MustCompile(`\d+`)
+	yearPattern  = regexp.MustCompile(`\d{4}`)
+)
+
+// ToBookInput maps a record onto book fields:
+//
+//	245 $a $b → title
+//	020 $a    → isbn
+//	100/700 $a → authors
+//	264 $b    → publisher (260 $b as fallback)
+//	300 $a    → total_page
+//	264 $c    → release_year (260 $c and 008/07-10 as fallbacks)
+//	520 $a    → description
+//	365 $b $c → price_minor, currency
+//
+// Category is not part of MARC and must be set by the caller.
+func ToBookInput(record Record) models.BookInput {
+	var input models.BookInput
+
+	if f := record.Field("245"); f != nil {
+		input.Title = trimPunctuation(f.Subfield("a"))
+		if subtitle := trimPunctuation(f.Subfield("b")); subtitle != "" {
+			input.Title += " : " + subtitle
+		}
+	}
+
+	if f := record.Field("020"); f != nil {
+		// "9786020331607 (pbk.)" → "9786020331607"
+		if fields := strings.Fields(f.Subfield("a")); len(fields) > 0 {
+			input.ISBN = models.NormalizeISBN(fields[0])
+		}
+	}
+
+	input.Authors = authors(record)
+	if f := imprint(record); f != nil {
+		input.Publisher = trimPunctuation(f.Subfield("b"))
+	}
+
+	if f := record.Field("300"); f != nil {
+		input.TotalPage = parsePages(f.Subfield("a"))
+	}
+
+	input.ReleaseYear = releaseYear(record)
+
+	if f := record.Field("520"); f != nil {
+		input.Description = strings.TrimSpace(f.Subfield("a"))
+	}
+
+	if f := record.Field("365"); f != nil {
+		input.Currency = money.BaseCurrency
+		if code := strings.ToUpper(strings.TrimSpace(f.Subfield("c"))); money.ValidCode(code) {
+			input.Currency = code
+		}
+		input.PriceMinor = parsePrice(f.Subfield("b"), input.Currency)
+	}
+
+	return input
+}
+
+// authors reads the main (100) and added (700) personal name entries
+func authors(record Record) []string {
+	var names []string
+	for _, f := range record.Fields {
+		if f.Tag == "100" || f.Tag == "700" {
+			if name := trimPunctuation(f.Subfield("a")); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// imprint returns the publication 264, any 264, or the 260 field
+func imprint(record Record) *Field {
+	for i := range record.Fields {
+		if record.Fields[i].Tag == "264" && record.Fields[i].Ind2 == "1" {
+			return &record.Fields[i]
+		}
+	}
+	if f := record.Field("264"); f != nil {
+		return f
+	}
+	return record.Field("260")
+}
+
+// parsePages extracts the page count from a 300 $a extent such as
+// "xii, 345 p. :" or "210 halaman"
+func parsePages(extent string) int {
+	if m := pagesPattern.FindStringSubmatch(extent); m != nil {
+		pages, _ := strconv.Atoi(m[1])
+		return pages
+	}
+	if m := numberRegexp.FindString(extent); m != "" {
+		pages, _ := strconv.Atoi(m)
+		return pages
+	}
+	return 0
+}
+
+// parsePrice reads a 365 $b amount such as "85000", "85.000" or "25.00"
+// into minor units of currency. A separator followed by as many digits as
+// the currency has decimal places starts the fraction; any other separator
+// groups thousands.
+func parsePrice(amount, currency string) int64 {
+	amount = strings.TrimSpace(amount)
+	fraction := ""
+	if i := strings.LastIndexAny(amount, ".,"); i >= 0 {
+		if digits := amount[i+1:]; len(digits) == money.Exponent(currency) && numberRegexp.FindString(digits) == digits {
+			amount, fraction = amount[:i], digits
+		}
+	}
+
+	whole := strings.Join(numberRegexp.FindAllString(amount, -1), "")
+	if whole == "" && fraction == "" {
+		return 0
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if fraction != "" {
+		whole += "." + fraction
+	}
+	price, err := money.Parse(whole, currency)
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// releaseYear reads the publication year, preferring 264 with second
+// indicator 1 (publication) over other 264s, 260 and the 008 date
+func releaseYear(record Record) int {
+	var candidates []string
+	for _, f := range record.Fields {
+		if f.Tag == "264" && f.Ind2 == "1" {
+			candidates = append(candidates, f.Subfield("c"))
+		}
+	}
+	for _, f := range record.Fields {
+		if f.Tag == "264" || f.Tag == "260" {
+			candidates = append(candidates, f.Subfield("c"))
+		}
+	}
+	if f := record.Field("008"); f != nil && len(f.Value) >= 11 {
+		candidates = append(candidates, f.Value[7:11])
+	}
+
+	for _, candidate := range candidates {
+		if year := yearPattern.FindString(candidate); year != "" {
+			n, _ := strconv.Atoi(year)
+			return n
+		}
+	}
+	return 0
+}
+
+// FromBook builds a record for a book. When the book was imported from MARC
+// the stored source record is used as the base, so fields we do not map are
+// written back unchanged; mapped fields are overwritten with current values.
+func FromBook(book models.Book) Record {
+	record := Record{Leader: DefaultLeader}
+	if raw, ok := DecodeRaw(book.RawMetadata); ok {
+		record = raw
+	}
+
+	if record.Field("001") == nil {
+		record.SetField(Field{Tag: "001", Value: strconv.Itoa(book.ID)})
+	}
+	record.SetField(Field{Tag: "005", Value: book.ModifiedAt.UTC().Format("20060102150405") + ".0"})
+
+	if book.ISBN != "" {
+		isbn := fieldOrNew(&record, "020", " ", " ")
+		if fields := strings.Fields(isbn.Subfield("a")); len(fields) == 0 || models.NormalizeISBN(fields[0]) != book.ISBN {
+			isbn.SetSubfield("a", book.ISBN)
+		}
+		record.SetField(isbn)
+	}
+
+	title := fieldOrNew(&record, "245", "0", "0")
+	stored := trimPunctuation(title.Subfield("a"))
+	if subtitle := trimPunctuation(title.Subfield("b")); subtitle != "" {
+		stored += " : " + subtitle
+	}
+	if stored != book.Title {
+		title.SetSubfield("a", book.Title)
+		title.RemoveSubfield("b")
+	}
+	record.SetField(title)
+
+	// Update the imprint the record already uses, defaulting to 264
+	imprintTag := "264"
+	if record.Field("264") == nil && record.Field("260") != nil {
+		imprintTag = "260"
+	}
+	pub := fieldOrNew(&record, imprintTag, " ", "1")
+	if releaseYear(Record{Fields: []Field{pub}}) != book.ReleaseYear {
+		pub.SetSubfield("c", strconv.Itoa(book.ReleaseYear))
+	}
+	if book.Publisher != "" && trimPunctuation(pub.Subfield("b")) != book.Publisher {
+		pub.SetSubfield("b", book.Publisher)
+	}
+	record.SetField(pub)
+
+	// Rewrite the name entries only when the author list changed
+	if strings.Join(authors(record), "|") != strings.Join(book.Authors, "|") {
+		kept := record.Fields[:0]
+		for _, f := range record.Fields {
+			if f.Tag != "100" && f.Tag != "700" {
+				kept = append(kept, f)
+			}
+		}
+		record.Fields = kept
+		for i, name := range book.Authors {
+			tag := "700"
+			if i == 0 {
+				tag = "100"
+			}
+			record.Fields = append(record.Fields, Field{Tag: tag, Ind1: "1", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: name}}})
+		}
+		sortFields(record.Fields)
+	}
+
+	extent := fieldOrNew(&record, "300", " ", " ")
+	if parsePages(extent.Subfield("a")) != book.TotalPage {
+		extent.SetSubfield("a", strconv.Itoa(book.TotalPage)+" pages")
+	}
+	record.SetField(extent)
+
+	if book.Description != "" {
+		summary := fieldOrNew(&record, "520", " ", " ")
+		summary.SetSubfield("a", book.Description)
+		record.SetField(summary)
+	} else {
+		record.RemoveField("520")
+	}
+
+	price := fieldOrNew(&record, "365", " ", " ")
+	price.SetSubfield("b", money.Format(book.PriceMinor, book.Currency))
+	price.SetSubfield("c", book.Currency)
+	record.SetField(price)
+
+	return record
+}
+
+// sortFields orders fields by tag, keeping the order of repeated tags
+func sortFields(fields []Field) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Tag < fields[j].Tag
+	})
+}
+
+// fieldOrNew returns a copy of the first field with tag, or a new empty field
+func fieldOrNew(record *Record, tag, ind1, ind2 string) Field {
+	if f := record.Field(tag); f != nil {
+		field := *f
+		field.Subfields = append([]Subfield(nil), f.Subfields...)
+		return field
+	}
+	return Field{Tag: tag, Ind1: ind1, Ind2: ind2}
+}
+
+// EncodeRaw wraps a record for storage in books.raw_metadata
+func EncodeRaw(record Record) (json.RawMessage, error) {
+	return json.Marshal(map[string]Record{RawKey: record})
+}
+
+// DecodeRaw extracts the MARC record from books.raw_metadata
+func DecodeRaw(raw json.RawMessage) (Record, bool) {
+	if len(raw) == 0 {
+		return Record{}, false
+	}
+
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &stored); err != nil || stored[RawKey] == nil {
+		return Record{}, false
+	}
+
+	var record Record
+	if err := json.Unmarshal(stored[RawKey], &record); err != nil {
+		return Record{}, false
+	}
+	return record, true
+}
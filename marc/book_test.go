@@ -0,0 +1,188 @@
+package marc
+
+import (
+	"book-management/models"
+	"reflect"
+	"testing"
+)
+
+func TestParsePages(t *testing.T) {
+	tests := []struct {
+		extent string
+		want   int
+	}{
+		{"345 p. :", 345},
+		{"xii, 345 p. ;", 345},
+		{"210 halaman", 210},
+		{"viii, 120 hlm. ;", 120},
+		{"1 volume (412 pages)", 412},
+		{"250", 250},
+		{"1 online resource", 1},
+		{"", 0},
+		{"xii p.", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parsePages(tt.extent); got != tt.want {
+			t.Errorf("parsePages(%q) = %d, want %d", tt.extent, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+	}{
+		{"85000", "IDR", 8500000},
+		{" 85000 ", "IDR", 8500000},
+		{"85.000", "IDR", 8500000},
+		{"1.250.000", "IDR", 125000000},
+		{"25.00", "IDR", 2500},
+		{"25,00", "IDR", 2500},
+		{"Rp 85.000", "IDR", 8500000},
+		{"19.99", "USD", 1999},
+		{"1,234.50", "USD", 123450},
+		{"$5", "USD", 500},
+		{"1.500", "JPY", 1500},
+		{"12.345", "KWD", 12345},
+		{"", "IDR", 0},
+		{"free", "IDR", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parsePrice(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("parsePrice(%q, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseYear(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []Field
+		want   int
+	}{
+		{
+			name: "publication 264 before other 264",
+			fields: []Field{
+				{Tag: "264", Ind2: "4", Subfields: []Subfield{{Code: "c", Value: "©2001"}}},
+				{Tag: "264", Ind2: "1", Subfields: []Subfield{{Code: "c", Value: "2005."}}},
+			},
+			want: 2005,
+		},
+		{
+			name:   "260 fallback",
+			fields: []Field{{Tag: "260", Subfields: []Subfield{{Code: "c", Value: "c1980."}}}},
+			want:   1980,
+		},
+		{
+			name:   "008 fallback",
+			fields: []Field{{Tag: "008", Value: "200101s1999    io            000 0 ind d"}},
+			want:   1999,
+		},
+		{
+			name: "264 without a year falls back to 008",
+			fields: []Field{
+				{Tag: "008", Value: "200101s1999    io            000 0 ind d"},
+				{Tag: "264", Ind2: "1", Subfields: []Subfield{{Code: "c", Value: "[n.d.]"}}},
+			},
+			want: 1999,
+		},
+		{
+			name:   "short 008",
+			fields: []Field{{Tag: "008", Value: "2001"}},
+			want:   0,
+		},
+		{name: "no date", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseYear(Record{Fields: tt.fields}); got != tt.want {
+				t.Errorf("releaseYear() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToBookInput(t *testing.T) {
+	record := Record{
+		Leader: DefaultLeader,
+		Fields: []Field{
+			{Tag: "001", Value: "123"},
+			{Tag: "020", Subfields: []Subfield{{Code: "a", Value: "978-602-03-3160-7 (pbk.)"}}},
+			{Tag: "100", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "Pramoedya Ananta Toer,"}}},
+			{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []Subfield{
+				{Code: "a", Value: "Bumi manusia :"},
+				{Code: "b", Value: "roman /"},
+			}},
+			{Tag: "264", Ind2: "1", Subfields: []Subfield{
+				{Code: "b", Value: "Lentera Dipantara,"},
+				{Code: "c", Value: "2005."},
+			}},
+			{Tag: "300", Subfields: []Subfield{{Code: "a", Value: "535 hlm. ;"}}},
+			{Tag: "365", Subfields: []Subfield{{Code: "b", Value: "125.000"}}},
+			{Tag: "520", Subfields: []Subfield{{Code: "a", Value: " Roman sejarah. "}}},
+			{Tag: "700", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "Max Lane."}}},
+		},
+	}
+
+	got := ToBookInput(record)
+	if got.Title != "Bumi manusia : roman" {
+		t.Errorf("Title = %q", got.Title)
+	}
+	if got.ISBN != "9786020331607" {
+		t.Errorf("ISBN = %q", got.ISBN)
+	}
+	if want := []string{"Pramoedya Ananta Toer", "Max Lane"}; !reflect.DeepEqual(got.Authors, want) {
+		t.Errorf("Authors = %q, want %q", got.Authors, want)
+	}
+	if got.Publisher != "Lentera Dipantara" {
+		t.Errorf("Publisher = %q", got.Publisher)
+	}
+	if got.ReleaseYear != 2005 || got.TotalPage != 535 || got.PriceMinor != 12500000 || got.Currency != "IDR" {
+		t.Errorf("ReleaseYear, TotalPage, PriceMinor, Currency = %d, %d, %d, %s", got.ReleaseYear, got.TotalPage, got.PriceMinor, got.Currency)
+	}
+	if got.Description != "Roman sejarah." {
+		t.Errorf("Description = %q", got.Description)
+	}
+}
+
+func TestFromBookDescription(t *testing.T) {
+	stored := Record{
+		Leader: DefaultLeader,
+		Fields: []Field{
+			{Tag: "245", Ind1: "0", Ind2: "0", Subfields: []Subfield{{Code: "a", Value: "Bumi manusia"}}},
+			{Tag: "520", Subfields: []Subfield{{Code: "a", Value: "Roman sejarah."}}},
+			{Tag: "520", Subfields: []Subfield{{Code: "a", Value: "Buku pertama Tetralogi Buru."}}},
+		},
+	}
+	raw, err := EncodeRaw(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		want        []string
+	}{
+		{"replaced", "Kisah Minke.", []string{"Kisah Minke.", "Buku pertama Tetralogi Buru."}},
+		{"cleared", "", nil},
+	}
+
+	for _, tt := range tests {
+		record := FromBook(models.Book{Title: "Bumi manusia", Description: tt.description, Currency: "IDR", RawMetadata: raw})
+		var got []string
+		for _, f := range record.Fields {
+			if f.Tag == "520" {
+				got = append(got, f.Subfield("a"))
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 520 $a = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+const (
+	leaderLength    = 24
+	directoryLength = 12
+)
+
+// ReadBinary decodes every ISO 2709 record in r
+func ReadBinary(r io.Reader) ([]Record, error) {
+	reader := bufio.NewReader(r)
+	var records []Record
+	for {
+		// Skip whitespace some exporters put between records
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == '\n' || b[0] == '\r' || b[0] == ' ' {
+			reader.ReadByte()
+			continue
+		}
+
+		head := make([]byte, 5)
+		if _, err := io.ReadFull(reader, head); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		length, err := strconv.Atoi(string(head))
+		if err != nil || length < leaderLength+1 {
+			return nil, fmt.Errorf("record %d: invalid record length %q", len(records)+1, head)
+		}
+
+		data := make([]byte, length)
+		copy(data, head)
+		if _, err := io.ReadFull(reader, data[5:]); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+
+		record, err := decodeRecord(data)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// decodeRecord decodes a single ISO 2709 record
+func decodeRecord(data []byte) (Record, error) {
+	if data[len(data)-1] != recordTerminator {
+		return Record{}, errors.New("missing record terminator")
+	}
+
+	record := Record{Leader: string(data[:leaderLength])}
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || base <= leaderLength || base > len(data) {
+		return Record{}, errors.New("invalid base address of data")
+	}
+
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryLength != 0 {
+		return Record{}, errors.New("invalid directory length")
+	}
+
+	for i := 0; i < len(directory); i += directoryLength {
+		entry := directory[i : i+directoryLength]
+		tag := string(entry[:3])
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || start < 0 || length < 1 || base+start < leaderLength || base+start+length > len(data) {
+			return Record{}, fmt.Errorf("invalid directory entry for tag %s", tag)
+		}
+
+		// Drop the field terminator
+		value := data[base+start : base+start+length-1]
+		field := Field{Tag: tag}
+		if field.IsControl() {
+			field.Value = string(value)
+		} else {
+			if len(value) < 2 {
+				return Record{}, fmt.Errorf("field %s is missing indicators", tag)
+			}
+			field.Ind1 = string(value[0])
+			field.Ind2 = string(value[1])
+			for _, part := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+				if len(part) == 0 {
+					continue
+				}
+				field.Subfields = append(field.Subfields, Subfield{
+					Code:  string(part[0]),
+					Value: string(part[1:]),
+				})
+			}
+		}
+		record.Fields = append(record.Fields, field)
+	}
+
+	return record, nil
+}
+
+// WriteBinary encodes records as ISO 2709. The leader's length, base address
+// and character coding (UTF-8) positions are recomputed.
+func WriteBinary(w io.Writer, records ...Record) error {
+	for _, record := range records {
+		data, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeRecord encodes a single record as ISO 2709
+func encodeRecord(record Record) ([]byte, error) {
+	var directory, body bytes.Buffer
+	for _, field := range record.Fields {
+		if len(field.Tag) != 3 {
+			return nil, fmt.Errorf("invalid tag %q", field.Tag)
+		}
+
+		start := body.Len()
+		if field.IsControl() {
+			body.WriteString(field.Value)
+		} else {
+			body.WriteString(indicator(field.Ind1))
+			body.WriteString(indicator(field.Ind2))
+			for _, sf := range field.Subfields {
+				body.WriteByte(subfieldDelimiter)
+				body.WriteString(sf.Code)
+				body.WriteString(sf.Value)
+			}
+		}
+		body.WriteByte(fieldTerminator)
+
+		length := body.Len() - start
+		if length > 9999 || start > 99999 {
+			return nil, fmt.Errorf("field %s is too long for ISO 2709", field.Tag)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", field.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+	body.WriteByte(recordTerminator)
+
+	base := leaderLength + directory.Len()
+	total := base + body.Len()
+	if total > 99999 {
+		return nil, errors.New("record is too long for ISO 2709")
+	}
+
+	leader := []byte(record.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(DefaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], "4500")
+
+	data := make([]byte, 0, total)
+	data = append(data, leader...)
+	data = append(data, directory.Bytes()...)
+	data = append(data, body.Bytes()...)
+	return data, nil
+}
+
+// indicator returns a single indicator character, blank when unset
+func indicator(ind string) string {
+	if len(ind) != 1 {
+		return " "
+	}
+	return ind
+}
@@ -0,0 +1,134 @@
+package marc
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleRecord() Record {
+	return Record{
+		Leader: "00000nam a2200000 i 4500",
+		Fields: []Field{
+			{Tag: "001", Value: "123"},
+			{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []Subfield{
+				{Code: "a", Value: "Bumi manusia :"},
+				{Code: "b", Value: "roman"},
+			}},
+			{Tag: "300", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "535 hlm."}}},
+		},
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteBinary(&buf, sampleRecord(), sampleRecord()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exporters sometimes separate records with a newline
+	data := strings.Replace(buf.String(), "\x1d", "\x1d\n", 1)
+
+	records, err := ReadBinary(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+	if !reflect.DeepEqual(records[0].Fields, sampleRecord().Fields) {
+		t.Errorf("fields = %+v, want %+v", records[0].Fields, sampleRecord().Fields)
+	}
+}
+
+func TestDecodeRecordRejectsMalformedRecords(t *testing.T) {
+	valid, err := encodeRecord(sampleRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first directory entry starts right after the leader
+	entry := leaderLength
+	tests := []struct {
+		name   string
+		offset int
+		value  string
+	}{
+		{"negative start", entry + 7, "-0001"},
+		{"start past the end", entry + 7, "99999"},
+		{"non-numeric start", entry + 7, "abcde"},
+		{"zero length", entry + 3, "0000"},
+		{"negative length", entry + 3, "-001"},
+		{"length past the end", entry + 3, "9999"},
+		{"non-numeric base", 12, "abcde"},
+		{"base inside the leader", 12, "00010"},
+		{"negative base", 12, "-0030"},
+		{"base past the end", 12, "99999"},
+		{"directory not a multiple of 12", 12, "00050"},
+		{"missing record terminator", len(valid) - 1, "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte(nil), valid...)
+			copy(data[tt.offset:], tt.value)
+			if _, err := decodeRecord(data); err == nil {
+				t.Error("decodeRecord() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestReadBinaryRejectsBadLengths(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"non-numeric length", "abcde"},
+		{"length shorter than the leader", "00010"},
+		{"truncated record", "00100nam a2200000 i 4500"},
+		{"truncated length", "001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadBinary(strings.NewReader(tt.data)); err == nil {
+				t.Error("ReadBinary() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestReadXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000 i 4500</leader>
+    <datafield tag="245" ind1="1" ind2="0">
+      <subfield code="a">Bumi manusia :</subfield>
+      <subfield code="b">roman</subfield>
+    </datafield>
+    <controlfield tag="001">123</controlfield>
+    <datafield tag="300" ind1=" " ind2=" ">
+      <subfield code="a">535 hlm.</subfield>
+    </datafield>
+  </record>
+</collection>`
+
+	records, err := ReadXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("read %d records, want 1", len(records))
+	}
+	// Control fields come before data fields
+	if !reflect.DeepEqual(records[0].Fields, sampleRecord().Fields) {
+		t.Errorf("fields = %+v, want %+v", records[0].Fields, sampleRecord().Fields)
+	}
+
+	if _, err := ReadXML(strings.NewReader("<record><leader>")); err == nil {
+		t.Error("ReadXML() of truncated XML succeeded, want an error")
+	}
+}
@@ -0,0 +1,129 @@
+package marc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Namespace is the MARCXML namespace
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ReadXML decodes a MARCXML <collection> or a single <record>
+func ReadXML(r io.Reader) ([]Record, error) {
+	decoder := xml.NewDecoder(r)
+	var records []Record
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var raw xmlRecord
+		if err := decoder.DecodeElement(&raw, &start); err != nil {
+			return nil, err
+		}
+		records = append(records, raw.record())
+	}
+}
+
+// record converts the XML form into a Record, keeping control fields
+// before data fields
+func (x xmlRecord) record() Record {
+	record := Record{Leader: x.Leader}
+	for _, cf := range x.ControlFields {
+		record.Fields = append(record.Fields, Field{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range x.DataFields {
+		field := Field{Tag: df.Tag, Ind1: df.Ind1, Ind2: df.Ind2}
+		for _, sf := range df.Subfields {
+			field.Subfields = append(field.Subfields, Subfield{Code: sf.Code, Value: sf.Value})
+		}
+		record.Fields = append(record.Fields, field)
+	}
+	return record
+}
+
+// XMLWriter streams records as a MARCXML collection
+type XMLWriter struct {
+	encoder *xml.Encoder
+}
+
+// NewXMLWriter writes the XML header and opening <collection> element
+func NewXMLWriter(w io.Writer) (*XMLWriter, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err := encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &XMLWriter{encoder: encoder}, nil
+}
+
+// Write encodes a single <record>
+func (w *XMLWriter) Write(record Record) error {
+	x := xmlRecord{Leader: record.Leader}
+	for _, field := range record.Fields {
+		if field.IsControl() {
+			x.ControlFields = append(x.ControlFields, xmlControlField{Tag: field.Tag, Value: field.Value})
+			continue
+		}
+		df := xmlDataField{Tag: field.Tag, Ind1: indicator(field.Ind1), Ind2: indicator(field.Ind2)}
+		for _, sf := range field.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: sf.Code, Value: sf.Value})
+		}
+		x.DataFields = append(x.DataFields, df)
+	}
+	return w.encoder.Encode(x)
+}
+
+// Flush flushes buffered XML to the underlying writer
+func (w *XMLWriter) Flush() error {
+	return w.encoder.Flush()
+}
+
+// Close writes the closing </collection> element
+func (w *XMLWriter) Close() error {
+	if err := w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+	return w.encoder.Flush()
+}
@@ -0,0 +1,106 @@
+// Package marc reads and writes MARC 21 bibliographic records in binary
+// (ISO 2709) and MARCXML form and maps them to books.
+package marc
+
+import "strings"
+
+// Record is a single MARC 21 record
+type Record struct {
+	Leader string  `json:"leader" xml:"leader"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a control field (tags 001-009, Value set) or a data field
+// (indicators and subfields set)
+type Field struct {
+	Tag       string     `json:"tag"`
+	Value     string     `json:"value,omitempty"`
+	Ind1      string     `json:"ind1,omitempty"`
+	Ind2      string     `json:"ind2,omitempty"`
+	Subfields []Subfield `json:"subfields,omitempty"`
+}
+
+// Subfield is a coded value inside a data field
+type Subfield struct {
+	Code  string `json:"code"`
+	Value string `json:"value"`
+}
+
+// IsControl reports whether the field is a control field
+func (f *Field) IsControl() bool {
+	return f.Tag < "010"
+}
+
+// Subfield returns the first value of the given subfield code
+func (f *Field) Subfield(code string) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// SetSubfield replaces the first subfield with the given code, or appends it
+func (f *Field) SetSubfield(code, value string) {
+	for i := range f.Subfields {
+		if f.Subfields[i].Code == code {
+			f.Subfields[i].Value = value
+			return
+		}
+	}
+	f.Subfields = append(f.Subfields, Subfield{Code: code, Value: value})
+}
+
+// RemoveSubfield removes every subfield with the given code
+func (f *Field) RemoveSubfield(code string) {
+	kept := f.Subfields[:0]
+	for _, sf := range f.Subfields {
+		if sf.Code != code {
+			kept = append(kept, sf)
+		}
+	}
+	f.Subfields = kept
+}
+
+// Field returns the first field with the given tag, or nil
+func (r *Record) Field(tag string) *Field {
+	for i := range r.Fields {
+		if r.Fields[i].Tag == tag {
+			return &r.Fields[i]
+		}
+	}
+	return nil
+}
+
+// SetField replaces the first field with the same tag, or inserts it in tag order
+func (r *Record) SetField(field Field) {
+	if existing := r.Field(field.Tag); existing != nil {
+		*existing = field
+		return
+	}
+
+	i := 0
+	for i < len(r.Fields) && r.Fields[i].Tag <= field.Tag {
+		i++
+	}
+	r.Fields = append(r.Fields, Field{})
+	copy(r.Fields[i+1:], r.Fields[i:])
+	r.Fields[i] = field
+}
+
+// RemoveField removes every field with the given tag
+func (r *Record) RemoveField(tag string) {
+	kept := r.Fields[:0]
+	for _, f := range r.Fields {
+		if f.Tag != tag {
+			kept = append(kept, f)
+		}
+	}
+	r.Fields = kept
+}
+
+// trimPunctuation removes the ISBD punctuation MARC puts at the end of values
+func trimPunctuation(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,."))
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// Dublin Core namespaces of an oai_dc record
+const (
+	DCNamespace    = "http://purl.org/dc/elements/1.1/"
+	OAIDCNamespace = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+)
+
+// DublinCore is a simple Dublin Core record in the oai_dc container
+type DublinCore struct {
+	XMLName     xml.Name `xml:"oai_dc:dc"`
+	XMLNSOAIDC  string   `xml:"xmlns:oai_dc,attr"`
+	XMLNSDC     string   `xml:"xmlns:dc,attr"`
+	Title       string   `xml:"dc:title"`
+	Creators    []string `xml:"dc:creator"`
+	Subjects    []string `xml:"dc:subject"`
+	Description string   `xml:"dc:description,omitempty"`
+	Publisher   string   `xml:"dc:publisher,omitempty"`
+	Date        string   `xml:"dc:date,omitempty"`
+	Type        string   `xml:"dc:type"`
+	Format      string   `xml:"dc:format,omitempty"`
+	Identifiers []string `xml:"dc:identifier"`
+	Relations   []string `xml:"dc:relation"`
+}
+
+// DublinCore renders the description as a Dublin Core record. The ISBN is
+// given as a urn:isbn identifier and the cover image as a relation.
+func (d Description) DublinCore() DublinCore {
+	record := DublinCore{
+		XMLNSOAIDC:  OAIDCNamespace,
+		XMLNSDC:     DCNamespace,
+		Title:       d.Title,
+		Creators:    d.Authors,
+		Description: d.Description,
+		Publisher:   d.Publisher,
+		Type:        "Text",
+		Identifiers: []string{d.URL},
+	}
+
+	if d.Category != "" {
+		record.Subjects = []string{d.Category}
+	}
+	if d.ReleaseYear != 0 {
+		record.Date = strconv.Itoa(d.ReleaseYear)
+	}
+	if d.TotalPage != 0 {
+		record.Format = strconv.Itoa(d.TotalPage) + " pages"
+	}
+	if d.ISBN != "" {
+		record.Identifiers = append(record.Identifiers, "urn:isbn:"+d.ISBN)
+	}
+	if d.ImageURL != "" {
+		record.Relations = []string{d.ImageURL}
+	}
+
+	return record
+}
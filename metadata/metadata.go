@@ -0,0 +1,53 @@
+// Package metadata maps books onto a single description that is rendered
+// as schema.org JSON-LD and Dublin Core XML, so a field added to the
+// description appears in every representation.
+package metadata
+
+import (
+	"book-management/models"
+	"book-management/money"
+	"strconv"
+	"time"
+)
+
+// Description is the format-independent metadata of a book. Price is a
+// decimal amount in Currency.
+type Description struct {
+	ID          int
+	URL         string
+	Title       string
+	Authors     []string
+	Publisher   string
+	ISBN        string
+	Description string
+	ImageURL    string
+	ReleaseYear int
+	TotalPage   int
+	Price       string
+	Currency    string
+	Category    string
+	CreatedAt   time.Time
+	ModifiedAt  time.Time
+}
+
+// Describe builds the description of a book. base is the URL books are
+// served under, e.g. "https://example.com/api/books".
+func Describe(book models.Book, categoryName, base string) Description {
+	return Description{
+		ID:          book.ID,
+		URL:         base + "/" + strconv.Itoa(book.ID),
+		Title:       book.Title,
+		Authors:     book.Authors,
+		Publisher:   book.Publisher,
+		ISBN:        book.ISBN,
+		Description: book.Description,
+		ImageURL:    book.ImageURL,
+		ReleaseYear: book.ReleaseYear,
+		TotalPage:   book.TotalPage,
+		Price:       money.Format(book.PriceMinor, book.Currency),
+		Currency:    book.Currency,
+		Category:    categoryName,
+		CreatedAt:   book.CreatedAt,
+		ModifiedAt:  book.ModifiedAt,
+	}
+}
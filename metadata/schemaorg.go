@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"strconv"
+	"time"
+)
+
+// SchemaBook is a schema.org Book in JSON-LD form
+type SchemaBook struct {
+	Context       string        `json:"@context"`
+	Type          string        `json:"@type"`
+	ID            string        `json:"@id"`
+	URL           string        `json:"url"`
+	Name          string        `json:"name"`
+	Author        []SchemaThing `json:"author,omitempty"`
+	Publisher     *SchemaThing  `json:"publisher,omitempty"`
+	ISBN          string        `json:"isbn,omitempty"`
+	Description   string        `json:"description,omitempty"`
+	Image         string        `json:"image,omitempty"`
+	DatePublished string        `json:"datePublished,omitempty"`
+	NumberOfPages int           `json:"numberOfPages,omitempty"`
+	Genre         string        `json:"genre,omitempty"`
+	DateCreated   string        `json:"dateCreated,omitempty"`
+	DateModified  string        `json:"dateModified,omitempty"`
+	Offers        SchemaOffer   `json:"offers"`
+}
+
+// SchemaThing is a named Person or Organization
+type SchemaThing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// SchemaOffer is the schema.org Offer built from the book price
+type SchemaOffer struct {
+	Type          string `json:"@type"`
+	Price         string `json:"price"`
+	PriceCurrency string `json:"priceCurrency"`
+	URL           string `json:"url"`
+}
+
+// SchemaOrg renders the description as a schema.org Book
+func (d Description) SchemaOrg() SchemaBook {
+	book := SchemaBook{
+		Context:       "https://schema.org",
+		Type:          "Book",
+		ID:            d.URL,
+		URL:           d.URL,
+		Name:          d.Title,
+		ISBN:          d.ISBN,
+		Description:   d.Description,
+		Image:         d.ImageURL,
+		NumberOfPages: d.TotalPage,
+		Genre:         d.Category,
+		DateCreated:   formatTime(d.CreatedAt),
+		DateModified:  formatTime(d.ModifiedAt),
+		Offers: SchemaOffer{
+			Type:          "Offer",
+			Price:         d.Price,
+			PriceCurrency: d.Currency,
+			URL:           d.URL,
+		},
+	}
+
+	for _, author := range d.Authors {
+		book.Author = append(book.Author, SchemaThing{Type: "Person", Name: author})
+	}
+	if d.Publisher != "" {
+		book.Publisher = &SchemaThing{Type: "Organization", Name: d.Publisher}
+	}
+	if d.ReleaseYear != 0 {
+		book.DatePublished = strconv.Itoa(d.ReleaseYear)
+	}
+
+	return book
+}
+
+// formatTime formats t as RFC 3339, or "" for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -22,13 +22,16 @@ func init() {
 
 type Claims struct {
 	Username string `json:"username"`
+	// Roles are only set for users whose staff password was verified
+	Roles []string `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(username string) (string, error) {
+// GenerateToken generates a JWT token for a user with the given roles
+func GenerateToken(username string, roles []string) (string, error) {
 	claims := Claims{
 		Username: username,
+		Roles:    roles,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -39,6 +42,40 @@ func GenerateToken(username string) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
+// CheckCredentials verifies a login and returns the user's roles. Staff
+// accounts are verified against STAFF_CREDENTIALS and regular users against
+// USER_CREDENTIALS, so a username always belongs to whoever holds its
+// password; reading lists and reviews rely on that.
+func CheckCredentials(username, password string) ([]string, bool) {
+	if username == "" || password == "" {
+		return nil, false
+	}
+
+	roles := UserRoles(username)
+	if len(roles) > 0 {
+		if !VerifyStaffPassword(username, password) {
+			return nil, false
+		}
+		return roles, true
+	}
+	return nil, VerifyUserPassword(username, password)
+}
+
+// parseToken validates a signed token and returns its claims
+func parseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
 // AuthMiddleware validates JWT token
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -64,12 +101,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// Parse and validate token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := parseToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid or expired token",
 			})
@@ -79,6 +112,36 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// Set username in context
 		c.Set("username", claims.Username)
+		c.Set("roles", claims.Roles)
 		c.Next()
 	}
 }
+
+// CatalogAuthMiddleware authenticates catalog clients such as e-readers,
+// which can send HTTP Basic credentials but not a Bearer token. A Bearer
+// token is accepted as well.
+func CatalogAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if username, password, ok := c.Request.BasicAuth(); ok {
+			if roles, valid := CheckCredentials(username, password); valid {
+				c.Set("username", username)
+				c.Set("roles", roles)
+				c.Next()
+				return
+			}
+		} else if tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+			if claims, err := parseToken(tokenString); err == nil {
+				c.Set("username", claims.Username)
+				c.Set("roles", claims.Roles)
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Basic realm="Book Catalog", charset="UTF-8"`)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid credentials",
+		})
+		c.Abort()
+	}
+}
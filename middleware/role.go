@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles that can be granted to users
+const (
+	RoleAdmin     = "admin"
+	RoleEditor    = "editor"
+	RoleLibrarian = "librarian"
+)
+
+// roles lists every role, for looking up a user's roles at login
+var roles = []string{RoleAdmin, RoleEditor, RoleLibrarian}
+
+// HasRole reports whether username has role. Members of a role are listed
+// comma separated in the <ROLE>_USERS environment variable (ADMIN_USERS,
+// EDITOR_USERS, LIBRARIAN_USERS); admins have every role. An unset variable grants the
+// role to nobody.
+func HasRole(username, role string) bool {
+	if username == "" {
+		return false
+	}
+
+	for _, r := range []string{role, RoleAdmin} {
+		for _, member := range strings.Split(os.Getenv(strings.ToUpper(r)+"_USERS"), ",") {
+			if strings.TrimSpace(member) == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UserRoles lists the roles granted to username
+func UserRoles(username string) []string {
+	var granted []string
+	for _, role := range roles {
+		if HasRole(username, role) {
+			granted = append(granted, role)
+		}
+	}
+	return granted
+}
+
+// VerifyStaffPassword checks the password of a user holding a role against
+// the bcrypt hashes in STAFF_CREDENTIALS, listed comma separated as
+// username:hash. Users without a listed hash cannot be verified.
+func VerifyStaffPassword(username, password string) bool {
+	return verifyPassword("STAFF_CREDENTIALS", username, password)
+}
+
+// VerifyUserPassword checks the password of a regular user against the
+// bcrypt hashes in USER_CREDENTIALS, in the same format as STAFF_CREDENTIALS
+func VerifyUserPassword(username, password string) bool {
+	return verifyPassword("USER_CREDENTIALS", username, password)
+}
+
+// verifyPassword checks password against the username:hash list in the
+// environment variable name
+func verifyPassword(name, username, password string) bool {
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		name, hash, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && name == username {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+		}
+	}
+	return false
+}
+
+// RequireRole only lets users with role through. The role must be both in
+// the token, which is only granted after VerifyStaffPassword, and still
+// granted by the environment. It must run after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, _ := c.Get("username")
+		usernameStr, _ := username.(string)
+		tokenRoles, _ := c.Get("roles")
+		tokenRolesList, _ := tokenRoles.([]string)
+
+		granted := false
+		for _, r := range tokenRolesList {
+			if r == role || r == RoleAdmin {
+				granted = true
+			}
+		}
+
+		if !granted || !HasRole(usernameStr, role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "This action requires the " + role + " role",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE categories ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE books ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +migrate Down
+ALTER TABLE books DROP COLUMN version;
+ALTER TABLE categories DROP COLUMN version;
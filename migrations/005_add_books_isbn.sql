@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE books ADD COLUMN isbn VARCHAR(13) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_books_isbn ON books(isbn) WHERE isbn <> '';
+CREATE INDEX idx_books_title_year ON books(lower(title), release_year);
+
+-- +migrate Down
+DROP INDEX idx_books_title_year;
+DROP INDEX idx_books_isbn;
+ALTER TABLE books DROP COLUMN isbn;
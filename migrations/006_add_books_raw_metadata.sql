@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE books ADD COLUMN raw_metadata JSONB;
+
+-- +migrate Down
+ALTER TABLE books DROP COLUMN raw_metadata;
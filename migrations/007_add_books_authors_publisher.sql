@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE books ADD COLUMN authors TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE books ADD COLUMN publisher TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE books DROP COLUMN publisher;
+ALTER TABLE books DROP COLUMN authors;
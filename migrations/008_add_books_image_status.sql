@@ -0,0 +1,17 @@
+-- +migrate Up
+ALTER TABLE books ADD COLUMN image_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN image_mirror_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN image_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN image_checked_at TIMESTAMP;
+
+-- Existing external covers are mirrored by the background worker
+UPDATE books SET image_status = 'pending' WHERE image_url LIKE 'http%';
+
+CREATE INDEX idx_books_image_status_pending ON books(id) WHERE image_status = 'pending';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_books_image_status_pending;
+ALTER TABLE books DROP COLUMN image_checked_at;
+ALTER TABLE books DROP COLUMN image_error;
+ALTER TABLE books DROP COLUMN image_mirror_url;
+ALTER TABLE books DROP COLUMN image_status;
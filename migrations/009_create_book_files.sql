@@ -0,0 +1,31 @@
+-- +migrate Up
+CREATE TABLE book_files (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL,
+    filename TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum_sha256 CHAR(64) NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100),
+    UNIQUE (book_id, checksum_sha256)
+);
+
+CREATE INDEX idx_book_files_book_id ON book_files(book_id);
+
+CREATE TABLE book_file_downloads (
+    id SERIAL PRIMARY KEY,
+    file_id INTEGER NOT NULL REFERENCES book_files(id) ON DELETE CASCADE,
+    username VARCHAR(100) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    downloaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_book_file_downloads_file_id ON book_file_downloads(file_id);
+CREATE INDEX idx_book_file_downloads_username ON book_file_downloads(username);
+
+-- +migrate Down
+DROP TABLE IF EXISTS book_file_downloads;
+DROP TABLE IF EXISTS book_files;
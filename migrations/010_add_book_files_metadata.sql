@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE book_files ADD COLUMN metadata JSONB;
+
+-- +migrate Down
+ALTER TABLE book_files DROP COLUMN metadata;
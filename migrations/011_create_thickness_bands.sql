@@ -0,0 +1,31 @@
+-- +migrate Up
+CREATE TABLE thickness_bands (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    min_pages INTEGER NOT NULL,
+    max_pages INTEGER,
+    translations JSONB NOT NULL DEFAULT '{}',
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100)
+);
+
+-- The rule that used to be hard-coded: more than 100 pages is "tebal"
+INSERT INTO thickness_bands (name, min_pages, max_pages, translations, modified_by) VALUES
+    ('tipis', 1, 100, '{"en": "thin"}', 'system'),
+    ('tebal', 101, NULL, '{"en": "thick"}', 'system');
+
+CREATE TABLE thickness_recalculations (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS thickness_recalculations;
+DROP TABLE IF EXISTS thickness_bands;
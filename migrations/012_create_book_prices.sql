@@ -0,0 +1,26 @@
+-- +migrate Up
+-- Value of one whole unit of each currency in IDR, the currency of books.price
+CREATE TABLE exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    rate NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100)
+);
+
+INSERT INTO exchange_rates (currency, rate, modified_by) VALUES ('IDR', 1, 'system');
+
+-- Prices of a book in other markets, in minor units of an ISO 4217 currency
+CREATE TABLE book_prices (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    market VARCHAR(10) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL CHECK (amount_minor >= 0),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100),
+    UNIQUE (book_id, market)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS book_prices;
+DROP TABLE IF EXISTS exchange_rates;
@@ -0,0 +1,44 @@
+-- +migrate Up
+-- Every price a book has had. market '' is the base price (books.price).
+CREATE TABLE price_history (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    market VARCHAR(10) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL,
+    effective_from TIMESTAMP NOT NULL,
+    effective_to TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+CREATE INDEX idx_price_history_book ON price_history(book_id, market, effective_from);
+CREATE UNIQUE INDEX idx_price_history_open ON price_history(book_id, market) WHERE effective_to IS NULL;
+
+-- The current prices open the history
+INSERT INTO price_history (book_id, market, currency, amount_minor, effective_from, created_by)
+SELECT id, '', 'IDR', price * 100, COALESCE(modified_at, CURRENT_TIMESTAMP), modified_by FROM books;
+
+INSERT INTO price_history (book_id, market, currency, amount_minor, effective_from, created_by)
+SELECT book_id, market, currency, amount_minor, COALESCE(modified_at, CURRENT_TIMESTAMP), modified_by FROM book_prices;
+
+-- Price changes waiting for their effective time
+CREATE TABLE scheduled_price_changes (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    market VARCHAR(10) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL CHECK (amount_minor >= 0),
+    effective_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- 'pending', 'applied', 'cancelled', 'failed'
+    error TEXT NOT NULL DEFAULT '',
+    applied_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+CREATE INDEX idx_scheduled_price_changes_due ON scheduled_price_changes(effective_at) WHERE status = 'pending';
+CREATE INDEX idx_scheduled_price_changes_book ON scheduled_price_changes(book_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS scheduled_price_changes;
+DROP TABLE IF EXISTS price_history;
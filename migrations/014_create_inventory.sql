@@ -0,0 +1,56 @@
+-- +migrate Up
+CREATE TABLE locations (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- The stock ledger. On-hand is the sum of quantity; rows are never updated.
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations(id) ON DELETE RESTRICT,
+    type VARCHAR(20) NOT NULL,  -- 'receipt', 'sale', 'transfer_out', 'transfer_in', 'adjustment'
+    quantity INTEGER NOT NULL CHECK (quantity <> 0),
+    transfer_id INTEGER REFERENCES stock_movements(id) ON DELETE CASCADE,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+CREATE INDEX idx_stock_movements_book_location ON stock_movements(book_id, location_id);
+CREATE INDEX idx_stock_movements_location ON stock_movements(location_id);
+
+CREATE TABLE book_stock_thresholds (
+    book_id INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    low_stock_threshold INTEGER NOT NULL CHECK (low_stock_threshold >= 0),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100)
+);
+
+-- Raised when the total on-hand of a book falls to its threshold, resolved
+-- when it rises above it again
+CREATE TABLE stock_alerts (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    on_hand INTEGER NOT NULL,
+    threshold INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',  -- 'open', 'resolved'
+    raised_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_stock_alerts_open ON stock_alerts(book_id) WHERE status = 'open';
+
+-- +migrate Down
+DROP TABLE IF EXISTS stock_alerts;
+DROP TABLE IF EXISTS book_stock_thresholds;
+DROP TABLE IF EXISTS stock_movements;
+DROP TABLE IF EXISTS locations;
@@ -0,0 +1,53 @@
+-- +migrate Up
+CREATE TABLE patrons (
+    id SERIAL PRIMARY KEY,
+    card_number VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    username VARCHAR(100) UNIQUE,        -- login of the patron, if any
+    loan_limit INTEGER CHECK (loan_limit >= 0),  -- NULL uses LOAN_LIMIT
+    status VARCHAR(20) NOT NULL DEFAULT 'active',  -- 'active', 'suspended'
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Physical copies of a book. A book with copies, and a copy with loans,
+-- cannot be deleted, so circulation history and fines are kept.
+CREATE TABLE copies (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
+    barcode VARCHAR(50) NOT NULL UNIQUE,
+    condition VARCHAR(20) NOT NULL DEFAULT 'good',  -- 'new', 'good', 'fair', 'poor', 'damaged'
+    shelf_location VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'available',  -- 'available', 'on_loan', 'lost', 'withdrawn'
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100),
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_by VARCHAR(100),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX idx_copies_book_id ON copies(book_id);
+
+CREATE TABLE loans (
+    id SERIAL PRIMARY KEY,
+    copy_id INTEGER NOT NULL REFERENCES copies(id) ON DELETE RESTRICT,
+    patron_id INTEGER NOT NULL REFERENCES patrons(id) ON DELETE RESTRICT,
+    checked_out_at TIMESTAMP NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    returned_at TIMESTAMP,
+    renewals INTEGER NOT NULL DEFAULT 0,
+    checked_out_by VARCHAR(100),
+    returned_by VARCHAR(100)
+);
+
+CREATE UNIQUE INDEX idx_loans_active_copy ON loans(copy_id) WHERE returned_at IS NULL;
+CREATE INDEX idx_loans_patron ON loans(patron_id, checked_out_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS loans;
+DROP TABLE IF EXISTS copies;
+DROP TABLE IF EXISTS patrons;
@@ -0,0 +1,35 @@
+-- +migrate Up
+-- Reservation queue per book. A pending hold waits in line; a ready hold
+-- has a copy set aside, with copies.status 'on_hold', until expires_at.
+CREATE TABLE holds (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    patron_id INTEGER NOT NULL REFERENCES patrons(id) ON DELETE RESTRICT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- 'pending', 'ready', 'fulfilled', 'cancelled', 'expired'
+    copy_id INTEGER REFERENCES copies(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ready_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    closed_at TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+CREATE UNIQUE INDEX idx_holds_active_patron ON holds(book_id, patron_id) WHERE status IN ('pending', 'ready');
+CREATE INDEX idx_holds_queue ON holds(book_id, created_at, id) WHERE status = 'pending';
+CREATE INDEX idx_holds_expiry ON holds(expires_at) WHERE status = 'ready';
+CREATE INDEX idx_holds_patron ON holds(patron_id, created_at);
+
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    patron_id INTEGER NOT NULL REFERENCES patrons(id) ON DELETE CASCADE,
+    hold_id INTEGER REFERENCES holds(id) ON DELETE SET NULL,
+    type VARCHAR(30) NOT NULL,  -- 'hold_ready', 'hold_expired'
+    message TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notifications_patron ON notifications(patron_id, created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS holds;
@@ -0,0 +1,31 @@
+-- +migrate Up
+-- fine is accrued on the loan by the daily overdue job and settled when
+-- the copy comes back; overdue_at is when the loan was first marked overdue
+ALTER TABLE loans ADD COLUMN fine INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loans ADD COLUMN overdue_at TIMESTAMP;
+
+CREATE INDEX idx_loans_due ON loans(due_at) WHERE returned_at IS NULL;
+
+-- Payments and waivers reduce a patron's balance, which is the sum of the
+-- fines on their loans minus these amounts
+CREATE TABLE fine_transactions (
+    id SERIAL PRIMARY KEY,
+    patron_id INTEGER NOT NULL REFERENCES patrons(id) ON DELETE RESTRICT,
+    loan_id INTEGER REFERENCES loans(id) ON DELETE SET NULL,
+    type VARCHAR(20) NOT NULL,  -- 'payment', 'waiver'
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100)
+);
+
+CREATE INDEX idx_fine_transactions_patron ON fine_transactions(patron_id, created_at);
+
+ALTER TABLE notifications ADD COLUMN loan_id INTEGER REFERENCES loans(id) ON DELETE SET NULL;
+
+-- +migrate Down
+ALTER TABLE notifications DROP COLUMN IF EXISTS loan_id;
+DROP TABLE IF EXISTS fine_transactions;
+DROP INDEX IF EXISTS idx_loans_due;
+ALTER TABLE loans DROP COLUMN IF EXISTS overdue_at;
+ALTER TABLE loans DROP COLUMN IF EXISTS fine;
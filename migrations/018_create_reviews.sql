@@ -0,0 +1,32 @@
+-- +migrate Up
+CREATE TABLE reviews (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    username VARCHAR(100) NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'visible',  -- 'visible', 'hidden'
+    hidden_reason VARCHAR(255) NOT NULL DEFAULT '',
+    moderated_by VARCHAR(100),
+    moderated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (book_id, username)
+);
+
+CREATE INDEX idx_reviews_book ON reviews(book_id, created_at) WHERE status = 'visible';
+CREATE INDEX idx_reviews_status ON reviews(status, created_at);
+
+-- Summary of the visible reviews, kept up to date by the review endpoints.
+-- It is not part of the book version.
+ALTER TABLE books ADD COLUMN rating_average NUMERIC(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN rating_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_books_rating ON books(rating_average DESC, rating_count DESC, id DESC);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_books_rating;
+ALTER TABLE books DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE books DROP COLUMN IF EXISTS rating_average;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,44 @@
+-- +migrate Up
+-- Personal reading lists. Every user gets the shelves 'to_read', 'reading'
+-- and 'finished'; other lists are 'custom'. A list with a share_token can
+-- be read by anyone who has the link.
+CREATE TABLE reading_lists (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'custom',  -- 'to_read', 'reading', 'finished', 'custom'
+    share_token VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (username, name)
+);
+
+CREATE UNIQUE INDEX idx_reading_lists_shelf ON reading_lists(username, kind) WHERE kind <> 'custom';
+
+-- position is 1-based and kept contiguous within a list
+CREATE TABLE reading_list_items (
+    list_id INTEGER NOT NULL REFERENCES reading_lists(id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (list_id, book_id)
+);
+
+CREATE INDEX idx_reading_list_items_book ON reading_list_items(book_id);
+
+CREATE TABLE reading_progress (
+    username VARCHAR(100) NOT NULL,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    current_page INTEGER NOT NULL CHECK (current_page >= 0),
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    PRIMARY KEY (username, book_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS reading_progress;
+DROP TABLE IF EXISTS reading_list_items;
+DROP TABLE IF EXISTS reading_lists;
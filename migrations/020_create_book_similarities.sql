@@ -0,0 +1,45 @@
+-- +migrate Up
+-- Cached suggestions for GET /api/books/:id/similar: the best scoring books
+-- for each book, with the signals that scored. Scores are symmetric, so a
+-- changed pair is updated in the lists of both books.
+CREATE TABLE book_similarities (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    similar_book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (book_id, similar_book_id)
+);
+
+CREATE INDEX idx_book_similarities_score ON book_similarities(book_id, score DESC, similar_book_id);
+CREATE INDEX idx_book_similarities_similar ON book_similarities(similar_book_id);
+
+-- When a book's suggestions were computed, with a signature of the fields
+-- they depend on (category, release year, authors, tags). A missing row, a
+-- different or NULL signature, or an old computed_at means they are stale.
+CREATE TABLE book_similarity_refreshes (
+    book_id INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    signature TEXT,
+    computed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_book_similarity_refreshes_computed ON book_similarity_refreshes(computed_at);
+
+-- Pairs whose reader or borrower counts changed, rescored in the background
+CREATE TABLE book_similarity_queue (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    similar_book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, similar_book_id)
+);
+
+-- Lookups for the candidate signals
+CREATE INDEX idx_books_category_year ON books(category_id, release_year);
+CREATE INDEX idx_books_authors ON books USING GIN (authors);
+CREATE INDEX idx_loans_copy ON loans(copy_id, patron_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_loans_copy;
+DROP INDEX IF EXISTS idx_books_authors;
+DROP INDEX IF EXISTS idx_books_category_year;
+DROP TABLE IF EXISTS book_similarity_queue;
+DROP TABLE IF EXISTS book_similarity_refreshes;
+DROP TABLE IF EXISTS book_similarities;
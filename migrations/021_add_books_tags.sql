@@ -0,0 +1,9 @@
+-- +migrate Up
+-- Free-form lower-case keywords, used for filtering and similar books
+ALTER TABLE books ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_books_tags ON books USING GIN (tags);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_books_tags;
+ALTER TABLE books DROP COLUMN IF EXISTS tags;
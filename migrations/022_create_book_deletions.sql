@@ -0,0 +1,13 @@
+-- +migrate Up
+-- Books that were deleted, so that ONIX delta feeds can send delete
+-- notifications for them
+CREATE TABLE book_deletions (
+    book_id INTEGER PRIMARY KEY,
+    isbn VARCHAR(13) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_book_deletions_deleted_at ON book_deletions(deleted_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS book_deletions;
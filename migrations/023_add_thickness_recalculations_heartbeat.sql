@@ -0,0 +1,16 @@
+-- +migrate Up
+-- Running jobs report progress in heartbeat_at, so a job whose instance
+-- stopped can be told apart from one that is still working. Only one job
+-- may run at a time.
+ALTER TABLE thickness_recalculations ADD COLUMN heartbeat_at TIMESTAMP;
+
+UPDATE thickness_recalculations
+SET status = 'failed', error = 'interrupted', finished_at = CURRENT_TIMESTAMP
+WHERE status = 'running';
+
+CREATE UNIQUE INDEX idx_thickness_recalculations_running
+    ON thickness_recalculations(status) WHERE status = 'running';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_thickness_recalculations_running;
+ALTER TABLE thickness_recalculations DROP COLUMN IF EXISTS heartbeat_at;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- books.price held whole rupiah. It becomes price_minor, in minor units of
+-- the book's own ISO 4217 currency, like book_prices.
+ALTER TABLE books RENAME COLUMN price TO price_minor;
+ALTER TABLE books ALTER COLUMN price_minor TYPE BIGINT USING ROUND(price_minor * 100);
+ALTER TABLE books ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE books ADD CONSTRAINT books_price_minor_check CHECK (price_minor >= 0);
+
+-- +migrate Down
+-- Prices in other currencies cannot be converted back and are kept as is
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_price_minor_check;
+ALTER TABLE books DROP COLUMN IF EXISTS currency;
+ALTER TABLE books ALTER COLUMN price_minor TYPE NUMERIC USING price_minor / 100;
+ALTER TABLE books RENAME COLUMN price_minor TO price;
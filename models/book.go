@@ -1,37 +1,128 @@
 package models
 
-import "time"
+import (
+	"book-management/money"
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type Book struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	ReleaseYear int       `json:"release_year" binding:"required,min=1980,max=2024"`
-	Price       int       `json:"price" binding:"required,min=0"`
-	TotalPage   int       `json:"total_page" binding:"required,min=1"`
-	Thickness   string    `json:"thickness"`
-	CategoryID  int       `json:"category_id" binding:"required"`
-	CreatedAt   time.Time `json:"created_at"`
-	CreatedBy   string    `json:"created_by"`
-	ModifiedAt  time.Time `json:"modified_at"`
-	ModifiedBy  string    `json:"modified_by"`
+	ID          int      `json:"id"`
+	Title       string   `json:"title" binding:"required"`
+	ISBN        string   `json:"isbn"`
+	Authors     []string `json:"authors"`
+	Publisher   string   `json:"publisher"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+	ImageURL    string   `json:"image_url"`
+	// Image* report the mirroring of an external image_url into our storage
+	ImageStatus    string     `json:"image_status,omitempty"`
+	ImageMirrorURL string     `json:"image_mirror_url,omitempty"`
+	ImageError     string     `json:"image_error,omitempty"`
+	ImageCheckedAt *time.Time `json:"image_checked_at,omitempty"`
+	ReleaseYear    int        `json:"release_year" binding:"required"`
+	PriceMinor     int64      `json:"price_minor" binding:"required,min=0"`
+	Currency       string     `json:"currency"`
+	TotalPage      int        `json:"total_page" binding:"required,min=1"`
+	Thickness      string     `json:"thickness"`
+	// ThicknessLabel is the thickness in the requested language
+	ThicknessLabel string    `json:"thickness_label"`
+	CategoryID     int       `json:"category_id" binding:"required"`
+	CreatedAt      time.Time `json:"created_at"`
+	CreatedBy      string    `json:"created_by"`
+	ModifiedAt     time.Time `json:"modified_at"`
+	ModifiedBy     string    `json:"modified_by"`
+	Version        int       `json:"version"`
+	// RawMetadata keeps source records (e.g. MARC) whose fields are not
+	// mapped. It is only loaded for exports that rebuild those records.
+	RawMetadata json.RawMessage `json:"-"`
+	// Prices are the prices in other markets
+	Prices       []BookPrice   `json:"prices,omitempty"`
+	DisplayPrice *DisplayPrice `json:"display_price,omitempty"`
+	// Availability counts the lending library's copies of the book
+	Availability *CopyAvailability `json:"availability,omitempty"`
+	// RatingAverage and RatingCount summarize the visible reviews
+	RatingAverage float64 `json:"rating_average"`
+	RatingCount   int     `json:"rating_count"`
 }
 
+// BookInput holds the editable fields of a book. PriceMinor is in minor
+// units of Currency, an ISO 4217 code that defaults to money.BaseCurrency.
 type BookInput struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
-	ImageURL    string `json:"image_url"`
-	ReleaseYear int    `json:"release_year" binding:"required,min=1980,max=2024"`
-	Price       int    `json:"price" binding:"required,min=0"`
-	TotalPage   int    `json:"total_page" binding:"required,min=1"`
-	CategoryID  int    `json:"category_id" binding:"required"`
+	Title       string   `json:"title" binding:"required"`
+	ISBN        string   `json:"isbn" binding:"omitempty,isbn"`
+	Authors     []string `json:"authors" binding:"omitempty,dive,required"`
+	Publisher   string   `json:"publisher"`
+	Tags        []string `json:"tags" binding:"omitempty,max=20,dive,required,max=50"`
+	Description string   `json:"description"`
+	ImageURL    string   `json:"image_url"`
+	ReleaseYear int      `json:"release_year" binding:"required"`
+	PriceMinor  int64    `json:"price_minor" binding:"required,min=0"`
+	Currency    string   `json:"currency"`
+	TotalPage   int      `json:"total_page" binding:"required,min=1"`
+	CategoryID  int      `json:"category_id" binding:"required"`
 }
 
-// CalculateThickness calculates book thickness based on total pages
+// Image mirroring states of a book's image_url. An empty status means there
+// is nothing to mirror (no image, or one we already host).
+const (
+	ImageStatusPending  = "pending"
+	ImageStatusMirrored = "mirrored"
+	ImageStatusFailed   = "failed"
+)
+
+// CalculateThickness calculates book thickness based on total pages using
+// the configured thickness bands
 func (b *BookInput) CalculateThickness() string {
-	if b.TotalPage > 100 {
-		return "tebal"
+	return ThicknessFor(b.TotalPage)
+}
+
+// Input returns the editable fields of a book
+func (b *Book) Input() BookInput {
+	return BookInput{
+		Title:       b.Title,
+		ISBN:        b.ISBN,
+		Authors:     b.Authors,
+		Publisher:   b.Publisher,
+		Tags:        b.Tags,
+		Description: b.Description,
+		ImageURL:    b.ImageURL,
+		ReleaseYear: b.ReleaseYear,
+		PriceMinor:  b.PriceMinor,
+		Currency:    b.Currency,
+		TotalPage:   b.TotalPage,
+		CategoryID:  b.CategoryID,
+	}
+}
+
+// NormalizeTags lower-cases and trims tags, dropping blanks and duplicates.
+// The result is never nil.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
 	}
-	return "tipis"
+	return normalized
+}
+
+// NormalizeCurrency upper-cases a currency code, defaulting to
+// money.BaseCurrency when it is empty
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return money.BaseCurrency
+	}
+	return currency
+}
+
+// NormalizeISBN strips hyphens and spaces from an ISBN so that
+// "978-602-03-1" and "978602031" compare equal
+func NormalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
 }
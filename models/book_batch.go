@@ -0,0 +1,43 @@
+package models
+
+// Batch operation types
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// Batch execution modes
+const (
+	BatchModeAtomic  = "atomic"
+	BatchModePartial = "partial"
+)
+
+// BookOperation is a single create, update or delete inside a batch
+type BookOperation struct {
+	Op string `json:"op"`
+	ID int    `json:"id"`
+	// Version is required for update and delete, like If-Match
+	Version *int       `json:"version"`
+	Data    *BookInput `json:"data"`
+}
+
+// BookBatchInput is the request body of the batch endpoint.
+// Mode defaults to atomic (all-or-nothing).
+type BookBatchInput struct {
+	Mode       string          `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Operations []BookOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BookOperationResult reports the outcome of one operation in a batch
+type BookOperationResult struct {
+	Index     int    `json:"index"`
+	Op        string `json:"op"`
+	ID        int    `json:"id,omitempty"`
+	Status    int    `json:"status"`
+	Version   int    `json:"version,omitempty"`
+	Thickness string `json:"thickness,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Errors lists the rejected fields when Error is a validation failure
+	Errors ValidationErrors `json:"errors,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Digital edition formats
+const (
+	FileFormatPDF  = "pdf"
+	FileFormatEPUB = "epub"
+)
+
+// BookFile is a digital edition (PDF/EPUB) attached to a book
+type BookFile struct {
+	ID             int       `json:"id"`
+	BookID         int       `json:"book_id"`
+	Format         string    `json:"format"`
+	Filename       string    `json:"filename"`
+	ContentType    string    `json:"content_type"`
+	Size           int64     `json:"size"`
+	ChecksumSHA256 string    `json:"checksum_sha256"`
+	CreatedAt      time.Time `json:"created_at"`
+	CreatedBy      string    `json:"created_by"`
+	// Metadata is what was extracted from the file (title, language, page
+	// count, ...)
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// BookProposal is a correction to a book field suggested by the metadata
+// of one of its files
+type BookProposal struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+	Note     string      `json:"note,omitempty"`
+}
+
+// AcceptProposalsInput lists the proposed fields an editor accepts
+type AcceptProposalsInput struct {
+	Fields []string `json:"fields" binding:"required,min=1,dive,oneof=title total_page authors publisher isbn"`
+}
+
+// FileDownload records one download of a book file
+type FileDownload struct {
+	ID           int       `json:"id"`
+	FileID       int       `json:"file_id"`
+	Username     string    `json:"username"`
+	IPAddress    string    `json:"ip_address"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
@@ -9,8 +9,16 @@ type Category struct {
 	CreatedBy  string    `json:"created_by"`
 	ModifiedAt time.Time `json:"modified_at"`
 	ModifiedBy string    `json:"modified_by"`
+	Version    int       `json:"version"`
 }
 
 type CategoryInput struct {
 	Name string `json:"name" binding:"required"`
 }
+
+// Input returns the editable fields of a category
+func (c *Category) Input() CategoryInput {
+	return CategoryInput{
+		Name: c.Name,
+	}
+}
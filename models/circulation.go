@@ -0,0 +1,150 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// Copy states
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyOnHold    = "on_hold" // set aside for a ready hold
+	CopyLost      = "lost"
+	CopyWithdrawn = "withdrawn"
+)
+
+// Patron states
+const (
+	PatronActive    = "active"
+	PatronSuspended = "suspended"
+)
+
+// Copy is a physical copy of a book
+type Copy struct {
+	ID            int       `json:"id"`
+	BookID        int       `json:"book_id"`
+	Barcode       string    `json:"barcode"`
+	Condition     string    `json:"condition"`
+	ShelfLocation string    `json:"shelf_location"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	CreatedBy     string    `json:"created_by"`
+	ModifiedAt    time.Time `json:"modified_at"`
+	ModifiedBy    string    `json:"modified_by"`
+	Version       int       `json:"version"`
+}
+
+// CopyInput creates or updates a copy. Status can only be set to the
+// states that are not managed by circulation.
+type CopyInput struct {
+	Barcode       string `json:"barcode" binding:"required,max=50"`
+	Condition     string `json:"condition" binding:"omitempty,oneof=new good fair poor damaged"`
+	ShelfLocation string `json:"shelf_location" binding:"max=100"`
+	Status        string `json:"status" binding:"omitempty,oneof=available lost withdrawn"`
+}
+
+// CopyAvailability summarises the copies of a book
+type CopyAvailability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+}
+
+// Patron is a borrower of the lending library
+type Patron struct {
+	ID         int       `json:"id"`
+	CardNumber string    `json:"card_number"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Username   string    `json:"username,omitempty"`
+	LoanLimit  *int      `json:"loan_limit"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by"`
+	Version    int       `json:"version"`
+}
+
+type PatronInput struct {
+	CardNumber string `json:"card_number" binding:"required,max=50"`
+	Name       string `json:"name" binding:"required,max=255"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	Username   string `json:"username" binding:"max=100"`
+	LoanLimit  *int   `json:"loan_limit" binding:"omitempty,min=0"`
+	Status     string `json:"status" binding:"omitempty,oneof=active suspended"`
+}
+
+// Loan is the lending of a copy to a patron. ReturnedAt is nil while the
+// copy is out.
+type Loan struct {
+	ID           int        `json:"id"`
+	CopyID       int        `json:"copy_id"`
+	BookID       int        `json:"book_id"`
+	Barcode      string     `json:"barcode"`
+	PatronID     int        `json:"patron_id"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at"`
+	Renewals     int        `json:"renewals"`
+	CheckedOutBy string     `json:"checked_out_by"`
+	ReturnedBy   string     `json:"returned_by,omitempty"`
+	// Fine is accrued daily while overdue and final once returned
+	Fine      int        `json:"fine"`
+	OverdueAt *time.Time `json:"overdue_at,omitempty"`
+}
+
+// CheckoutInput lends the copy with Barcode to a patron
+type CheckoutInput struct {
+	Barcode  string `json:"barcode" binding:"required"`
+	PatronID int    `json:"patron_id" binding:"required"`
+}
+
+// ReturnInput returns the copy with Barcode
+type ReturnInput struct {
+	Barcode string `json:"barcode" binding:"required"`
+}
+
+// CirculationRules are the configurable lending limits
+type CirculationRules struct {
+	LoanPeriodDays int `json:"loan_period_days"`
+	// LoanLimit applies to patrons without their own limit
+	LoanLimit   int `json:"loan_limit"`
+	MaxRenewals int `json:"max_renewals"`
+	// HoldPickupDays is how long a copy stays set aside for a hold
+	HoldPickupDays int `json:"hold_pickup_days"`
+}
+
+// DefaultCirculationRules are used until other rules are configured
+func DefaultCirculationRules() CirculationRules {
+	return CirculationRules{LoanPeriodDays: 14, LoanLimit: 5, MaxRenewals: 2, HoldPickupDays: 3}
+}
+
+// LoanPeriod is how long a checkout or renewal lasts
+func (r CirculationRules) LoanPeriod() time.Duration {
+	return time.Duration(r.LoanPeriodDays) * 24 * time.Hour
+}
+
+// HoldPickup is how long a ready hold waits before it expires
+func (r CirculationRules) HoldPickup() time.Duration {
+	return time.Duration(r.HoldPickupDays) * 24 * time.Hour
+}
+
+var (
+	circulationRulesMu sync.RWMutex
+	circulationRules   = DefaultCirculationRules()
+)
+
+// SetCirculationRules replaces the lending rules
+func SetCirculationRules(rules CirculationRules) {
+	circulationRulesMu.Lock()
+	defer circulationRulesMu.Unlock()
+	circulationRules = rules
+}
+
+// CurrentCirculationRules returns the lending rules in use
+func CurrentCirculationRules() CirculationRules {
+	circulationRulesMu.RLock()
+	defer circulationRulesMu.RUnlock()
+	return circulationRules
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// Fine transaction types
+const (
+	FinePayment = "payment"
+	FineWaiver  = "waiver"
+)
+
+// FineRules are the configurable overdue fine rules. Amounts are in the
+// base currency, like book prices.
+type FineRules struct {
+	DailyRate int `json:"daily_rate"`
+	// GraceDays late are not charged
+	GraceDays int `json:"grace_days"`
+	// MaxFine caps the fine of a single loan, 0 when there is no cap
+	MaxFine           int         `json:"max_fine"`
+	CategoryDailyRate map[int]int `json:"category_daily_rate"`
+	CategoryMaxFine   map[int]int `json:"category_max_fine"`
+	// BlockThreshold is the balance above which checkouts are refused
+	BlockThreshold int `json:"block_threshold"`
+}
+
+// DefaultFineRules are used until other rules are configured
+func DefaultFineRules() FineRules {
+	return FineRules{
+		DailyRate:         1000,
+		BlockThreshold:    50000,
+		CategoryDailyRate: map[int]int{},
+		CategoryMaxFine:   map[int]int{},
+	}
+}
+
+// Fine is the fine for a loan of a book in categoryID that was due at due
+// and is returned, or still out, at until. Every started day late counts.
+func (r FineRules) Fine(categoryID int, due, until time.Time) int {
+	if !until.After(due) {
+		return 0
+	}
+
+	day := 24 * time.Hour
+	daysLate := int((until.Sub(due) + day - 1) / day)
+	charged := daysLate - r.GraceDays
+	if charged <= 0 {
+		return 0
+	}
+
+	rate := r.DailyRate
+	if categoryRate, ok := r.CategoryDailyRate[categoryID]; ok {
+		rate = categoryRate
+	}
+	limit := r.MaxFine
+	if categoryLimit, ok := r.CategoryMaxFine[categoryID]; ok {
+		limit = categoryLimit
+	}
+
+	fine := charged * rate
+	if limit > 0 && fine > limit {
+		fine = limit
+	}
+	return fine
+}
+
+// Blocks reports whether a patron with balance may no longer check out
+func (r FineRules) Blocks(balance int) bool {
+	return balance > r.BlockThreshold
+}
+
+var (
+	fineRulesMu sync.RWMutex
+	fineRules   = DefaultFineRules()
+)
+
+// SetFineRules replaces the fine rules
+func SetFineRules(rules FineRules) {
+	fineRulesMu.Lock()
+	defer fineRulesMu.Unlock()
+	fineRules = rules
+}
+
+// CurrentFineRules returns the fine rules in use
+func CurrentFineRules() FineRules {
+	fineRulesMu.RLock()
+	defer fineRulesMu.RUnlock()
+	return fineRules
+}
+
+// FineTransaction is a payment or waiver against a patron's balance
+type FineTransaction struct {
+	ID        int       `json:"id"`
+	PatronID  int       `json:"patron_id"`
+	LoanID    *int      `json:"loan_id,omitempty"`
+	Type      string    `json:"type"`
+	Amount    int       `json:"amount"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// FineTransactionInput is the request body for a payment or waiver
+type FineTransactionInput struct {
+	Amount int    `json:"amount" binding:"required,min=1"`
+	LoanID *int   `json:"loan_id"`
+	Note   string `json:"note" binding:"max=255"`
+}
+
+// FineAccount is the fine balance of a patron with the loans that were
+// fined and the payments and waivers made
+type FineAccount struct {
+	PatronID     int               `json:"patron_id"`
+	Charged      int               `json:"charged"`
+	Paid         int               `json:"paid"`
+	Waived       int               `json:"waived"`
+	Balance      int               `json:"balance"`
+	Blocked      bool              `json:"blocked"`
+	Loans        []Loan            `json:"loans"`
+	Transactions []FineTransaction `json:"transactions"`
+}
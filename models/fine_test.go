@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFineRulesFine(t *testing.T) {
+	rules := FineRules{
+		DailyRate:         1000,
+		GraceDays:         2,
+		MaxFine:           10000,
+		CategoryDailyRate: map[int]int{7: 500, 8: 3000},
+		CategoryMaxFine:   map[int]int{7: 2000, 9: 0},
+	}
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name       string
+		categoryID int
+		until      time.Time
+		want       int
+	}{
+		{"returned early", 1, due.Add(-day), 0},
+		{"returned on time", 1, due, 0},
+		{"within grace", 1, due.Add(2 * day), 0},
+		{"started day counts", 1, due.Add(2*day + time.Minute), 1000},
+		{"after grace", 1, due.Add(5 * day), 3000},
+		{"capped", 1, due.Add(30 * day), 10000},
+		{"category rate", 7, due.Add(4 * day), 1000},
+		{"category cap", 7, due.Add(30 * day), 2000},
+		{"category rate under default cap", 8, due.Add(5 * day), 9000},
+		{"category rate over default cap", 8, due.Add(10 * day), 10000},
+		{"category without cap", 9, due.Add(30 * day), 28000},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Fine(tt.categoryID, due, tt.until); got != tt.want {
+			t.Errorf("%s: Fine() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFineRulesFineWithoutGrace(t *testing.T) {
+	rules := DefaultFineRules()
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		until time.Time
+		want  int
+	}{
+		{due, 0},
+		{due.Add(time.Second), 1000},
+		{due.Add(24 * time.Hour), 1000},
+		{due.Add(100 * 24 * time.Hour), 100000},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Fine(1, due, tt.until); got != tt.want {
+			t.Errorf("Fine(%s late) = %d, want %d", tt.until.Sub(due), got, tt.want)
+		}
+	}
+}
+
+func TestFineRulesBlocks(t *testing.T) {
+	rules := FineRules{BlockThreshold: 50000}
+
+	tests := []struct {
+		balance int
+		want    bool
+	}{
+		{0, false},
+		{-1000, false},
+		{50000, false},
+		{50001, true},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Blocks(tt.balance); got != tt.want {
+			t.Errorf("Blocks(%d) = %v, want %v", tt.balance, got, tt.want)
+		}
+	}
+}
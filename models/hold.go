@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Hold statuses. A hold is active while pending or ready.
+const (
+	HoldPending   = "pending"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// Notification types
+const (
+	NotificationHoldReady   = "hold_ready"
+	NotificationHoldExpired = "hold_expired"
+	NotificationLoanOverdue = "loan_overdue"
+)
+
+// Hold is a patron's place in the reservation queue of a book
+type Hold struct {
+	ID       int    `json:"id"`
+	BookID   int    `json:"book_id"`
+	PatronID int    `json:"patron_id"`
+	Status   string `json:"status"`
+	// Position is the place in the queue, 1 being next, while pending
+	Position *int `json:"position,omitempty"`
+	// CopyID and Barcode identify the copy set aside once ready
+	CopyID    *int       `json:"copy_id,omitempty"`
+	Barcode   string     `json:"barcode,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	CreatedBy string     `json:"created_by"`
+}
+
+// HoldInput is the request body for placing a hold
+type HoldInput struct {
+	BookID   int `json:"book_id" binding:"required"`
+	PatronID int `json:"patron_id" binding:"required"`
+}
+
+// Notification is a message sent to a patron
+type Notification struct {
+	ID        int       `json:"id"`
+	PatronID  int       `json:"patron_id"`
+	HoldID    *int      `json:"hold_id,omitempty"`
+	LoanID    *int      `json:"loan_id,omitempty"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
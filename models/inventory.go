@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// Stock movement types. Transfers are recorded as a transfer_out at the
+// source and a transfer_in at the destination.
+const (
+	StockReceipt     = "receipt"
+	StockSale        = "sale"
+	StockTransfer    = "transfer"
+	StockTransferOut = "transfer_out"
+	StockTransferIn  = "transfer_in"
+	StockAdjustment  = "adjustment"
+)
+
+// Stock alert states
+const (
+	StockAlertOpen     = "open"
+	StockAlertResolved = "resolved"
+)
+
+// Location is a warehouse or store holding stock
+type Location struct {
+	ID         int       `json:"id"`
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Address    string    `json:"address"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by"`
+	Version    int       `json:"version"`
+}
+
+type LocationInput struct {
+	Code    string `json:"code" binding:"required,max=20"`
+	Name    string `json:"name" binding:"required,max=255"`
+	Address string `json:"address"`
+}
+
+// StockMovement is one entry of the stock ledger. Quantity is signed:
+// positive adds stock to the location, negative removes it.
+type StockMovement struct {
+	ID         int       `json:"id"`
+	BookID     int       `json:"book_id"`
+	LocationID int       `json:"location_id"`
+	Type       string    `json:"type"`
+	Quantity   int       `json:"quantity"`
+	TransferID *int      `json:"transfer_id,omitempty"`
+	Reference  string    `json:"reference"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by"`
+}
+
+// StockMovementInput records a movement. Receipts, sales and transfers take
+// a positive quantity; adjustments are signed. Transfers also need
+// ToLocationID.
+type StockMovementInput struct {
+	BookID       int    `json:"book_id" binding:"required"`
+	LocationID   int    `json:"location_id" binding:"required"`
+	Type         string `json:"type" binding:"required,oneof=receipt sale transfer adjustment"`
+	Quantity     int    `json:"quantity" binding:"required"`
+	ToLocationID int    `json:"to_location_id"`
+	Reference    string `json:"reference" binding:"max=100"`
+	Note         string `json:"note"`
+}
+
+// LocationStock is the on-hand quantity of a book at one location
+type LocationStock struct {
+	LocationID   int    `json:"location_id"`
+	LocationCode string `json:"location_code"`
+	BookID       int    `json:"book_id"`
+	OnHand       int    `json:"on_hand"`
+}
+
+// BookStock is the stock of a book across all locations
+type BookStock struct {
+	BookID            int             `json:"book_id"`
+	OnHand            int             `json:"on_hand"`
+	LowStockThreshold *int            `json:"low_stock_threshold"`
+	LowStock          bool            `json:"low_stock"`
+	Locations         []LocationStock `json:"locations"`
+}
+
+type StockThresholdInput struct {
+	LowStockThreshold *int `json:"low_stock_threshold" binding:"required,min=0"`
+}
+
+// StockAlert reports a book whose stock fell to its threshold
+type StockAlert struct {
+	ID         int        `json:"id"`
+	BookID     int        `json:"book_id"`
+	OnHand     int        `json:"on_hand"`
+	Threshold  int        `json:"threshold"`
+	Status     string     `json:"status"`
+	RaisedAt   time.Time  `json:"raised_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
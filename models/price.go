@@ -0,0 +1,83 @@
+package models
+
+import (
+	"book-management/money"
+	"time"
+)
+
+// BookPrice is the price of a book in one market
+type BookPrice struct {
+	Market string `json:"market"`
+	money.Price
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by"`
+}
+
+// BookPriceInput sets the price of a book in a market. Amount is a decimal
+// in the currency, e.g. "19.99"; it is sent as a string so it stays exact.
+type BookPriceInput struct {
+	Currency string `json:"currency" binding:"required,len=3"`
+	Amount   string `json:"amount" binding:"required"`
+}
+
+// DisplayPrice is a book's price in a requested currency. Converted is
+// false when the book has its own price in that currency.
+type DisplayPrice struct {
+	money.Price
+	Market    string `json:"market,omitempty"`
+	Converted bool   `json:"converted"`
+}
+
+// ExchangeRate is the value of one unit of Currency in money.BaseCurrency
+type ExchangeRate struct {
+	Currency   string    `json:"currency" binding:"required,len=3"`
+	Rate       string    `json:"rate" binding:"required"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by"`
+}
+
+// ExchangeRatesInput updates the listed rates; other rates are kept
+type ExchangeRatesInput struct {
+	Rates []ExchangeRate `json:"rates" binding:"required,min=1,dive"`
+}
+
+// Scheduled price change states
+const (
+	PriceChangePending   = "pending"
+	PriceChangeApplied   = "applied"
+	PriceChangeCancelled = "cancelled"
+	PriceChangeFailed    = "failed"
+)
+
+// PriceHistoryEntry is a price a book had from EffectiveFrom until
+// EffectiveTo (nil while it is current). Market is empty for the base price.
+type PriceHistoryEntry struct {
+	Market string `json:"market"`
+	money.Price
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	CreatedBy     string     `json:"created_by"`
+}
+
+// ScheduledPriceChange is a price that takes effect at EffectiveAt
+type ScheduledPriceChange struct {
+	ID     int    `json:"id"`
+	BookID int    `json:"book_id"`
+	Market string `json:"market"`
+	money.Price
+	EffectiveAt time.Time  `json:"effective_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CreatedBy   string     `json:"created_by"`
+}
+
+// ScheduledPriceInput schedules a price change. An empty Market changes
+// the base price, which keeps the book's currency when Currency is empty.
+type ScheduledPriceInput struct {
+	Market      string    `json:"market"`
+	Currency    string    `json:"currency"`
+	Amount      string    `json:"amount" binding:"required"`
+	EffectiveAt time.Time `json:"effective_at" binding:"required"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Reading list kinds. Every user has one list of each shelf kind.
+const (
+	ListToRead   = "to_read"
+	ListReading  = "reading"
+	ListFinished = "finished"
+	ListCustom   = "custom"
+)
+
+// Shelves are the built-in lists created for every user, by kind
+var Shelves = []struct{ Kind, Name string }{
+	{ListToRead, "To read"},
+	{ListReading, "Reading"},
+	{ListFinished, "Finished"},
+}
+
+// ReadingList is a user's list of books. Items are only loaded for a
+// single list.
+type ReadingList struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	// ShareURL is the public link to the list while it is shared
+	ShareURL   string            `json:"share_url,omitempty"`
+	ItemCount  int               `json:"item_count"`
+	Items      []ReadingListItem `json:"items,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ModifiedAt time.Time         `json:"modified_at"`
+	Version    int               `json:"version"`
+}
+
+// ReadingListInput is the request body for creating or renaming a list
+type ReadingListInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// ReadingListItem is a book on a reading list
+type ReadingListItem struct {
+	BookID   int              `json:"book_id"`
+	Title    string           `json:"title"`
+	Position int              `json:"position"`
+	Note     string           `json:"note"`
+	AddedAt  time.Time        `json:"added_at"`
+	Progress *ReadingProgress `json:"progress,omitempty"`
+}
+
+// ReadingListItemInput is the request body for adding a book to a list.
+// Position 0 appends it.
+type ReadingListItemInput struct {
+	BookID   int    `json:"book_id" binding:"required"`
+	Note     string `json:"note" binding:"max=1000"`
+	Position int    `json:"position" binding:"min=0"`
+}
+
+// ReadingListItemUpdate is the request body for changing an item's note
+// and moving it. Position 0 keeps it in place.
+type ReadingListItemUpdate struct {
+	Note     string `json:"note" binding:"max=1000"`
+	Position int    `json:"position" binding:"min=0"`
+}
+
+// ReadingProgress is how far a user has read a book
+type ReadingProgress struct {
+	BookID      int        `json:"book_id"`
+	Title       string     `json:"title,omitempty"`
+	CurrentPage int        `json:"current_page"`
+	TotalPage   int        `json:"total_page"`
+	Percentage  float64    `json:"percentage"`
+	StartedAt   time.Time  `json:"started_at"`
+	ModifiedAt  time.Time  `json:"modified_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ReadingProgressInput is the request body for updating reading progress
+type ReadingProgressInput struct {
+	CurrentPage *int `json:"current_page" binding:"required,min=0"`
+}
+
+// ProgressPercentage is the share of totalPage read at currentPage, to one
+// decimal place
+func ProgressPercentage(currentPage, totalPage int) float64 {
+	if totalPage <= 0 {
+		return 0
+	}
+	percentage := math.Round(float64(currentPage)*1000/float64(totalPage)) / 10
+	return math.Min(percentage, 100)
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Review statuses
+const (
+	ReviewVisible = "visible"
+	ReviewHidden  = "hidden"
+)
+
+// Review is a user's rating and review of a book. A user reviews a book
+// once.
+type Review struct {
+	ID       int    `json:"id"`
+	BookID   int    `json:"book_id"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+	Body     string `json:"body"`
+	Status   string `json:"status"`
+	// HiddenReason, ModeratedBy and ModeratedAt are set by editors
+	HiddenReason string     `json:"hidden_reason,omitempty"`
+	ModeratedBy  string     `json:"moderated_by,omitempty"`
+	ModeratedAt  *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModifiedAt   time.Time  `json:"modified_at"`
+	Version      int        `json:"version"`
+}
+
+// ReviewInput is the request body for writing a review
+type ReviewInput struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+// ModerationInput is the request body for hiding a review
+type ModerationInput struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
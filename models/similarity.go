@@ -0,0 +1,37 @@
+package models
+
+// Signals that score a book as similar to another
+const (
+	SimilarCategory    = "category"
+	SimilarAuthors     = "authors"
+	SimilarTags        = "tags"
+	SimilarReleaseYear = "release_year"
+	SimilarReaders     = "readers"
+	SimilarBorrowers   = "borrowers"
+)
+
+// Weights of the similarity signals. A shared category and each shared
+// author or tag score a fixed amount. Release years score the window minus the
+// years apart, so only books less than SimilarYearWindow years apart score.
+// Readers (users with both books in their reading lists) and borrowers
+// (patrons who borrowed both) score per person, up to SimilarPeopleCap.
+const (
+	SimilarCategoryWeight = 5
+	SimilarAuthorWeight   = 4
+	SimilarTagWeight      = 3
+	SimilarYearWindow     = 3
+	SimilarReaderWeight   = 1
+	SimilarBorrowerWeight = 1
+	SimilarPeopleCap      = 10
+)
+
+// SimilarBooksKept is how many suggestions are cached per book
+const SimilarBooksKept = 20
+
+// SimilarBook is a book suggested from another, with its score and the
+// signals that scored
+type SimilarBook struct {
+	Book    Book     `json:"book"`
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
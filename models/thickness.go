@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ThicknessBand names the books whose page count is between MinPages and
+// MaxPages (inclusive; nil means no upper bound)
+type ThicknessBand struct {
+	ID           int               `json:"id"`
+	Name         string            `json:"name" binding:"required,max=50"`
+	MinPages     int               `json:"min_pages" binding:"min=1"`
+	MaxPages     *int              `json:"max_pages"`
+	Translations map[string]string `json:"translations"`
+	ModifiedAt   time.Time         `json:"modified_at"`
+	ModifiedBy   string            `json:"modified_by"`
+}
+
+// ThicknessBandsInput replaces the whole set of bands
+type ThicknessBandsInput struct {
+	Bands []ThicknessBand `json:"bands" binding:"required,min=1,dive"`
+}
+
+// Validate checks that the bands have unique names and cover every page
+// count from 1 upwards without gaps or overlaps. The bands are sorted by
+// MinPages in place.
+func (in *ThicknessBandsInput) Validate() error {
+	sort.Slice(in.Bands, func(i, j int) bool {
+		return in.Bands[i].MinPages < in.Bands[j].MinPages
+	})
+
+	names := make(map[string]bool)
+	next := 1
+	for i, band := range in.Bands {
+		if names[band.Name] {
+			return fmt.Errorf("band name %q is used twice", band.Name)
+		}
+		names[band.Name] = true
+
+		if band.MinPages != next {
+			return fmt.Errorf("band %q must start at %d pages", band.Name, next)
+		}
+		if band.MaxPages == nil {
+			if i != len(in.Bands)-1 {
+				return fmt.Errorf("only the last band may have no max_pages")
+			}
+			return nil
+		}
+		if *band.MaxPages < band.MinPages {
+			return fmt.Errorf("band %q has max_pages below min_pages", band.Name)
+		}
+		next = *band.MaxPages + 1
+	}
+	return errors.New("the last band must have no max_pages")
+}
+
+// Recalculation states
+const (
+	RecalculationRunning = "running"
+	RecalculationDone    = "done"
+	RecalculationFailed  = "failed"
+)
+
+// ThicknessRecalculation is the progress of a thickness recalculation job
+type ThicknessRecalculation struct {
+	ID         int        `json:"id"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Updated    int        `json:"updated"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedBy  string     `json:"created_by"`
+}
+
+// thicknessBands is the active rule set used by CalculateThickness
+var thicknessBands struct {
+	sync.RWMutex
+	bands []ThicknessBand
+}
+
+// SetThicknessBands replaces the rules used by CalculateThickness
+func SetThicknessBands(bands []ThicknessBand) {
+	thicknessBands.Lock()
+	defer thicknessBands.Unlock()
+	thicknessBands.bands = bands
+}
+
+// ThicknessLabel returns the name of the band called name in lang. Bands
+// without a translation for lang, and names that are not a current band,
+// are returned as is.
+func ThicknessLabel(name, lang string) string {
+	thicknessBands.RLock()
+	defer thicknessBands.RUnlock()
+
+	for _, band := range thicknessBands.bands {
+		if band.Name == name {
+			if label := band.Translations[lang]; label != "" {
+				return label
+			}
+			break
+		}
+	}
+	return name
+}
+
+// ThicknessFor returns the name of the band totalPage falls into. Until
+// bands are loaded the original rule applies: more than 100 pages is
+// "tebal", otherwise "tipis".
+func ThicknessFor(totalPage int) string {
+	thicknessBands.RLock()
+	defer thicknessBands.RUnlock()
+
+	if len(thicknessBands.bands) == 0 {
+		if totalPage > 100 {
+			return "tebal"
+		}
+		return "tipis"
+	}
+
+	for _, band := range thicknessBands.bands {
+		if totalPage >= band.MinPages && (band.MaxPages == nil || totalPage <= *band.MaxPages) {
+			return band.Name
+		}
+	}
+	// Below the first band (the bands always start at 1 page)
+	return thicknessBands.bands[0].Name
+}
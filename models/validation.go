@@ -0,0 +1,145 @@
+package models
+
+import (
+	"book-management/money"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError reports why one field of a request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every rejected field of a request
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Field + ": " + fieldError.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BookRules are the configurable limits books are validated against
+type BookRules struct {
+	// MinReleaseYear is the earliest accepted release_year
+	MinReleaseYear int `json:"min_release_year"`
+	// ReleaseYearLead is how many years past the current year release_year
+	// may be, for announced books
+	ReleaseYearLead int `json:"release_year_lead"`
+	TitleMinLength  int `json:"title_min_length"`
+	TitleMaxLength  int `json:"title_max_length"`
+	// MaxPrice applies to categories without their own ceiling; 0 means no
+	// ceiling. Ceilings are whole amounts in money.BaseCurrency.
+	MaxPrice         int         `json:"max_price"`
+	CategoryMaxPrice map[int]int `json:"category_max_price"`
+}
+
+// DefaultBookRules are used until other rules are configured
+func DefaultBookRules() BookRules {
+	return BookRules{
+		MinReleaseYear:   1,
+		ReleaseYearLead:  1,
+		TitleMinLength:   1,
+		TitleMaxLength:   255,
+		CategoryMaxPrice: map[int]int{},
+	}
+}
+
+// MaxReleaseYear is the latest release_year accepted at now
+func (r BookRules) MaxReleaseYear(now time.Time) int {
+	return now.Year() + r.ReleaseYearLead
+}
+
+// PriceCeiling is the highest price accepted in a category, 0 when there is
+// none
+func (r BookRules) PriceCeiling(categoryID int) int {
+	if ceiling, ok := r.CategoryMaxPrice[categoryID]; ok {
+		return ceiling
+	}
+	return r.MaxPrice
+}
+
+var (
+	bookRulesMu sync.RWMutex
+	bookRules   = DefaultBookRules()
+)
+
+// SetBookRules replaces the rules used by BookInput.Validate
+func SetBookRules(rules BookRules) {
+	bookRulesMu.Lock()
+	defer bookRulesMu.Unlock()
+	bookRules = rules
+}
+
+// CurrentBookRules returns the rules in use
+func CurrentBookRules() BookRules {
+	bookRulesMu.RLock()
+	defer bookRulesMu.RUnlock()
+	return bookRules
+}
+
+// Validate checks the rules that depend on configuration or the current
+// date. The static rules are still expressed as binding tags.
+func (b BookInput) Validate() error {
+	rules := CurrentBookRules()
+	var errs ValidationErrors
+
+	titleLength := utf8.RuneCountInString(strings.TrimSpace(b.Title))
+	if b.Title != "" && titleLength < rules.TitleMinLength {
+		errs = append(errs, FieldError{
+			Field: "title", Rule: "min",
+			Message: fmt.Sprintf("must be at least %d characters long", rules.TitleMinLength),
+		})
+	}
+	if rules.TitleMaxLength > 0 && titleLength > rules.TitleMaxLength {
+		errs = append(errs, FieldError{
+			Field: "title", Rule: "max",
+			Message: fmt.Sprintf("must be at most %d characters long", rules.TitleMaxLength),
+		})
+	}
+
+	if b.ReleaseYear != 0 && b.ReleaseYear < rules.MinReleaseYear {
+		errs = append(errs, FieldError{
+			Field: "release_year", Rule: "min",
+			Message: fmt.Sprintf("must be %d or later", rules.MinReleaseYear),
+		})
+	}
+	if maxYear := rules.MaxReleaseYear(time.Now()); b.ReleaseYear > maxYear {
+		errs = append(errs, FieldError{
+			Field: "release_year", Rule: "max",
+			Message: fmt.Sprintf("must be %d or earlier", maxYear),
+		})
+	}
+
+	// Price ceilings are whole amounts in money.BaseCurrency, so prices in
+	// other currencies are converted before they are compared
+	currency := NormalizeCurrency(b.Currency)
+	rates := money.Current()
+	if !money.ValidCode(currency) || !rates.Has(currency) {
+		errs = append(errs, FieldError{
+			Field: "currency", Rule: "currency",
+			Message: "must be an ISO 4217 code with an exchange rate",
+		})
+	} else if ceiling := rules.PriceCeiling(b.CategoryID); ceiling > 0 {
+		base, err := rates.Convert(b.PriceMinor, currency, money.BaseCurrency)
+		if err == nil && base > money.FromMajor(int64(ceiling), money.BaseCurrency) {
+			errs = append(errs, FieldError{
+				Field: "price_minor", Rule: "max",
+				Message: fmt.Sprintf("must not exceed %d %s in this category", ceiling, money.BaseCurrency),
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package money handles prices as integer minor units of an ISO 4217
+// currency, so amounts are never rounded by floating point.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// BaseCurrency is the currency of the rate table and of books without
+// their own currency
+const BaseCurrency = "IDR"
+
+// ErrUnknownCurrency is returned when no rate is known for a currency
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// codePattern matches an ISO 4217 alphabetic code
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// exponents lists the ISO 4217 minor unit exponents that differ from 2
+var exponents = map[string]int{
+	"BHD": 3, "CLP": 0, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "OMR": 3, "PYG": 0, "TND": 3, "UGX": 0, "VND": 0,
+}
+
+// ValidCode reports whether code looks like an ISO 4217 currency code
+func ValidCode(code string) bool {
+	return codePattern.MatchString(code)
+}
+
+// Exponent is the number of decimal places of a currency's minor unit
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// unit is 10^exponent of currency as a big.Int
+func unit(currency string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Exponent(currency))), nil)
+}
+
+// Price is an amount in the minor unit of a currency
+type Price struct {
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	// Amount is the same value as an exact decimal string
+	Amount string `json:"amount"`
+}
+
+// NewPrice builds a Price from minor units
+func NewPrice(currency string, minor int64) Price {
+	return Price{Currency: currency, AmountMinor: minor, Amount: Format(minor, currency)}
+}
+
+// FromMajor converts whole units (such as price ceilings) to minor units
+func FromMajor(major int64, currency string) int64 {
+	return major * unit(currency).Int64()
+}
+
+// Format writes minor units as a decimal string, e.g. 1999 USD as "19.99"
+func Format(minor int64, currency string) string {
+	exponent := Exponent(currency)
+	return new(big.Rat).SetFrac(big.NewInt(minor), unit(currency)).FloatString(exponent)
+}
+
+// Parse reads a decimal amount into minor units. Amounts with more decimal
+// places than the currency has are rejected rather than rounded.
+func Parse(amount, currency string) (int64, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || strings.Contains(amount, "/") {
+		return 0, fmt.Errorf("%q is not a decimal amount", amount)
+	}
+
+	value.Mul(value, new(big.Rat).SetInt(unit(currency)))
+	if !value.IsInt() {
+		return 0, fmt.Errorf("%s amounts have at most %d decimal places", currency, Exponent(currency))
+	}
+	if !value.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	return value.Num().Int64(), nil
+}
+
+// round rounds a rational to the nearest integer, halves away from zero
+func round(value *big.Rat) *big.Int {
+	num := new(big.Int).Abs(value.Num())
+	quotient, remainder := new(big.Int).QuoRem(num, value.Denom(), new(big.Int))
+	if remainder.Mul(remainder, big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if value.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+	return quotient
+}
@@ -0,0 +1,122 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"19.99", "USD", 1999, false},
+		{" 19.9 ", "USD", 1990, false},
+		{"0", "USD", 0, false},
+		{"-5.25", "EUR", -525, false},
+		{"1.999", "USD", 0, true},
+		{"150000", "IDR", 15000000, false},
+		{"1500", "JPY", 1500, false},
+		{"1500.0", "JPY", 1500, false},
+		{"1500.5", "JPY", 0, true},
+		{"12.345", "KWD", 12345, false},
+		{"0.001", "BHD", 1, false},
+		{"12.3456", "KWD", 0, true},
+		{"1/2", "USD", 0, true},
+		{"abc", "USD", 0, true},
+		{"", "USD", 0, true},
+		{"100000000000000000000", "USD", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q, %s) error = %v, wantErr %v", tt.amount, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{1999, "USD", "19.99"},
+		{5, "USD", "0.05"},
+		{0, "USD", "0.00"},
+		{-525, "EUR", "-5.25"},
+		{15000000, "IDR", "150000.00"},
+		{1500, "JPY", "1500"},
+		{0, "KRW", "0"},
+		{12345, "KWD", "12.345"},
+		{1, "BHD", "0.001"},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.minor, tt.currency); got != tt.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tt.minor, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	for _, currency := range []string{"USD", "JPY", "KWD"} {
+		for _, minor := range []int64{0, 1, 999, 123456789} {
+			got, err := Parse(Format(minor, currency), currency)
+			if err != nil || got != minor {
+				t.Errorf("Parse(Format(%d, %s)) = %d, %v", minor, currency, got, err)
+			}
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// Value of one whole unit in IDR
+	rates := NewRates(map[string]*big.Rat{
+		"USD": big.NewRat(16000, 1),
+		"JPY": big.NewRat(100, 1),
+		"KWD": big.NewRat(52000, 1),
+	})
+
+	tests := []struct {
+		name    string
+		minor   int64
+		from    string
+		to      string
+		want    int64
+		wantErr error
+	}{
+		{"same currency", 1999, "USD", "USD", 1999, nil},
+		{"to base", 1999, "USD", "IDR", 31984000, nil},
+		{"from base", 1600000, "IDR", "USD", 100, nil},
+		{"to exponent 0", 100, "USD", "JPY", 160, nil},
+		{"from exponent 0", 160, "JPY", "USD", 100, nil},
+		{"from exponent 3", 1000, "KWD", "USD", 325, nil},
+		{"to exponent 3", 325, "USD", "KWD", 1000, nil},
+		{"half rounds up", 5000, "IDR", "JPY", 1, nil},
+		{"negative half rounds down", -5000, "IDR", "JPY", -1, nil},
+		{"below half rounds to zero", 4999, "IDR", "JPY", 0, nil},
+		{"unknown source", 100, "GBP", "USD", 0, ErrUnknownCurrency},
+		{"unknown target", 100, "USD", "GBP", 0, ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(tt.minor, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert(%d, %s, %s) error = %v, want %v", tt.minor, tt.from, tt.to, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert(%d, %s, %s) = %d, want %d", tt.minor, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
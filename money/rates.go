@@ -0,0 +1,85 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// Rates converts between currencies. Each rate is the value of one whole
+// unit of a currency in BaseCurrency.
+type Rates struct {
+	rates map[string]*big.Rat
+}
+
+// NewRates builds a rate table. BaseCurrency is always included with a
+// rate of 1.
+func NewRates(rates map[string]*big.Rat) *Rates {
+	table := &Rates{rates: map[string]*big.Rat{BaseCurrency: big.NewRat(1, 1)}}
+	for currency, rate := range rates {
+		if currency != BaseCurrency {
+			table.rates[currency] = new(big.Rat).Set(rate)
+		}
+	}
+	return table
+}
+
+// Has reports whether currency can be converted
+func (r *Rates) Has(currency string) bool {
+	_, ok := r.rates[currency]
+	return ok
+}
+
+// Currencies lists the convertible currencies in alphabetical order
+func (r *Rates) Currencies() []string {
+	currencies := make([]string, 0, len(r.rates))
+	for currency := range r.rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// Convert converts minor units of one currency into minor units of
+// another, rounding once at the end (halves away from zero)
+func (r *Rates) Convert(minor int64, from, to string) (int64, error) {
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, from)
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, to)
+	}
+
+	value := new(big.Rat).SetFrac(big.NewInt(minor), unit(from))
+	value.Mul(value, fromRate)
+	value.Quo(value, toRate)
+	value.Mul(value, new(big.Rat).SetInt(unit(to)))
+
+	converted := round(value)
+	if !converted.IsInt64() {
+		return 0, fmt.Errorf("converted amount is too large")
+	}
+	return converted.Int64(), nil
+}
+
+var (
+	currentMu sync.RWMutex
+	current   = NewRates(nil)
+)
+
+// SetRates replaces the rate table used by Current
+func SetRates(rates *Rates) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = rates
+}
+
+// Current returns the rate table in use
+func Current() *Rates {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
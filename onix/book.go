@@ -0,0 +1,324 @@
+package onix
+
+import (
+	"book-management/models"
+	"book-management/money"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ONIX code list values used when building and reading products
+const (
+	NotificationConfirmed = "03"
+	NotificationDelete    = "05"
+
+	productIDProprietary = "01"
+	productIDISBN10      = "02"
+	productIDGTIN13      = "03"
+	productIDISBN13      = "15"
+
+	contributorAuthor = "A01"
+
+	// priceTypeRRP is an RRP including tax
+	priceTypeRRP = "02"
+
+	// CategorySchemeName labels our categories in proprietary Subject composites
+	CategorySchemeName = "Book Management Category"
+)
+
+var (
+	yearPattern    = regexp.MustCompile(`^\d{4}`)
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// CategorizedBook is a book together with its category name
+type CategorizedBook struct {
+	models.Book
+	CategoryName string
+}
+
+// DeletedProduct builds the delete notification for a removed book. Only
+// the identifiers are sent, as receivers match deletions on those.
+func DeletedProduct(id int, isbn string) Product {
+	return Product{
+		RecordReference:    recordReference(id),
+		NotificationType:   NotificationDelete,
+		ProductIdentifiers: productIdentifiers(id, isbn),
+	}
+}
+
+func recordReference(id int) string {
+	return "book-management-" + strconv.Itoa(id)
+}
+
+// productIdentifiers identifies a book by its ID and, when set, its ISBN
+func productIdentifiers(id int, isbn string) []ProductIdentifier {
+	ids := []ProductIdentifier{
+		{ProductIDType: productIDProprietary, IDValue: strconv.Itoa(id)},
+	}
+	if isbn != "" {
+		idType := productIDISBN13
+		if len(isbn) == 10 {
+			idType = productIDISBN10
+		}
+		ids = append(ids, ProductIdentifier{ProductIDType: idType, IDValue: isbn})
+	}
+	return ids
+}
+
+// prices lists the base price of a book, without a territory, followed by
+// its market prices. A two-letter market is sent as a country code and any
+// other market as an ONIX region code.
+func prices(book models.Book) []Price {
+	list := []Price{{
+		PriceType:    priceTypeRRP,
+		PriceAmount:  money.Format(book.PriceMinor, book.Currency),
+		CurrencyCode: book.Currency,
+	}}
+	for _, price := range book.Prices {
+		territory := &Territory{RegionsIncluded: price.Market}
+		if countryPattern.MatchString(price.Market) {
+			territory = &Territory{CountriesIncluded: price.Market}
+		}
+		list = append(list, Price{
+			PriceType:    priceTypeRRP,
+			PriceAmount:  price.Amount,
+			CurrencyCode: price.Currency,
+			Territory:    territory,
+		})
+	}
+	return list
+}
+
+// FromBook builds a product record for a book. Market prices are only
+// included when book.Prices is loaded.
+func FromBook(book CategorizedBook, supplier string) Product {
+	product := Product{
+		RecordReference:    recordReference(book.ID),
+		NotificationType:   NotificationConfirmed,
+		ProductIdentifiers: productIdentifiers(book.ID, book.ISBN),
+		DescriptiveDetail: &DescriptiveDetail{
+			ProductComposition: "00",
+			ProductForm:        "BA",
+			TitleDetails: []TitleDetail{{
+				TitleType: "01",
+				TitleElements: []TitleElement{{
+					TitleElementLevel: "01",
+					TitleText:         book.Title,
+				}},
+			}},
+			Extents: []Extent{{
+				ExtentType:  "00",
+				ExtentValue: strconv.Itoa(book.TotalPage),
+				ExtentUnit:  "03",
+			}},
+		},
+		PublishingDetail: &PublishingDetail{
+			PublishingStatus: "04",
+			PublishingDates: []PublishingDate{{
+				PublishingDateRole: "01",
+				Date:               Date{Format: "05", Value: strconv.Itoa(book.ReleaseYear)},
+			}},
+		},
+		ProductSupply: &ProductSupply{
+			SupplyDetails: []SupplyDetail{{
+				Supplier:            Supplier{SupplierRole: "01", SupplierName: supplier},
+				ProductAvailability: "20",
+				Prices:              prices(book.Book),
+			}},
+		},
+	}
+
+	for i, author := range book.Authors {
+		product.DescriptiveDetail.Contributors = append(product.DescriptiveDetail.Contributors, Contributor{
+			SequenceNumber:  strconv.Itoa(i + 1),
+			ContributorRole: contributorAuthor,
+			PersonName:      author,
+		})
+	}
+
+	if book.Publisher != "" {
+		product.PublishingDetail.Publishers = []Publisher{{
+			PublishingRole: "01",
+			PublisherName:  book.Publisher,
+		}}
+	}
+
+	if book.CategoryName != "" {
+		product.DescriptiveDetail.Subjects = []Subject{{
+			SubjectSchemeIdentifier: "24",
+			SubjectSchemeName:       CategorySchemeName,
+			SubjectCode:             strconv.Itoa(book.CategoryID),
+			SubjectHeadingText:      book.CategoryName,
+		}}
+	}
+
+	collateral := &CollateralDetail{}
+	if book.Description != "" {
+		collateral.TextContents = []TextContent{{
+			TextType:        "03",
+			ContentAudience: "00",
+			Text:            book.Description,
+		}}
+	}
+	if book.ImageURL != "" {
+		collateral.SupportingResources = []SupportingResource{{
+			ResourceContentType: "01",
+			ContentAudience:     "00",
+			ResourceMode:        "03",
+			ResourceVersions: []ResourceVersion{{
+				ResourceForm: "02",
+				ResourceLink: book.ImageURL,
+			}},
+		}}
+	}
+	if collateral.TextContents != nil || collateral.SupportingResources != nil {
+		product.CollateralDetail = collateral
+	}
+
+	return product
+}
+
+// ToBookInput maps a product record onto book fields. The category name is
+// taken from a Subject in our proprietary scheme, or the first Subject
+// heading, and still has to be resolved to a category ID by the caller.
+func ToBookInput(product Product) (input models.BookInput, categoryName string) {
+	for _, id := range product.ProductIdentifiers {
+		if id.ProductIDType == productIDISBN13 || id.ProductIDType == productIDGTIN13 || id.ProductIDType == productIDISBN10 {
+			input.ISBN = models.NormalizeISBN(id.IDValue)
+			break
+		}
+	}
+
+	if detail := product.DescriptiveDetail; detail != nil {
+		input.Title = title(detail.TitleDetails)
+
+		for _, extent := range detail.Extents {
+			// 00 main content, 07 total numbered pages, 11 content page count
+			if extent.ExtentUnit == "03" && (extent.ExtentType == "00" || extent.ExtentType == "07" || extent.ExtentType == "11") {
+				input.TotalPage, _ = strconv.Atoi(strings.TrimSpace(extent.ExtentValue))
+				break
+			}
+		}
+
+		for _, contributor := range detail.Contributors {
+			if contributor.ContributorRole != contributorAuthor {
+				continue
+			}
+			if name := contributorName(contributor); name != "" {
+				input.Authors = append(input.Authors, name)
+			}
+		}
+
+		for _, subject := range detail.Subjects {
+			if subject.SubjectSchemeName == CategorySchemeName {
+				categoryName = subject.SubjectHeadingText
+				break
+			}
+			if categoryName == "" {
+				categoryName = subject.SubjectHeadingText
+			}
+		}
+	}
+
+	if collateral := product.CollateralDetail; collateral != nil {
+		for _, text := range collateral.TextContents {
+			// Prefer the main description (03) over the short one (02)
+			if text.TextType == "03" || (text.TextType == "02" && input.Description == "") {
+				input.Description = strings.TrimSpace(text.Text)
+			}
+		}
+		for _, resource := range collateral.SupportingResources {
+			if resource.ResourceContentType == "01" && len(resource.ResourceVersions) > 0 {
+				input.ImageURL = resource.ResourceVersions[0].ResourceLink
+				break
+			}
+		}
+	}
+
+	if publishing := product.PublishingDetail; publishing != nil {
+		for _, publisher := range publishing.Publishers {
+			// Prefer the main publisher (01) over any other role
+			if publisher.PublishingRole == "01" || input.Publisher == "" {
+				input.Publisher = strings.TrimSpace(publisher.PublisherName)
+			}
+			if publisher.PublishingRole == "01" {
+				break
+			}
+		}
+
+		for _, date := range publishing.PublishingDates {
+			if date.PublishingDateRole == "01" {
+				if year := yearPattern.FindString(strings.TrimSpace(date.Date.Value)); year != "" {
+					input.ReleaseYear, _ = strconv.Atoi(year)
+				}
+				break
+			}
+		}
+	}
+
+	// The base price is the first price without a territory, or else the
+	// first price. Prices without a currency are in money.BaseCurrency.
+	if supply := product.ProductSupply; supply != nil {
+		var base *Price
+		for _, detail := range supply.SupplyDetails {
+			for i, price := range detail.Prices {
+				if base == nil || (base.Territory != nil && price.Territory == nil) {
+					base = &detail.Prices[i]
+				}
+			}
+		}
+		if base != nil {
+			currency := models.NormalizeCurrency(base.CurrencyCode)
+			if minor, err := money.Parse(base.PriceAmount, currency); err == nil {
+				input.PriceMinor = minor
+				input.Currency = currency
+			}
+		}
+	}
+
+	return input, categoryName
+}
+
+// contributorName picks the most complete name form of a contributor
+func contributorName(contributor Contributor) string {
+	switch {
+	case contributor.PersonName != "":
+		return strings.TrimSpace(contributor.PersonName)
+	case contributor.KeyNames != "":
+		return strings.TrimSpace(contributor.NamesBeforeKey + " " + contributor.KeyNames)
+	case contributor.PersonNameInverted != "":
+		return strings.TrimSpace(contributor.PersonNameInverted)
+	}
+	return strings.TrimSpace(contributor.CorporateName)
+}
+
+// title joins the distinctive product-level title and subtitle
+func title(details []TitleDetail) string {
+	for _, detail := range details {
+		if detail.TitleType != "01" {
+			continue
+		}
+		for _, element := range detail.TitleElements {
+			if element.TitleElementLevel != "01" {
+				continue
+			}
+			text := element.TitleText
+			if text == "" {
+				text = strings.TrimSpace(element.TitlePrefix + " " + element.TitleWithoutPrefix)
+			}
+			if element.Subtitle != "" {
+				text += " : " + element.Subtitle
+			}
+			return strings.TrimSpace(text)
+		}
+	}
+	return ""
+}
+
+// SentDateTime formats t as an ONIX header timestamp
+func SentDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,184 @@
+// Package onix writes and reads ONIX for Books 3.0 product records
+// (reference tags) and maps them to books.
+package onix
+
+import "encoding/xml"
+
+// Namespace is the ONIX 3.0 reference tag namespace
+const Namespace = "http://ns.editeur.org/onix/3.0/reference"
+
+// Message is an <ONIXMessage>
+type Message struct {
+	XMLName  xml.Name  `xml:"ONIXMessage"`
+	Release  string    `xml:"release,attr"`
+	Header   Header    `xml:"Header"`
+	Products []Product `xml:"Product"`
+}
+
+// Header identifies the sender of a message
+type Header struct {
+	Sender       Sender `xml:"Sender"`
+	SentDateTime string `xml:"SentDateTime"`
+}
+
+// Sender is the <Sender> composite
+type Sender struct {
+	SenderName string `xml:"SenderName"`
+}
+
+// Product is a single <Product> record
+type Product struct {
+	XMLName            xml.Name            `xml:"Product"`
+	RecordReference    string              `xml:"RecordReference"`
+	NotificationType   string              `xml:"NotificationType"`
+	ProductIdentifiers []ProductIdentifier `xml:"ProductIdentifier"`
+	DescriptiveDetail  *DescriptiveDetail  `xml:"DescriptiveDetail"`
+	CollateralDetail   *CollateralDetail   `xml:"CollateralDetail"`
+	PublishingDetail   *PublishingDetail   `xml:"PublishingDetail"`
+	ProductSupply      *ProductSupply      `xml:"ProductSupply"`
+
+	// Raw is the inner XML of a decoded product, kept for raw_metadata
+	Raw string `xml:",innerxml" json:"-"`
+}
+
+// ProductIdentifier is a typed product ID such as an ISBN-13
+type ProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDValue       string `xml:"IDValue"`
+}
+
+// DescriptiveDetail is block 1 of a product record
+type DescriptiveDetail struct {
+	ProductComposition string        `xml:"ProductComposition"`
+	ProductForm        string        `xml:"ProductForm"`
+	TitleDetails       []TitleDetail `xml:"TitleDetail"`
+	Contributors       []Contributor `xml:"Contributor"`
+	Extents            []Extent      `xml:"Extent"`
+	Subjects           []Subject     `xml:"Subject"`
+}
+
+// TitleDetail is the <TitleDetail> composite
+type TitleDetail struct {
+	TitleType     string         `xml:"TitleType"`
+	TitleElements []TitleElement `xml:"TitleElement"`
+}
+
+// TitleElement holds the title text, either whole or split around a prefix
+type TitleElement struct {
+	TitleElementLevel  string `xml:"TitleElementLevel"`
+	TitleText          string `xml:"TitleText,omitempty"`
+	TitlePrefix        string `xml:"TitlePrefix,omitempty"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix,omitempty"`
+	Subtitle           string `xml:"Subtitle,omitempty"`
+}
+
+// Contributor is a person credited with creating the product
+type Contributor struct {
+	SequenceNumber     string `xml:"SequenceNumber,omitempty"`
+	ContributorRole    string `xml:"ContributorRole"`
+	PersonName         string `xml:"PersonName,omitempty"`
+	PersonNameInverted string `xml:"PersonNameInverted,omitempty"`
+	NamesBeforeKey     string `xml:"NamesBeforeKey,omitempty"`
+	KeyNames           string `xml:"KeyNames,omitempty"`
+	CorporateName      string `xml:"CorporateName,omitempty"`
+}
+
+// Extent is a page count or other measure of the product
+type Extent struct {
+	ExtentType  string `xml:"ExtentType"`
+	ExtentValue string `xml:"ExtentValue"`
+	ExtentUnit  string `xml:"ExtentUnit"`
+}
+
+// Subject is a subject scheme heading
+type Subject struct {
+	SubjectSchemeIdentifier string `xml:"SubjectSchemeIdentifier"`
+	SubjectSchemeName       string `xml:"SubjectSchemeName,omitempty"`
+	SubjectCode             string `xml:"SubjectCode,omitempty"`
+	SubjectHeadingText      string `xml:"SubjectHeadingText,omitempty"`
+}
+
+// CollateralDetail is block 2 of a product record
+type CollateralDetail struct {
+	TextContents        []TextContent        `xml:"TextContent"`
+	SupportingResources []SupportingResource `xml:"SupportingResource"`
+}
+
+// TextContent is a description or other text about the product
+type TextContent struct {
+	TextType        string `xml:"TextType"`
+	ContentAudience string `xml:"ContentAudience"`
+	Text            string `xml:"Text"`
+}
+
+// SupportingResource points to a cover image or other resource
+type SupportingResource struct {
+	ResourceContentType string            `xml:"ResourceContentType"`
+	ContentAudience     string            `xml:"ContentAudience"`
+	ResourceMode        string            `xml:"ResourceMode"`
+	ResourceVersions    []ResourceVersion `xml:"ResourceVersion"`
+}
+
+// ResourceVersion is one link to a supporting resource
+type ResourceVersion struct {
+	ResourceForm string `xml:"ResourceForm"`
+	ResourceLink string `xml:"ResourceLink"`
+}
+
+// PublishingDetail is block 4 of a product record
+type PublishingDetail struct {
+	Publishers       []Publisher      `xml:"Publisher"`
+	PublishingStatus string           `xml:"PublishingStatus,omitempty"`
+	PublishingDates  []PublishingDate `xml:"PublishingDate"`
+}
+
+// Publisher is the <Publisher> composite
+type Publisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+// PublishingDate is a dated publishing event
+type PublishingDate struct {
+	PublishingDateRole string `xml:"PublishingDateRole"`
+	Date               Date   `xml:"Date"`
+}
+
+// Date is an ONIX date; Format is a List 55 code ("" means YYYYMMDD)
+type Date struct {
+	Format string `xml:"dateformat,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+// ProductSupply is block 6 of a product record
+type ProductSupply struct {
+	SupplyDetails []SupplyDetail `xml:"SupplyDetail"`
+}
+
+// SupplyDetail describes a supplier and its prices
+type SupplyDetail struct {
+	Supplier            Supplier `xml:"Supplier"`
+	ProductAvailability string   `xml:"ProductAvailability"`
+	Prices              []Price  `xml:"Price"`
+}
+
+// Supplier is the <Supplier> composite
+type Supplier struct {
+	SupplierRole string `xml:"SupplierRole"`
+	SupplierName string `xml:"SupplierName"`
+}
+
+// Price is a single price of the product. A price without a Territory
+// applies wherever no territory price does.
+type Price struct {
+	PriceType    string     `xml:"PriceType"`
+	PriceAmount  string     `xml:"PriceAmount"`
+	CurrencyCode string     `xml:"CurrencyCode"`
+	Territory    *Territory `xml:"Territory,omitempty"`
+}
+
+// Territory is the <Territory> composite of a price
+type Territory struct {
+	CountriesIncluded string `xml:"CountriesIncluded,omitempty"`
+	RegionsIncluded   string `xml:"RegionsIncluded,omitempty"`
+}
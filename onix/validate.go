@@ -0,0 +1,192 @@
+package onix
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ValidationError describes a problem in one product record
+type ValidationError struct {
+	Product string `json:"product"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Product, e.Path, e.Message)
+}
+
+var (
+	notificationTypes = codeList("01", "02", "03", "04", "05", "08", "09", "88", "89")
+	productIDTypes    = codeList("01", "02", "03", "04", "05", "06", "13", "14", "15", "17", "22", "23", "24", "26", "28", "29", "30", "31", "35")
+	compositions      = codeList("00", "01", "10", "11", "20", "30", "31")
+	dateFormats       = codeList("", "00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12", "13", "14")
+
+	productFormPattern = regexp.MustCompile(`^[A-Z0-9]{2}$`)
+	twoDigitPattern    = regexp.MustCompile(`^\d{2}$`)
+	currencyPattern    = regexp.MustCompile(`^[A-Z]{3}$`)
+	amountPattern      = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+func codeList(codes ...string) map[string]bool {
+	list := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		list[code] = true
+	}
+	return list
+}
+
+// Validate checks a message against the cardinality, code list and format
+// rules of the ONIX 3.0 reference schema for the elements this package
+// reads and writes. Elements outside that subset are not checked.
+func Validate(message Message) []ValidationError {
+	var errs []ValidationError
+	add := func(product, path, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Product: product, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if message.Release != "3.0" {
+		add("", "ONIXMessage/@release", "must be 3.0, got %q", message.Release)
+	}
+	if message.Header.Sender.SenderName == "" {
+		add("", "Header/Sender/SenderName", "is required")
+	}
+	if message.Header.SentDateTime == "" {
+		add("", "Header/SentDateTime", "is required")
+	}
+
+	for i, product := range message.Products {
+		ref := product.RecordReference
+		if ref == "" {
+			ref = fmt.Sprintf("Product[%d]", i+1)
+			add(ref, "RecordReference", "is required")
+		}
+
+		if !notificationTypes[product.NotificationType] {
+			add(ref, "NotificationType", "%q is not in code list 1", product.NotificationType)
+		}
+
+		if len(product.ProductIdentifiers) == 0 {
+			add(ref, "ProductIdentifier", "at least one is required")
+		}
+		for _, id := range product.ProductIdentifiers {
+			if !productIDTypes[id.ProductIDType] {
+				add(ref, "ProductIdentifier/ProductIDType", "%q is not in code list 5", id.ProductIDType)
+			}
+			if strings.TrimSpace(id.IDValue) == "" {
+				add(ref, "ProductIdentifier/IDValue", "is required")
+			}
+			if (id.ProductIDType == productIDISBN13 || id.ProductIDType == productIDGTIN13) && !validEAN13(id.IDValue) {
+				add(ref, "ProductIdentifier/IDValue", "%q is not a valid 13-digit identifier", id.IDValue)
+			}
+		}
+
+		// Deletions only need the identifiers
+		if product.NotificationType == NotificationDelete {
+			continue
+		}
+
+		detail := product.DescriptiveDetail
+		if detail == nil {
+			add(ref, "DescriptiveDetail", "is required")
+			continue
+		}
+		if !compositions[detail.ProductComposition] {
+			add(ref, "DescriptiveDetail/ProductComposition", "%q is not in code list 2", detail.ProductComposition)
+		}
+		if !productFormPattern.MatchString(detail.ProductForm) {
+			add(ref, "DescriptiveDetail/ProductForm", "%q is not a valid List 150 code", detail.ProductForm)
+		}
+		if len(detail.TitleDetails) == 0 {
+			add(ref, "DescriptiveDetail/TitleDetail", "at least one is required")
+		}
+		for _, title := range detail.TitleDetails {
+			if !twoDigitPattern.MatchString(title.TitleType) {
+				add(ref, "TitleDetail/TitleType", "%q is not a valid List 15 code", title.TitleType)
+			}
+			if len(title.TitleElements) == 0 {
+				add(ref, "TitleDetail/TitleElement", "at least one is required")
+			}
+			for _, element := range title.TitleElements {
+				if !twoDigitPattern.MatchString(element.TitleElementLevel) {
+					add(ref, "TitleElement/TitleElementLevel", "%q is not a valid List 149 code", element.TitleElementLevel)
+				}
+				if element.TitleText == "" && element.TitleWithoutPrefix == "" {
+					add(ref, "TitleElement", "TitleText or TitleWithoutPrefix is required")
+				}
+			}
+		}
+		for _, extent := range detail.Extents {
+			if !twoDigitPattern.MatchString(extent.ExtentType) || !twoDigitPattern.MatchString(extent.ExtentUnit) {
+				add(ref, "Extent", "ExtentType and ExtentUnit must be two-digit codes")
+			}
+			if !amountPattern.MatchString(extent.ExtentValue) {
+				add(ref, "Extent/ExtentValue", "%q is not a number", extent.ExtentValue)
+			}
+		}
+
+		if publishing := product.PublishingDetail; publishing != nil {
+			for _, date := range publishing.PublishingDates {
+				if !dateFormats[date.Date.Format] {
+					add(ref, "PublishingDate/Date/@dateformat", "%q is not in code list 55", date.Date.Format)
+				}
+				if date.Date.Value == "" {
+					add(ref, "PublishingDate/Date", "is required")
+				}
+			}
+		}
+
+		if supply := product.ProductSupply; supply != nil {
+			for _, detail := range supply.SupplyDetails {
+				if detail.Supplier.SupplierName == "" {
+					add(ref, "SupplyDetail/Supplier/SupplierName", "is required")
+				}
+				if !twoDigitPattern.MatchString(detail.ProductAvailability) {
+					add(ref, "SupplyDetail/ProductAvailability", "%q is not a valid List 65 code", detail.ProductAvailability)
+				}
+				for _, price := range detail.Prices {
+					if !amountPattern.MatchString(price.PriceAmount) {
+						add(ref, "Price/PriceAmount", "%q is not a decimal amount", price.PriceAmount)
+					}
+					if price.CurrencyCode != "" && !currencyPattern.MatchString(price.CurrencyCode) {
+						add(ref, "Price/CurrencyCode", "%q is not an ISO 4217 code", price.CurrencyCode)
+					}
+					if territory := price.Territory; territory != nil {
+						if territory.CountriesIncluded == "" && territory.RegionsIncluded == "" {
+							add(ref, "Price/Territory", "needs CountriesIncluded or RegionsIncluded")
+						}
+						for _, country := range strings.Fields(territory.CountriesIncluded) {
+							if !countryPattern.MatchString(country) {
+								add(ref, "Price/Territory/CountriesIncluded", "%q is not an ISO 3166-1 code", country)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// validEAN13 checks the length and check digit of an ISBN-13 / GTIN-13
+func validEAN13(value string) bool {
+	value = strings.ReplaceAll(value, "-", "")
+	if len(value) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digit := int(r - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,72 @@
+package onix
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+)
+
+// Read decodes an ONIX 3.0 message with reference tags
+func Read(r io.Reader) (Message, error) {
+	var message Message
+	decoder := xml.NewDecoder(r)
+	if err := decoder.Decode(&message); err != nil {
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return message, err
+		}
+		// A short-tag <ONIXmessage> root fails the XMLName match
+		return message, errors.New("expected an ONIX 3.0 <ONIXMessage> with reference tags")
+	}
+	return message, nil
+}
+
+// Writer streams products as an ONIX message
+type Writer struct {
+	encoder *xml.Encoder
+}
+
+// NewWriter writes the XML header, the opening <ONIXMessage> and the <Header>
+func NewWriter(w io.Writer, sender string, sent time.Time) (*Writer, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err := encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "ONIXMessage"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: Namespace},
+			{Name: xml.Name{Local: "release"}, Value: "3.0"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header := Header{Sender: Sender{SenderName: sender}, SentDateTime: SentDateTime(sent)}
+	if err := encoder.EncodeElement(header, xml.StartElement{Name: xml.Name{Local: "Header"}}); err != nil {
+		return nil, err
+	}
+	return &Writer{encoder: encoder}, nil
+}
+
+// Write encodes a single <Product>
+func (w *Writer) Write(product Product) error {
+	return w.encoder.Encode(product)
+}
+
+// Flush flushes buffered XML to the underlying writer
+func (w *Writer) Flush() error {
+	return w.encoder.Flush()
+}
+
+// Close writes the closing </ONIXMessage>
+func (w *Writer) Close() error {
+	if err := w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "ONIXMessage"}}); err != nil {
+		return err
+	}
+	return w.encoder.Flush()
+}
@@ -0,0 +1,171 @@
+package opds
+
+import (
+	"book-management/models"
+	"book-management/money"
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// Feed is an Atom feed in OPDS 1.2 form
+type Feed struct {
+	XMLName      xml.Name `xml:"feed"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	XmlnsDC      string   `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string   `xml:"xmlns:opds,attr"`
+	XmlnsOS      string   `xml:"xmlns:opensearch,attr"`
+	ID           string   `xml:"id"`
+	Title        string   `xml:"title"`
+	Updated      string   `xml:"updated"`
+	TotalResults int      `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int      `xml:"opensearch:itemsPerPage,omitempty"`
+	Links        []Link   `xml:"link"`
+	Entries      []Entry  `xml:"entry"`
+}
+
+// Link is an Atom link
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Price *Price `xml:"opds:price,omitempty"`
+}
+
+// Price is an opds:price on an acquisition link
+type Price struct {
+	CurrencyCode string `xml:"currencycode,attr"`
+	Value        string `xml:",chardata"`
+}
+
+// Entry is an Atom entry for a navigation target or a book
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    string     `xml:"updated"`
+	Identifier string     `xml:"dc:identifier,omitempty"`
+	Issued     string     `xml:"dc:issued,omitempty"`
+	Summary    *Text      `xml:"summary,omitempty"`
+	Content    *Text      `xml:"content,omitempty"`
+	Categories []Category `xml:"category"`
+	Links      []Link     `xml:"link"`
+}
+
+// Text is an Atom text construct
+type Text struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Category is an Atom category
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+func newFeed(id, title string, updated time.Time) Feed {
+	return Feed{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsDC:   "http://purl.org/dc/terms/",
+		XmlnsOPDS: "http://opds-spec.org/2010/catalog",
+		XmlnsOS:   "http://a9.com/-/spec/opensearch/1.1/",
+		ID:        id,
+		Title:     title,
+		Updated:   updated.UTC().Format(time.RFC3339),
+	}
+}
+
+// NavigationFeed builds the root navigation feed: one entry for all books
+// and one per category, plus the OpenSearch link
+func NavigationFeed(base string, categories []models.Category) Feed {
+	feed := newFeed("urn:book-management:catalog", catalogTitle, time.Now())
+	feed.Links = []Link{
+		{Rel: "self", Href: base, Type: NavigationType},
+		{Rel: "start", Href: base, Type: NavigationType},
+		{Rel: "search", Href: base + "/opensearch.xml", Type: OpenSearchType},
+	}
+
+	feed.Entries = append(feed.Entries, Entry{
+		ID:      "urn:book-management:books",
+		Title:   "All books",
+		Updated: feed.Updated,
+		Content: &Text{Type: "text", Value: "Every book in the catalog"},
+		Links:   []Link{{Rel: "subsection", Href: base + "/books", Type: AcquisitionType}},
+	})
+
+	for _, category := range categories {
+		feed.Entries = append(feed.Entries, Entry{
+			ID:      categoryIDPrefix + strconv.Itoa(category.ID),
+			Title:   category.Name,
+			Updated: category.ModifiedAt.UTC().Format(time.RFC3339),
+			Content: &Text{Type: "text", Value: "Books in " + category.Name},
+			Links:   []Link{{Rel: "subsection", Href: base + "/categories/" + strconv.Itoa(category.ID), Type: AcquisitionType}},
+		})
+	}
+	return feed
+}
+
+// AcquisitionFeed builds one page of books with paging links.
+// categories maps category IDs to names.
+func AcquisitionFeed(base string, page Page, books []models.Book, categories map[int]string) Feed {
+	updated := time.Time{}
+	for _, book := range books {
+		if book.ModifiedAt.After(updated) {
+			updated = book.ModifiedAt
+		}
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	feed := newFeed(page.FeedID, page.Title, updated)
+	feed.TotalResults = page.Total
+	feed.ItemsPerPage = page.Size
+	feed.Links = []Link{
+		{Rel: "start", Href: base, Type: NavigationType},
+		{Rel: "up", Href: base, Type: NavigationType},
+		{Rel: "search", Href: base + "/opensearch.xml", Type: OpenSearchType},
+	}
+	for _, link := range page.pageLinks() {
+		feed.Links = append(feed.Links, Link{Rel: link[0], Href: link[1], Type: AcquisitionType})
+	}
+
+	for _, book := range books {
+		feed.Entries = append(feed.Entries, bookEntry(book, categories[book.CategoryID]))
+	}
+	return feed
+}
+
+// bookEntry builds the Atom entry of a book
+func bookEntry(book models.Book, categoryName string) Entry {
+	entry := Entry{
+		ID:      bookIDPrefix + strconv.Itoa(book.ID),
+		Title:   book.Title,
+		Updated: book.ModifiedAt.UTC().Format(time.RFC3339),
+		Issued:  strconv.Itoa(book.ReleaseYear),
+		Links: []Link{{
+			Rel:   relAcquisitionBuy,
+			Href:  "/api/books/" + strconv.Itoa(book.ID),
+			Type:  "application/json",
+			Price: &Price{CurrencyCode: book.Currency, Value: money.Format(book.PriceMinor, book.Currency)},
+		}},
+	}
+
+	if book.ISBN != "" {
+		entry.Identifier = "urn:isbn:" + book.ISBN
+	}
+	if book.Description != "" {
+		entry.Summary = &Text{Type: "text", Value: book.Description}
+	}
+	if categoryName != "" {
+		entry.Categories = []Category{{Term: strconv.Itoa(book.CategoryID), Label: categoryName}}
+	}
+	if book.ImageURL != "" {
+		entry.Links = append(entry.Links,
+			Link{Rel: relImage, Href: book.ImageURL},
+			Link{Rel: relThumbnail, Href: book.ImageURL},
+		)
+	}
+	return entry
+}
@@ -0,0 +1,149 @@
+package opds
+
+import (
+	"book-management/models"
+	"book-management/money"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Feed2 is an OPDS 2.0 feed
+type Feed2 struct {
+	Metadata     Metadata2      `json:"metadata"`
+	Links        []Link2        `json:"links"`
+	Navigation   []Link2        `json:"navigation,omitempty"`
+	Publications []Publication2 `json:"publications,omitempty"`
+}
+
+// Metadata2 is the metadata of a feed or publication
+type Metadata2 struct {
+	Type          string     `json:"@type,omitempty"`
+	Identifier    string     `json:"identifier,omitempty"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description,omitempty"`
+	Modified      string     `json:"modified,omitempty"`
+	Published     string     `json:"published,omitempty"`
+	NumberOfItems int        `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int        `json:"itemsPerPage,omitempty"`
+	CurrentPage   int        `json:"currentPage,omitempty"`
+	NumberOfPages int        `json:"numberOfPages,omitempty"`
+	Subject       []Subject2 `json:"subject,omitempty"`
+}
+
+// Subject2 is a publication subject
+type Subject2 struct {
+	Name string `json:"name"`
+	Code string `json:"code,omitempty"`
+}
+
+// Link2 is an OPDS 2.0 / Readium Web Publication link
+type Link2 struct {
+	Rel        string      `json:"rel,omitempty"`
+	Href       string      `json:"href"`
+	Type       string      `json:"type,omitempty"`
+	Title      string      `json:"title,omitempty"`
+	Templated  bool        `json:"templated,omitempty"`
+	Properties *Properties `json:"properties,omitempty"`
+}
+
+// Properties carries the price of an acquisition link
+type Properties struct {
+	Price *Price2 `json:"price,omitempty"`
+}
+
+// Price2 is an OPDS 2.0 price. Value is a JSON number kept as the exact
+// decimal amount.
+type Price2 struct {
+	Currency string      `json:"currency"`
+	Value    json.Number `json:"value"`
+}
+
+// Publication2 is a book in an OPDS 2.0 feed
+type Publication2 struct {
+	Metadata Metadata2 `json:"metadata"`
+	Links    []Link2   `json:"links"`
+	Images   []Link2   `json:"images,omitempty"`
+}
+
+// NavigationFeed2 builds the OPDS 2.0 root navigation feed
+func NavigationFeed2(base string, categories []models.Category) Feed2 {
+	feed := Feed2{
+		Metadata: Metadata2{Title: catalogTitle, Modified: time.Now().UTC().Format(time.RFC3339)},
+		Links: []Link2{
+			{Rel: "self", Href: base + "/v2", Type: OPDS2Type},
+			{Rel: "search", Href: base + "/v2/search{?query}", Type: OPDS2Type, Templated: true},
+		},
+		Navigation: []Link2{
+			{Href: base + "/v2/books", Title: "All books", Type: OPDS2Type, Rel: "subsection"},
+		},
+	}
+
+	for _, category := range categories {
+		feed.Navigation = append(feed.Navigation, Link2{
+			Href:  base + "/v2/categories/" + strconv.Itoa(category.ID),
+			Title: category.Name,
+			Type:  OPDS2Type,
+			Rel:   "subsection",
+		})
+	}
+	return feed
+}
+
+// AcquisitionFeed2 builds one page of books as an OPDS 2.0 feed.
+// categories maps category IDs to names.
+func AcquisitionFeed2(base string, page Page, books []models.Book, categories map[int]string) Feed2 {
+	feed := Feed2{
+		Metadata: Metadata2{
+			Title:         page.Title,
+			NumberOfItems: page.Total,
+			ItemsPerPage:  page.Size,
+			CurrentPage:   page.Number,
+			NumberOfPages: page.TotalPages(),
+		},
+		Links: []Link2{
+			{Rel: "start", Href: base + "/v2", Type: OPDS2Type},
+			{Rel: "search", Href: base + "/v2/search{?query}", Type: OPDS2Type, Templated: true},
+		},
+		Publications: []Publication2{},
+	}
+	for _, link := range page.pageLinks() {
+		feed.Links = append(feed.Links, Link2{Rel: link[0], Href: link[1], Type: OPDS2Type})
+	}
+
+	for _, book := range books {
+		feed.Publications = append(feed.Publications, publication(book, categories[book.CategoryID]))
+	}
+	return feed
+}
+
+// publication builds the OPDS 2.0 publication of a book
+func publication(book models.Book, categoryName string) Publication2 {
+	pub := Publication2{
+		Metadata: Metadata2{
+			Type:        "http://schema.org/Book",
+			Identifier:  bookIDPrefix + strconv.Itoa(book.ID),
+			Title:       book.Title,
+			Description: book.Description,
+			Modified:    book.ModifiedAt.UTC().Format(time.RFC3339),
+			Published:   strconv.Itoa(book.ReleaseYear),
+		},
+		Links: []Link2{{
+			Rel:        relAcquisitionBuy,
+			Href:       "/api/books/" + strconv.Itoa(book.ID),
+			Type:       "application/json",
+			Properties: &Properties{Price: &Price2{Currency: book.Currency, Value: json.Number(money.Format(book.PriceMinor, book.Currency))}},
+		}},
+	}
+
+	if book.ISBN != "" {
+		pub.Metadata.Identifier = "urn:isbn:" + book.ISBN
+	}
+	if categoryName != "" {
+		pub.Metadata.Subject = []Subject2{{Name: categoryName, Code: strconv.Itoa(book.CategoryID)}}
+	}
+	if book.ImageURL != "" {
+		pub.Images = []Link2{{Href: book.ImageURL}}
+	}
+	return pub
+}
@@ -0,0 +1,67 @@
+// Package opds builds OPDS 1.2 (Atom) and OPDS 2.0 (JSON) catalog feeds
+// and the OpenSearch description used to search them.
+package opds
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Media types of the documents served by the catalog
+const (
+	NavigationType     = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType    = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType     = "application/opensearchdescription+xml"
+	OPDS2Type          = "application/opds+json"
+	relAcquisitionBuy  = "http://opds-spec.org/acquisition/buy"
+	relImage           = "http://opds-spec.org/image"
+	relThumbnail       = "http://opds-spec.org/image/thumbnail"
+	catalogTitle       = "Book Management Catalog"
+	bookIDPrefix       = "urn:book-management:book:"
+	categoryIDPrefix   = "urn:book-management:category:"
+	opensearchTemplate = "{searchTerms}"
+)
+
+// Page locates one page of an acquisition feed. Path is the feed's URL path
+// and Query holds its non-paging parameters.
+type Page struct {
+	Path   string
+	Query  url.Values
+	Number int
+	Size   int
+	Total  int
+	Title  string
+	FeedID string
+}
+
+// TotalPages is the number of pages in the feed
+func (p Page) TotalPages() int {
+	if p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.Size - 1) / p.Size
+}
+
+// href returns the URL of another page of the same feed
+func (p Page) href(number int) string {
+	query := url.Values{}
+	for key, values := range p.Query {
+		query[key] = values
+	}
+	query.Set("page", strconv.Itoa(number))
+	query.Set("page_size", strconv.Itoa(p.Size))
+	return p.Path + "?" + query.Encode()
+}
+
+// pageLinks lists the first/previous/next/last page URLs keyed by relation
+func (p Page) pageLinks() [][2]string {
+	links := [][2]string{{"self", p.href(p.Number)}, {"first", p.href(1)}}
+	if p.Number > 1 {
+		links = append(links, [2]string{"previous", p.href(p.Number - 1)})
+	}
+	if p.Number < p.TotalPages() {
+		links = append(links, [2]string{"next", p.href(p.Number + 1)})
+	}
+	links = append(links, [2]string{"last", p.href(p.TotalPages())})
+	return links
+}
@@ -0,0 +1,34 @@
+package opds
+
+import "encoding/xml"
+
+// OpenSearchDescription describes how to search the catalog
+type OpenSearchDescription struct {
+	XMLName       xml.Name    `xml:"OpenSearchDescription"`
+	Xmlns         string      `xml:"xmlns,attr"`
+	ShortName     string      `xml:"ShortName"`
+	Description   string      `xml:"Description"`
+	InputEncoding string      `xml:"InputEncoding"`
+	URLs          []SearchURL `xml:"Url"`
+}
+
+// SearchURL is an OpenSearch URL template
+type SearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearchDescription builds the descriptor for the Atom and JSON
+// search endpoints under base
+func NewOpenSearchDescription(base string) OpenSearchDescription {
+	return OpenSearchDescription{
+		Xmlns:         "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:     "Books",
+		Description:   "Search the " + catalogTitle,
+		InputEncoding: "UTF-8",
+		URLs: []SearchURL{
+			{Type: AcquisitionType, Template: base + "/search?q=" + opensearchTemplate},
+			{Type: OPDS2Type, Template: base + "/v2/search?q=" + opensearchTemplate},
+		},
+	}
+}
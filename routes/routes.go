@@ -14,17 +14,133 @@ func SetupRoutes(router *gin.Engine) {
 			"message": "Book Management API is running 🚀",
 			"endpoints": gin.H{
 				"Books": gin.H{
-					"GET /api/books":        "Menampilkan seluruh buku",
-					"POST /api/books":       "Menambahkan buku baru",
-					"GET /api/books/:id":    "Menampilkan detail buku berdasarkan ID",
-					"DELETE /api/books/:id": "Menghapus buku berdasarkan ID",
+					"GET /api/books":                                   "Menampilkan seluruh buku",
+					"POST /api/books":                                  "Menambahkan buku baru",
+					"POST /api/books/batch":                            "Create/update/delete banyak buku sekaligus",
+					"POST /api/books/import":                           "Import buku dari file CSV/XLSX (mendukung dry run)",
+					"GET /api/books/citations":                         "Sitasi banyak buku (BibTeX, RIS, CSL-JSON)",
+					"GET /api/books/validation-rules":                  "Aturan validasi buku yang berlaku",
+					"GET /api/books/:id":                               "Menampilkan detail buku berdasarkan ID",
+					"GET /api/books/:id/citation":                      "Sitasi buku (BibTeX, RIS, CSL-JSON)",
+					"GET /api/books/:id/prices":                        "Timeline harga: harga saat ini, riwayat, dan perubahan terjadwal",
+					"POST /api/books/:id/prices/scheduled":             "Menjadwalkan perubahan harga",
+					"DELETE /api/books/:id/prices/scheduled/:changeId": "Membatalkan perubahan harga terjadwal",
+					"PUT /api/books/:id/prices/:market":                "Mengatur harga buku di sebuah market",
+					"DELETE /api/books/:id/prices/:market":             "Menghapus harga buku di sebuah market",
+					"POST /api/books/:id/cover":                        "Upload cover buku (otomatis membuat thumbnail)",
+					"GET /api/books/:id/files":                         "Daftar file digital (PDF/EPUB) sebuah buku",
+					"POST /api/books/:id/files":                        "Upload file digital (PDF/EPUB)",
+					"DELETE /api/books/:id/files/:fileId":              "Hapus file digital",
+					"POST /api/books/:id/files/:fileId/link":           "Membuat link download bertanda tangan (sementara)",
+					"GET /api/books/:id/files/:fileId/downloads":       "Riwayat download file digital",
+					"PUT /api/books/:id":                               "Update buku berdasarkan ID (wajib If-Match)",
+					"PATCH /api/books/:id":                             "Update sebagian buku (merge-patch / json-patch)",
+					"DELETE /api/books/:id":                            "Menghapus buku berdasarkan ID (wajib If-Match)",
 				},
 				"Categories": gin.H{
 					"GET /api/categories":        "Menampilkan semua kategori",
 					"POST /api/categories":       "Menambahkan kategori baru",
 					"GET /api/categories/:id":    "Menampilkan detail kategori by ID",
-					"PUT /api/categories/:id":    "Update kategori berdasarkan ID",
-					"DELETE /api/categories/:id": "Hapus kategori berdasarkan ID",
+					"PUT /api/categories/:id":    "Update kategori berdasarkan ID (wajib If-Match)",
+					"PATCH /api/categories/:id":  "Update sebagian kategori (merge-patch / json-patch)",
+					"DELETE /api/categories/:id": "Hapus kategori berdasarkan ID (wajib If-Match)",
+				},
+				"OPDS": gin.H{
+					"GET /api/opds":                "Katalog OPDS 1.2 (Atom) untuk aplikasi e-reader",
+					"GET /api/opds/v2":             "Katalog OPDS 2.0 (JSON)",
+					"GET /api/opds/opensearch.xml": "OpenSearch descriptor untuk pencarian katalog",
+				},
+				"ONIX": gin.H{
+					"GET /api/books/export?format=onix":                    "Feed ONIX 3.0 lengkap",
+					"GET /api/books/export?format=onix&modified_since=...": "Feed ONIX 3.0 delta (berubah sejak waktu tertentu)",
+					"POST /api/onix/validate":                              "Validasi file ONIX 3.0",
+				},
+				"Files": gin.H{
+					"GET /api/covers/:hash/:file": "Menampilkan cover atau thumbnail (publik, cacheable)",
+					"GET /api/files/:id":          "Download file digital lewat link bertanda tangan (mendukung Range)",
+				},
+				"Inventory": gin.H{
+					"GET /api/locations":                 "Menampilkan seluruh lokasi stok (gudang/toko)",
+					"POST /api/locations":                "Membuat lokasi stok",
+					"GET /api/locations/:id":             "Detail lokasi stok",
+					"PUT /api/locations/:id":             "Mengubah lokasi stok",
+					"DELETE /api/locations/:id":          "Menghapus lokasi stok tanpa riwayat",
+					"GET /api/locations/:id/stock":       "Stok semua buku di sebuah lokasi",
+					"GET /api/books/:id/stock":           "Stok buku per lokasi",
+					"PUT /api/books/:id/stock/threshold": "Mengatur batas stok minimum buku",
+					"GET /api/stock/movements":           "Riwayat pergerakan stok (ledger)",
+					"POST /api/stock/movements":          "Mencatat penerimaan, penjualan, transfer, atau penyesuaian stok",
+					"GET /api/stock/alerts":              "Daftar peringatan stok menipis",
+				},
+				"Circulation": gin.H{
+					"GET /api/patrons":                   "Menampilkan seluruh anggota perpustakaan",
+					"POST /api/patrons":                  "Mendaftarkan anggota (librarian)",
+					"GET /api/patrons/:id":               "Detail anggota",
+					"PUT /api/patrons/:id":               "Mengubah anggota (batas pinjam, status; librarian)",
+					"GET /api/patrons/:id/loans":         "Riwayat peminjaman anggota",
+					"GET /api/books/:id/copies":          "Daftar eksemplar buku",
+					"POST /api/books/:id/copies":         "Menambah eksemplar buku (librarian)",
+					"GET /api/copies/:id":                "Detail eksemplar",
+					"PUT /api/copies/:id":                "Mengubah eksemplar (kondisi, rak, status; librarian)",
+					"DELETE /api/copies/:id":             "Menghapus eksemplar yang belum pernah dipinjam (librarian)",
+					"GET /api/copies/:id/loans":          "Riwayat peminjaman eksemplar",
+					"GET /api/loans":                     "Daftar peminjaman (?active=true)",
+					"POST /api/loans/checkout":           "Meminjamkan eksemplar berdasarkan barcode (librarian)",
+					"POST /api/loans/return":             "Mengembalikan eksemplar berdasarkan barcode (librarian)",
+					"POST /api/loans/:id/renew":          "Memperpanjang peminjaman (librarian)",
+					"POST /api/holds":                    "Memesan buku yang semua eksemplarnya sedang dipinjam",
+					"GET /api/holds":                     "Daftar pemesanan (?active=true)",
+					"GET /api/holds/:id":                 "Detail pemesanan dan posisi antrean",
+					"DELETE /api/holds/:id":              "Membatalkan pemesanan",
+					"GET /api/books/:id/holds":           "Antrean pemesanan buku",
+					"GET /api/patrons/:id/holds":         "Pemesanan anggota beserta posisi antrean",
+					"GET /api/patrons/:id/notifications": "Notifikasi anggota",
+					"GET /api/me/holds":                  "Pemesanan milik user yang login beserta posisi antrean",
+					"GET /api/me/notifications":          "Notifikasi milik user yang login",
+					"GET /api/loans/overdue":             "Daftar peminjaman yang terlambat beserta dendanya",
+					"GET /api/patrons/:id/fines":         "Saldo denda anggota, rincian denda, pembayaran, dan pembebasan",
+					"POST /api/patrons/:id/payments":     "Mencatat pembayaran denda (librarian)",
+					"POST /api/patrons/:id/waivers":      "Membebaskan denda (admin)",
+				},
+				"Reviews": gin.H{
+					"GET /api/books?sort=rating":   "Menampilkan buku diurutkan berdasarkan rating",
+					"GET /api/books/:id/reviews":   "Ulasan buku yang tampil",
+					"POST /api/books/:id/reviews":  "Menulis ulasan dan rating 1-5 (satu per user per buku)",
+					"PUT /api/reviews/:id":         "Mengubah ulasan milik sendiri",
+					"DELETE /api/reviews/:id":      "Menghapus ulasan milik sendiri",
+					"GET /api/reviews":             "Daftar ulasan untuk moderasi (editor)",
+					"POST /api/reviews/:id/hide":   "Menyembunyikan ulasan (editor)",
+					"POST /api/reviews/:id/unhide": "Menampilkan kembali ulasan (editor)",
+				},
+				"Recommendations": gin.H{
+					"GET /api/books/:id/similar": "Buku serupa berdasarkan kategori, penulis, tahun terbit, daftar bacaan dan peminjaman (?limit=10)",
+				},
+				"ReadingLists": gin.H{
+					"GET /api/lists":                      "Daftar bacaan milik user (rak to_read, reading, finished, dan daftar custom)",
+					"POST /api/lists":                     "Membuat daftar bacaan custom",
+					"GET /api/lists/:id":                  "Isi daftar bacaan beserta progres membaca",
+					"PUT /api/lists/:id":                  "Mengganti nama daftar custom",
+					"DELETE /api/lists/:id":               "Menghapus daftar custom",
+					"POST /api/lists/:id/items":           "Menambah buku ke daftar (dengan catatan dan posisi)",
+					"PUT /api/lists/:id/items/:bookId":    "Mengubah catatan atau posisi buku di daftar",
+					"DELETE /api/lists/:id/items/:bookId": "Menghapus buku dari daftar",
+					"POST /api/lists/:id/share":           "Membuat link berbagi daftar",
+					"DELETE /api/lists/:id/share":         "Mencabut link berbagi daftar",
+					"GET /api/shared/lists/:token":        "Melihat daftar yang dibagikan (publik)",
+					"GET /api/books/:id/progress":         "Progres membaca buku",
+					"PUT /api/books/:id/progress":         "Mengubah halaman terakhir yang dibaca",
+					"GET /api/me/progress":                "Semua progres membaca user",
+				},
+				"ExchangeRates": gin.H{
+					"GET /api/exchange-rates":              "Menampilkan kurs konversi mata uang",
+					"PUT /api/exchange-rates":              "Mengubah kurs (admin)",
+					"DELETE /api/exchange-rates/:currency": "Menghapus kurs (admin)",
+				},
+				"Thickness": gin.H{
+					"GET /api/thickness-bands":                    "Menampilkan aturan ketebalan buku",
+					"PUT /api/thickness-bands":                    "Mengganti aturan ketebalan (admin) dan menghitung ulang semua buku",
+					"POST /api/thickness-bands/recalculate":       "Menghitung ulang ketebalan semua buku (admin)",
+					"GET /api/thickness-bands/recalculations/:id": "Status proses hitung ulang",
 				},
 				"Auth": gin.H{
 					"POST /api/login": "Login dan mendapatkan JWT token",
@@ -48,6 +164,32 @@ func SetupRoutes(router *gin.Engine) {
 	{
 		// Authentication
 		api.POST("/login", handlers.Login)
+
+		// Cover images are public so they can be used in <img> tags
+		api.GET("/covers/:hash/:file", handlers.GetCover)
+
+		// Digital editions are downloaded through signed links
+		api.GET("/files/:id", handlers.DownloadBookFile)
+		api.HEAD("/files/:id", handlers.DownloadBookFile)
+
+		// Reading lists shared by link
+		api.GET("/shared/lists/:token", handlers.GetSharedReadingList)
+
+		// OPDS catalog routes. E-readers cannot send a Bearer token, so the
+		// catalog also accepts HTTP Basic credentials.
+		catalog := api.Group("/opds")
+		catalog.Use(middleware.CatalogAuthMiddleware())
+		{
+			catalog.GET("", handlers.GetOPDSRoot)
+			catalog.GET("/books", handlers.GetOPDSBooks)
+			catalog.GET("/categories/:id", handlers.GetOPDSCategoryBooks)
+			catalog.GET("/search", handlers.SearchOPDS)
+			catalog.GET("/opensearch.xml", handlers.GetOpenSearchDescription)
+			catalog.GET("/v2", handlers.GetOPDS2Root)
+			catalog.GET("/v2/books", handlers.GetOPDS2Books)
+			catalog.GET("/v2/categories/:id", handlers.GetOPDS2CategoryBooks)
+			catalog.GET("/v2/search", handlers.SearchOPDS2)
+		}
 	}
 
 	// Protected routes (require JWT token)
@@ -61,17 +203,161 @@ func SetupRoutes(router *gin.Engine) {
 			categories.POST("", handlers.CreateCategory)
 			categories.GET("/:id", handlers.GetCategoryByID)
 			categories.PUT("/:id", handlers.UpdateCategory)
+			categories.PATCH("/:id", handlers.PatchCategory)
 			categories.DELETE("/:id", handlers.DeleteCategory)
 			categories.GET("/:id/books", handlers.GetBooksByCategory)
 		}
 
+		// Stock locations and the stock ledger
+		locations := protected.Group("/locations")
+		{
+			locations.GET("", handlers.GetAllLocations)
+			locations.POST("", handlers.CreateLocation)
+			locations.GET("/:id", handlers.GetLocationByID)
+			locations.PUT("/:id", handlers.UpdateLocation)
+			locations.DELETE("/:id", handlers.DeleteLocation)
+			locations.GET("/:id/stock", handlers.GetLocationStock)
+		}
+
+		stock := protected.Group("/stock")
+		{
+			stock.GET("/movements", handlers.GetStockMovements)
+			stock.POST("/movements", handlers.RecordStockMovement)
+			stock.GET("/alerts", handlers.GetStockAlerts)
+		}
+
+		// Library circulation: patrons, copies and loans
+		patrons := protected.Group("/patrons")
+		{
+			patrons.GET("", handlers.GetAllPatrons)
+			patrons.POST("", middleware.RequireRole(middleware.RoleLibrarian), handlers.CreatePatron)
+			patrons.GET("/:id", handlers.GetPatronByID)
+			patrons.PUT("/:id", middleware.RequireRole(middleware.RoleLibrarian), handlers.UpdatePatron)
+			patrons.GET("/:id/loans", handlers.GetPatronLoans)
+			patrons.GET("/:id/holds", handlers.GetPatronHolds)
+			patrons.GET("/:id/notifications", handlers.GetPatronNotifications)
+			patrons.GET("/:id/fines", handlers.GetPatronFines)
+			patrons.POST("/:id/payments", middleware.RequireRole(middleware.RoleLibrarian), handlers.RecordFinePayment)
+			patrons.POST("/:id/waivers", middleware.RequireRole(middleware.RoleAdmin), handlers.WaiveFine)
+		}
+
+		copies := protected.Group("/copies")
+		{
+			copies.GET("/:id", handlers.GetCopyByID)
+			copies.PUT("/:id", middleware.RequireRole(middleware.RoleLibrarian), handlers.UpdateCopy)
+			copies.DELETE("/:id", middleware.RequireRole(middleware.RoleLibrarian), handlers.DeleteCopy)
+			copies.GET("/:id/loans", handlers.GetCopyLoans)
+		}
+
+		loans := protected.Group("/loans")
+		{
+			loans.GET("", handlers.GetLoans)
+			loans.GET("/overdue", handlers.GetOverdueLoans)
+			loans.POST("/checkout", middleware.RequireRole(middleware.RoleLibrarian), handlers.CheckoutCopy)
+			loans.POST("/return", middleware.RequireRole(middleware.RoleLibrarian), handlers.ReturnCopy)
+			loans.POST("/:id/renew", middleware.RequireRole(middleware.RoleLibrarian), handlers.RenewLoan)
+		}
+
+		holds := protected.Group("/holds")
+		{
+			holds.GET("", handlers.GetHolds)
+			holds.POST("", handlers.PlaceHold)
+			holds.GET("/:id", handlers.GetHoldByID)
+			holds.DELETE("/:id", handlers.CancelHold)
+		}
+
+		// The patron linked to the logged in user
+		me := protected.Group("/me")
+		{
+			me.GET("/holds", handlers.GetMyHolds)
+			me.GET("/notifications", handlers.GetMyNotifications)
+			me.GET("/progress", handlers.GetMyReadingProgress)
+		}
+
+		// Personal reading lists
+		lists := protected.Group("/lists")
+		{
+			lists.GET("", handlers.GetReadingLists)
+			lists.POST("", handlers.CreateReadingList)
+			lists.GET("/:id", handlers.GetReadingList)
+			lists.PUT("/:id", handlers.UpdateReadingList)
+			lists.DELETE("/:id", handlers.DeleteReadingList)
+			lists.POST("/:id/items", handlers.AddReadingListItem)
+			lists.PUT("/:id/items/:bookId", handlers.UpdateReadingListItem)
+			lists.DELETE("/:id/items/:bookId", handlers.DeleteReadingListItem)
+			lists.POST("/:id/share", handlers.ShareReadingList)
+			lists.DELETE("/:id/share", handlers.UnshareReadingList)
+		}
+
+		// Reviews, with moderation for editors
+		reviews := protected.Group("/reviews")
+		{
+			reviews.GET("", middleware.RequireRole(middleware.RoleEditor), handlers.GetReviews)
+			reviews.PUT("/:id", handlers.UpdateReview)
+			reviews.DELETE("/:id", handlers.DeleteReview)
+			reviews.POST("/:id/hide", middleware.RequireRole(middleware.RoleEditor), handlers.HideReview)
+			reviews.POST("/:id/unhide", middleware.RequireRole(middleware.RoleEditor), handlers.UnhideReview)
+		}
+
+		// Exchange rates for price conversion
+		rates := protected.Group("/exchange-rates")
+		{
+			rates.GET("", handlers.GetExchangeRates)
+			rates.PUT("", middleware.RequireRole(middleware.RoleAdmin), handlers.UpdateExchangeRates)
+			rates.DELETE("/:currency", middleware.RequireRole(middleware.RoleAdmin), handlers.DeleteExchangeRate)
+		}
+
+		// Thickness classification rules
+		thickness := protected.Group("/thickness-bands")
+		{
+			thickness.GET("", handlers.GetThicknessBands)
+			thickness.PUT("", middleware.RequireRole(middleware.RoleAdmin), handlers.UpdateThicknessBands)
+			thickness.POST("/recalculate", middleware.RequireRole(middleware.RoleAdmin), handlers.RecalculateThickness)
+			thickness.GET("/recalculations/:id", handlers.GetThicknessRecalculation)
+		}
+
+		// ONIX routes
+		protected.POST("/onix/validate", handlers.ValidateONIX)
+
 		// Book routes
 		books := protected.Group("/books")
 		{
 			books.GET("", handlers.GetAllBooks)
+			books.GET("/export", handlers.ExportBooks)
+			books.GET("/citations", handlers.GetBookCitations)
+			books.GET("/validation-rules", handlers.GetBookValidationRules)
 			books.POST("", handlers.CreateBook)
+			books.POST("/batch", handlers.BatchBooks)
+			books.POST("/import", handlers.ImportBooks)
+			books.POST("/import/marc", handlers.ImportMARC)
+			books.POST("/import/onix", handlers.ImportONIX)
 			books.GET("/:id", handlers.GetBookByID)
+			books.GET("/:id/citation", handlers.GetBookCitation)
+			books.GET("/:id/prices", handlers.GetBookPrices)
+			books.PUT("/:id/prices/:market", handlers.SetBookPrice)
+			books.DELETE("/:id/prices/:market", handlers.DeleteBookPrice)
+			books.POST("/:id/prices/scheduled", handlers.SchedulePriceChange)
+			books.GET("/:id/stock", handlers.GetBookStock)
+			books.PUT("/:id/stock/threshold", handlers.SetStockThreshold)
+			books.GET("/:id/copies", handlers.GetBookCopies)
+			books.POST("/:id/copies", middleware.RequireRole(middleware.RoleLibrarian), handlers.CreateBookCopy)
+			books.GET("/:id/holds", handlers.GetBookHolds)
+			books.GET("/:id/similar", handlers.GetSimilarBooks)
+			books.GET("/:id/reviews", handlers.GetBookReviews)
+			books.POST("/:id/reviews", handlers.CreateBookReview)
+			books.GET("/:id/progress", handlers.GetReadingProgress)
+			books.PUT("/:id/progress", handlers.UpdateReadingProgress)
+			books.DELETE("/:id/prices/scheduled/:changeId", handlers.CancelScheduledPriceChange)
 			books.PUT("/:id", handlers.UpdateBook)
+			books.PATCH("/:id", handlers.PatchBook)
+			books.POST("/:id/cover", handlers.UploadBookCover)
+			books.GET("/:id/files", handlers.GetBookFiles)
+			books.POST("/:id/files", handlers.UploadBookFile)
+			books.DELETE("/:id/files/:fileId", handlers.DeleteBookFile)
+			books.POST("/:id/files/:fileId/link", handlers.CreateBookFileLink)
+			books.GET("/:id/files/:fileId/downloads", handlers.GetBookFileDownloads)
+			books.GET("/:id/files/:fileId/proposals", handlers.GetBookFileProposals)
+			books.POST("/:id/files/:fileId/proposals/accept", handlers.AcceptBookFileProposals)
 			books.DELETE("/:id", handlers.DeleteBook)
 		}
 	}
@@ -0,0 +1,56 @@
+// Package signedurl signs and verifies time-limited links with HMAC-SHA256.
+// A signature covers the URL path, the user the link was issued to and the
+// expiry time, so none of them can be changed without invalidating it.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid signature")
+	ErrExpired = errors.New("link has expired")
+)
+
+// sign computes the signature of a link
+func sign(secret []byte, path, user string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "\n" + user + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the query parameters (user, expires, signature) that grant
+// user access to path until expires
+func Sign(secret []byte, path, user string, expires time.Time) url.Values {
+	unix := expires.Unix()
+	return url.Values{
+		"user":      {user},
+		"expires":   {strconv.FormatInt(unix, 10)},
+		"signature": {sign(secret, path, user, unix)},
+	}
+}
+
+// Verify checks the signature in query against path and returns the user
+// the link was issued to
+func Verify(secret []byte, path string, query url.Values, now time.Time) (string, error) {
+	user := query.Get("user")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+
+	expected := sign(secret, path, user, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return "", ErrInvalid
+	}
+	if now.Unix() > expires {
+		return "", ErrExpired
+	}
+	return user, nil
+}